@@ -3,21 +3,47 @@ package main
 import (
 	"cmp"
 	"fmt"
+	"iter"
 	"log"
 
 	"bplus"
 	"btree"
+	"exthash"
 	"generator"
+	"lsm"
 	"rbtree"
+	"skiplist"
 )
 
-type Tree[K cmp.Ordered, V any] interface {
+/* Structure is implemented by every structure. All yields pairs in ascending order of keys, except for exthash.Table, where it follows hashes. */
+type Structure[K cmp.Ordered, V any] interface {
+	All() iter.Seq2[K, V]
 	Clear()
+	CompareAndSwapFunc(K, V, V, func(V, V) bool) bool
 	Del(K)
 	Get(K) V
+	GetOrSet(K, V) (V, bool)
 	Has(K) bool
+	Lookup(K) (V, bool)
 	Set(K, V)
 	String() string
+	Swap(K, V) (V, bool)
+	Update(K, func(V, bool) (V, bool))
+}
+
+/* Tree is a structure of comparable values, see Comparable. */
+type Tree[K cmp.Ordered, V comparable] interface {
+	Structure[K, V]
+	CompareAndSwap(K, V, V) bool
+}
+
+/* Comparable gives a structure CompareAndSwap, which compares values with ==. Structures themselves take any values, so they only have CompareAndSwapFunc. */
+type Comparable[K cmp.Ordered, V comparable] struct {
+	Structure[K, V]
+}
+
+func (c Comparable[K, V]) CompareAndSwap(key K, old, new V) bool {
+	return c.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
 }
 
 const (
@@ -40,20 +66,21 @@ var (
 )
 
 func BplusPrintSeq(t Tree[int, int]) {
-	bt, ok := t.(*bplus.Tree[int, int])
+	w, ok := t.(Comparable[int, int])
 	if !ok {
 		return
 	}
-	for leaf := bt.Begin(); leaf != bt.End(); leaf = leaf.Next {
-		for i := 0; i < len(leaf.Keys); i++ {
-			fmt.Printf("%d ", leaf.Keys[i])
-		}
+	bt, ok := w.Structure.(*bplus.Tree[int, int])
+	if !ok {
+		return
+	}
+	c := bt.Cursor()
+	for c.First(); c.Valid(); c.Next() {
+		fmt.Printf("%d ", c.Key())
 	}
 	println()
-	for leaf := bt.Rbegin(); leaf != bt.Rend(); leaf = leaf.Prev {
-		for i := len(leaf.Keys) - 1; i >= 0; i-- {
-			fmt.Printf("%d ", leaf.Keys[i])
-		}
+	for c.Last(); c.Valid(); c.Prev() {
+		fmt.Printf("%d ", c.Key())
 	}
 	println()
 	println()
@@ -134,22 +161,74 @@ func Demo(t Tree[int, int]) {
 	BplusPrintSeq(t)
 }
 
+func PointDemo(t Tree[int, int]) {
+	println("POINT!!!")
+	t.Clear()
+	for _, key := range InsertKeys {
+		if _, ok := t.GetOrSet(key, key); ok {
+			log.Panicf("Whoops... %v is present before insertion", key)
+		}
+	}
+	for _, key := range InsertKeys {
+		if old, ok := t.Swap(key, 0); (!ok) || (old != key) {
+			log.Panicf("Whoops... Swapped %v; %v, got %v", key, key, old)
+		}
+		if !t.CompareAndSwap(key, 0, key) {
+			log.Panicf("Whoops... Failed to swap %v back", key)
+		}
+	}
+	for _, key := range DeleleKeys {
+		t.Update(key, func(int, bool) (int, bool) { return 0, false })
+		if _, ok := t.Lookup(key); ok {
+			log.Panicf("Still has %v", key)
+		}
+	}
+	fmt.Println(t)
+	BplusPrintSeq(t)
+}
+
 func main() {
 	{
 		println("RB-tree")
 		t := new(rbtree.Tree[int, int])
-		Demo(t)
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
 	}
 	{
 		println("B-tree")
 		t := new(btree.Tree[int, int])
 		t.Order = Order
-		Demo(t)
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
 	}
 	{
 		println("B+tree")
 		t := new(bplus.Tree[int, int])
 		t.Order = Order
-		Demo(t)
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
+	}
+	{
+		println("Skip list")
+		t := new(skiplist.List[int, int])
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
+	}
+	{
+		println("Extendible hash")
+		t := new(exthash.Table[int, int])
+		t.BucketSize = Order
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
+	}
+	{
+		println("LSM-tree")
+		t := new(lsm.Tree[int, int])
+		t.MemtableSize = Order
+		Demo(Comparable[int, int]{t})
+		PointDemo(Comparable[int, int]{t})
+		if err := t.Close(); err != nil {
+			log.Panicf("Failed to close LSM-tree: %v", err)
+		}
 	}
 }
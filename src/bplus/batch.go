@@ -0,0 +1,373 @@
+package bplus
+
+import (
+	"iter"
+	"slices"
+)
+
+type batchPair[K any, V any] struct {
+	Key   K
+	Value V
+
+	/* Position in the batch, so that the last of repeated keys wins. */
+	index int
+}
+
+/* below reports whether 'key' is less than the separator to the right of the page at 'level' of SearchPath, where leaf is at level len(SearchPath). */
+func (t *Tree[K, V]) below(key K, level int) bool {
+	for j := level - 1; j >= 0; j-- {
+		item := &t.SearchPath[j]
+		if item.Index+1 < len(item.Node.Keys) {
+			return t.Compare(key, item.Node.Keys[item.Index+1]) < 0
+		}
+	}
+	return true
+}
+
+/* attach puts pages split off the child of the node at 'level' of SearchPath next to it, growing the tree if 'level' is -1. */
+func (t *Tree[K, V]) attach(level int, pages []Page, seps []K) {
+	if len(pages) < 2 {
+		return
+	}
+
+	if level >= 0 {
+		item := &t.SearchPath[level]
+		item.Node.Keys = slices.Insert(item.Node.Keys, item.Index+1, seps...)
+		item.Node.Children = slices.Insert(item.Node.Children, item.Index+1, pages[1:]...)
+		item.Node.dirty = true
+		return
+	}
+
+	for len(pages) > 1 {
+		node := t.newNode(0)
+		node.ChildPage0 = pages[0]
+		node.Keys = append(node.Keys, seps...)
+		node.Children = append(node.Children, pages[1:]...)
+		node.dirty = true
+		t.recount(node)
+
+		t.Root = node
+		pages, seps = t.splitNode(node)
+	}
+}
+
+/* splitLeaf splits overfull 'leaf' into as few pages as possible. Returns the pages, starting with 'leaf', and separators between them. */
+func (t *Tree[K, V]) splitLeaf(leaf *Leaf[K, V]) ([]Page, []K) {
+	if !t.overfull(leaf) {
+		return nil, nil
+	}
+
+	var sizes []int
+	if t.sized() {
+		room := t.measure(leaf, nil)
+		sizes = fit(t.sizes, room, room)
+	} else {
+		half := t.Order/2 - (1 - t.Order%2)
+		sizes = parts(len(leaf.Keys), t.Order-1, half, t.Order-1)
+	}
+	pages := make([]Page, len(sizes))
+	seps := make([]K, len(sizes)-1)
+
+	pages[0] = leaf
+	prev := leaf
+	offset := sizes[0]
+	for i := 1; i < len(sizes); i++ {
+		newLeaf := t.newLeaf(sizes[i])
+		copy(newLeaf.Keys, leaf.Keys[offset:])
+		copy(newLeaf.Values, leaf.Values[offset:])
+		newLeaf.dirty = true
+
+		newLeaf.Prev = prev
+		newLeaf.Next = prev.Next
+		if newLeaf.Next != nil {
+			newLeaf.Next.Prev = newLeaf
+		}
+		prev.Next = newLeaf
+		prev = newLeaf
+
+		pages[i] = newLeaf
+		seps[i-1] = t.separator(leaf.Keys[offset-1], leaf.Keys[offset])
+		offset += sizes[i]
+	}
+	/* Overflown arrays would keep memory of all parts, so the first part gets arrays of regular capacity. */
+	keys, values := leaf.Keys, leaf.Values
+	leaf.Keys = make([]K, sizes[0], max(sizes[0], t.Order))
+	leaf.Values = make([]V, sizes[0], max(sizes[0], t.Order))
+	copy(leaf.Keys, keys)
+	copy(leaf.Values, values)
+
+	return pages, seps
+}
+
+/* splitNode is splitLeaf for nodes. */
+func (t *Tree[K, V]) splitNode(node *Node[K]) ([]Page, []K) {
+	if len(node.Keys) < t.Order {
+		return nil, nil
+	}
+
+	/* Sizes are in children, child 'c' > 0 is node.Children[c-1] with node.Keys[c-1] before it. */
+	half := t.Order/2 - (1 - t.Order%2)
+	sizes := parts(len(node.Children)+1, t.Order, half+1, t.Order)
+	pages := make([]Page, len(sizes))
+	seps := make([]K, len(sizes)-1)
+
+	pages[0] = node
+	offset := sizes[0]
+	for i := 1; i < len(sizes); i++ {
+		newNode := t.newNode(sizes[i] - 1)
+		newNode.ChildPage0 = node.Children[offset-1]
+		copy(newNode.Children, node.Children[offset:])
+		copy(newNode.Keys, node.Keys[offset:])
+		newNode.dirty = true
+		t.recount(newNode)
+
+		pages[i] = newNode
+		seps[i-1] = node.Keys[offset-1]
+		offset += sizes[i]
+	}
+	/* See splitLeaf. */
+	keys, children := node.Keys, node.Children
+	node.Keys = make([]K, sizes[0]-1, t.Order)
+	node.Children = make([]Page, sizes[0]-1, t.Order)
+	copy(node.Keys, keys)
+	copy(node.Children, children)
+	node.dirty = true
+	t.recount(node)
+
+	return pages, seps
+}
+
+/*
+ * mergeLeaf logs and merges sorted 'run' of pairs into 'leaf', which SearchPath leads to, letting it overflow.
+ * Returns false if logging has failed, see Err, and then only pairs before the failed one are merged.
+ */
+func (t *Tree[K, V]) mergeLeaf(leaf *Leaf[K, V], run []batchPair[K, V]) bool {
+	ok := true
+
+	/* Forward pass logs pairs in order and finds their positions. */
+	var added int
+	t.positions = t.positions[:0]
+	for j := 0; j < len(run); j++ {
+		index, found := t.findOnLeaf(leaf, run[j].Key)
+
+		var old V
+		if found {
+			old = leaf.Values[index+1]
+		}
+		if !t.logSet(run[j].Key, run[j].Value, old, found) {
+			run = run[:j]
+			ok = false
+			break
+		}
+		if !found {
+			added++
+		}
+		t.positions = append(t.positions, index+1)
+	}
+	if len(run) == 0 {
+		return ok
+	}
+
+	/* Backward pass moves pairs after every position to their places and puts new pair before them. */
+	l := len(leaf.Keys)
+	leaf.Keys = slices.Grow(leaf.Keys, added)[:l+added]
+	leaf.Values = slices.Grow(leaf.Values, added)[:l+added]
+
+	end, w := l, l+added
+	for j := len(run) - 1; j >= 0; j-- {
+		pos := t.positions[j]
+		next := pos
+		if (pos < end) && (t.Compare(leaf.Keys[pos], run[j].Key) == 0) {
+			next++
+		}
+
+		n := end - next
+		copy(leaf.Keys[w-n:w], leaf.Keys[next:end])
+		copy(leaf.Values[w-n:w], leaf.Values[next:end])
+		w -= n + 1
+		leaf.Keys[w] = run[j].Key
+		leaf.Values[w] = run[j].Value
+		end = pos
+	}
+	leaf.dirty = true
+
+	for p := 0; p < len(t.SearchPath); p++ {
+		t.SearchPath[p].Node.size += added
+	}
+	return ok
+}
+
+/*
+ * stripLeaf logs and deletes keys of sorted 'run' from 'leaf', which SearchPath leads to, letting it underflow.
+ * Returns false if logging has failed, see Err, and then only keys before the failed one are deleted.
+ */
+func (t *Tree[K, V]) stripLeaf(leaf *Leaf[K, V], run []K) bool {
+	ok := true
+
+	t.positions = t.positions[:0]
+	for _, key := range run {
+		index, found := t.findOnLeaf(leaf, key)
+		if !found {
+			continue
+		}
+		if !t.logDel(key, leaf.Values[index+1]) {
+			ok = false
+			break
+		}
+		t.positions = append(t.positions, index+1)
+	}
+	if len(t.positions) == 0 {
+		return ok
+	}
+
+	/* Pairs between deleted ones move left by the number of pairs deleted before them. */
+	w := t.positions[0]
+	for i, pos := range t.positions {
+		end := len(leaf.Keys)
+		if i+1 < len(t.positions) {
+			end = t.positions[i+1]
+		}
+		copy(leaf.Keys[w:], leaf.Keys[pos+1:end])
+		copy(leaf.Values[w:], leaf.Values[pos+1:end])
+		w += end - pos - 1
+	}
+
+	removed := len(leaf.Keys) - w
+	leaf.Keys = leaf.Keys[:w]
+	leaf.Values = leaf.Values[:w]
+	leaf.dirty = true
+
+	for p := 0; p < len(t.SearchPath); p++ {
+		t.SearchPath[p].Node.size -= removed
+	}
+	return ok
+}
+
+/*
+ * DelMany deletes all keys from 'seq'. Keys are sorted and deleted in one pass: all keys of a leaf are deleted from it
+ * at once, search resumes from the lowest node above both neighbouring leaves, and every leaf left underfull is
+ * refilled or merged once.
+ */
+func (t *Tree[K, V]) DelMany(seq iter.Seq[K]) {
+	keys := slices.Collect(seq)
+
+	t.init()
+	if !slices.IsSortedFunc(keys, t.Compare) {
+		slices.SortFunc(keys, t.Compare)
+	}
+	keys = slices.CompactFunc(keys, func(a, b K) bool { return t.Compare(a, b) == 0 })
+	if (len(keys) == 0) || (t.Root == nil) {
+		return
+	}
+
+	leaf, _, _ := t.search(keys[0])
+	for leaf != nil {
+		n := 1
+		for (n < len(keys)) && t.below(keys[n], len(t.SearchPath)) {
+			n++
+		}
+
+		leaf = t.ownPath(leaf)
+		ok := t.stripLeaf(leaf, keys[:n])
+		keys = keys[n:]
+
+		/* Merges may change any node above, so search starts over. */
+		restart := (leaf != t.Root) && (t.underfull(leaf))
+		t.rebalance(leaf)
+		if (!ok) || (len(keys) == 0) {
+			break
+		}
+
+		if restart {
+			t.SearchPath = t.SearchPath[:0]
+			leaf, _, _ = t.search(keys[0])
+			continue
+		}
+		level := len(t.SearchPath) - 1
+		for !t.below(keys[0], level) {
+			level--
+		}
+		node := t.SearchPath[level].Node
+		t.SearchPath = t.SearchPath[:level]
+		leaf, _, _ = t.searchFrom(node, keys[0])
+	}
+}
+
+/*
+ * SetMany sets all pairs from 'seq', the last value winning for repeated keys. Pairs are sorted and applied in one
+ * pass: all keys of a leaf are merged into it at once, search resumes from the lowest node above both neighbouring
+ * leaves, and every overfull page is split once, into as many pages as needed, when the pass leaves it.
+ */
+func (t *Tree[K, V]) SetMany(seq iter.Seq2[K, V]) {
+	var pairs []batchPair[K, V]
+	for k, v := range seq {
+		pairs = append(pairs, batchPair[K, V]{Key: k, Value: v, index: len(pairs)})
+	}
+
+	t.init()
+	if !slices.IsSortedFunc(pairs, func(a, b batchPair[K, V]) int { return t.Compare(a.Key, b.Key) }) {
+		slices.SortFunc(pairs, func(a, b batchPair[K, V]) int {
+			if c := t.Compare(a.Key, b.Key); c != 0 {
+				return c
+			}
+			return a.index - b.index
+		})
+	}
+	var n int
+	for i := 0; i < len(pairs); i++ {
+		if (n > 0) && (t.Compare(pairs[n-1].Key, pairs[i].Key) == 0) {
+			n--
+		}
+		pairs[n] = pairs[i]
+		n++
+	}
+	pairs = pairs[:n]
+
+	if (len(pairs) > 0) && (t.Root == nil) {
+		if !t.setAt(nil, -1, false, pairs[0].Key, pairs[0].Value) {
+			return
+		}
+		pairs = pairs[1:]
+	}
+	if len(pairs) == 0 {
+		return
+	}
+
+	leaf, _, _ := t.search(pairs[0].Key)
+	for leaf != nil {
+		n := 1
+		for (n < len(pairs)) && t.below(pairs[n].Key, len(t.SearchPath)) {
+			n++
+		}
+
+		leaf = t.ownPath(leaf)
+		ok := t.mergeLeaf(leaf, pairs[:n])
+		pairs = pairs[n:]
+
+		pages, seps := t.splitLeaf(leaf)
+		t.attach(len(t.SearchPath)-1, pages, seps)
+		if (!ok) || (len(pairs) == 0) {
+			break
+		}
+
+		/* Nodes are split when the pass leaves them, parents are not split until then. */
+		level := len(t.SearchPath) - 1
+		for !t.below(pairs[0].Key, level) {
+			pages, seps := t.splitNode(t.SearchPath[level].Node)
+			t.attach(level-1, pages, seps)
+			level--
+		}
+		node := t.SearchPath[level].Node
+		t.SearchPath = t.SearchPath[:level]
+		leaf, _, _ = t.searchFrom(node, pairs[0].Key)
+	}
+	t.splitPath()
+}
+
+/* splitPath splits overfull nodes of SearchPath bottom-up. */
+func (t *Tree[K, V]) splitPath() {
+	for level := len(t.SearchPath) - 1; level >= 0; level-- {
+		pages, seps := t.splitNode(t.SearchPath[level].Node)
+		t.attach(level-1, pages, seps)
+	}
+}
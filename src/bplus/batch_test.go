@@ -0,0 +1,241 @@
+package bplus
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* BatchSizes are sizes of batches in batch tests, from single keys to the whole input. */
+var BatchSizes = [...]int{1, 7, 100, constants.N}
+
+/* checkCapacity verifies that pages split after overflowing in batches do not keep their large arrays. */
+func checkCapacity(t *testing.T, bt *Tree[int, int], page Page) {
+	t.Helper()
+
+	switch p := page.(type) {
+	case *Node[int]:
+		if (cap(p.Keys) > bt.Order) || (cap(p.Children) > bt.Order) {
+			t.Errorf("node %v has capacity %d, order is %d", p.Keys, cap(p.Keys), bt.Order)
+		}
+		checkCapacity(t, bt, p.ChildPage0)
+		for _, child := range p.Children {
+			checkCapacity(t, bt, child)
+		}
+	case *Leaf[int, int]:
+		if (cap(p.Keys) > bt.Order) || (cap(p.Values) > bt.Order) {
+			t.Errorf("leaf %v has capacity %d, order is %d", p.Keys, cap(p.Keys), bt.Order)
+		}
+	}
+}
+
+func checkBatch(t *testing.T, bt *Tree[int, int], m map[int]int) {
+	t.Helper()
+
+	checkPages(t, bt)
+	checkCapacity(t, bt, bt.Root)
+	checkSizes[int, int](t, bt.Root)
+	checkBounds(t, bt.Root, math.MinInt, math.MaxInt)
+	if bt.Len() != len(m) {
+		t.Errorf("expected length %d, got %d", len(m), bt.Len())
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	var i int
+	for k, v := range bt.All() {
+		if (i >= len(keys)) || (k != keys[i]) || (v != m[k]) {
+			t.Errorf("All: unexpected pair %v: %v at %d", k, v, i)
+			return
+		}
+		i++
+	}
+	if i != len(keys) {
+		t.Errorf("All: expected %d keys, got %d", len(keys), i)
+	}
+
+	c := bt.Cursor()
+	for c.Last(); c.Valid(); c.Prev() {
+		i--
+		if (i < 0) || (c.Key() != keys[i]) {
+			t.Errorf("Prev: unexpected key %v at %d", c.Key(), i)
+			return
+		}
+	}
+	if i != 0 {
+		t.Errorf("Prev: expected %d keys, got %d", len(keys), len(keys)-i)
+	}
+}
+
+func testBplusSetMany(t *testing.T, g generator.Generator, order, size int) {
+	t.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	/* Half of keys are already present, so batches both add and overwrite. */
+	ks := make([]int, constants.N)
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		ks[i] = g.Generate()
+		if i%2 == 0 {
+			m[ks[i]] = -i
+			bt.Set(ks[i], -i)
+		}
+	}
+	snapshot := bt.Snapshot()
+	old := maps.Clone(m)
+
+	for lo := 0; lo < len(ks); lo += size {
+		batch := ks[lo:min(lo+size, len(ks))]
+		for i, k := range batch {
+			m[k] = lo + i
+		}
+		/* Repeated key takes the last value. */
+		batch = append(slices.Clip(batch), batch[0])
+		m[batch[0]] = len(ks)
+
+		bt.SetMany(func(yield func(int, int) bool) {
+			for i, k := range batch {
+				v := lo + i
+				if i == len(batch)-1 {
+					v = len(ks)
+				}
+				if !yield(k, v) {
+					return
+				}
+			}
+		})
+	}
+	checkBatch(t, &bt, m)
+	checkView(t, snapshot, old)
+}
+
+func testBplusDelMany(t *testing.T, g generator.Generator, order, size int) {
+	t.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	ks := make([]int, constants.N)
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		ks[i] = g.Generate()
+		m[ks[i]] = i
+		bt.Set(ks[i], i)
+	}
+	snapshot := bt.Snapshot()
+	old := maps.Clone(m)
+
+	/* Every other batch is deleted, then the rest, so that deletions hit both dense and sparse leaves. */
+	for pass := 0; pass < 2; pass++ {
+		for lo := pass * size; lo < len(ks); lo += 2 * size {
+			batch := ks[lo:min(lo+size, len(ks))]
+			for _, k := range batch {
+				delete(m, k)
+			}
+			/* Absent keys are ignored. */
+			bt.DelMany(slices.Values(append(slices.Clip(batch), math.MinInt, math.MaxInt)))
+		}
+		checkBatch(t, &bt, m)
+	}
+	if len(m) != 0 {
+		t.Errorf("expected all keys to be deleted, %d left", len(m))
+	}
+	checkView(t, snapshot, old)
+}
+
+func TestBplusBatch(t *testing.T) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.T, generator.Generator, int, int)
+	}{
+		{"SetMany", testBplusSetMany},
+		{"DelMany", testBplusDelMany},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, constants.MinOrder + 2, DefaultOrder, constants.MaxOrder}
+
+	for _, op := range ops {
+		t.Run(op.Name, func(t *testing.T) {
+			for _, generator := range generators {
+				for _, order := range orders {
+					for _, size := range BatchSizes {
+						t.Run(fmt.Sprintf("%s/Order-%d/Batch-%d", generator, order, size), func(t *testing.T) {
+							generator.Reset()
+							op.Func(t, generator, order, size)
+						})
+					}
+				}
+			}
+		})
+	}
+}
+
+func benchmarkBplusBatch(b *testing.B, g generator.Generator, size int, batched bool) {
+	b.Helper()
+
+	var bt Tree[int, int]
+	for i := 0; i < constants.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+
+	batch := make([]int, size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range batch {
+			batch[j] = g.Generate()
+		}
+
+		if batched {
+			bt.SetMany(func(yield func(int, int) bool) {
+				for _, k := range batch {
+					if !yield(k, i) {
+						return
+					}
+				}
+			})
+			bt.DelMany(slices.Values(batch))
+		} else {
+			for _, k := range batch {
+				bt.Set(k, i)
+			}
+			for _, k := range batch {
+				bt.Del(k)
+			}
+		}
+	}
+}
+
+func BenchmarkBplusBatch(b *testing.B) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+	}
+
+	for _, generator := range generators {
+		for _, size := range BatchSizes[1:] {
+			for _, batched := range [...]bool{false, true} {
+				name := "Single"
+				if batched {
+					name = "Many"
+				}
+				b.Run(fmt.Sprintf("%s/Batch-%d/%s", generator, size, name), func(b *testing.B) {
+					generator.Reset()
+					benchmarkBplusBatch(b, generator, size, batched)
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+/* blinkPage is an immutable state of BlinkTree node. Keys separate children, so that len(Children) == len(Keys)+1. */
+type blinkPage[K cmp.Ordered, V any] struct {
+	Keys     []K
+	Values   []V
+	Children []*blinkNode[K, V]
+
+	/* Keys of the node are less than High, unless node is the rightmost one on its level and has no Right. */
+	High  K
+	Right *blinkNode[K, V]
+
+	/* Level is 0 for leaves. */
+	Level int
+}
+
+/* blinkNode latch is taken only by writers, readers load the current page. */
+type blinkNode[K cmp.Ordered, V any] struct {
+	sync.Mutex
+	page atomic.Pointer[blinkPage[K, V]]
+}
+
+/*
+ * BlinkTree is a B-link tree (Lehman, Yao, "Efficient Locking for Concurrent Operations on B-Trees"), safe for concurrent
+ * use. Every node has a right-link to its sibling and a high key, so a split is done in two steps: the new right
+ * node is linked to its left sibling first and is added to the parent later. Search that lands on a node which no
+ * longer covers its key just follows the right-link.
+ *
+ * Nodes are copy-on-write, so readers never take latches. Writers latch one node at a time: a leaf to modify it, then
+ * each parent that receives a separator after split. Following Lehman and Yao, Del does not merge nodes, so pages may
+ * stay underfull.
+ */
+type BlinkTree[K cmp.Ordered, V any] struct {
+	Order int
+
+	/* Serializes creation of new roots. */
+	rootLatch sync.Mutex
+	root      atomic.Pointer[blinkRoot[K, V]]
+}
+
+/* blinkRoot holds root of the tree until Clear replaces it, so that operations still running on the old tree can not reach the new one. */
+type blinkRoot[K cmp.Ordered, V any] struct {
+	node atomic.Pointer[blinkNode[K, V]]
+}
+
+/* covers reports whether the key belongs to this node and not to one of its right siblings. */
+func (p *blinkPage[K, V]) covers(key K) bool {
+	return (p.Right == nil) || (key < p.High)
+}
+
+/* child returns index of the child subtree which may contain the key. */
+func (p *blinkPage[K, V]) child(key K) int {
+	i := 0
+	for (i < len(p.Keys)) && (key >= p.Keys[i]) {
+		i++
+	}
+	return i
+}
+
+/* find returns position of the key in the leaf, or position where it should be inserted. */
+func (p *blinkPage[K, V]) find(key K) (int, bool) {
+	i := 0
+	for (i < len(p.Keys)) && (key > p.Keys[i]) {
+		i++
+	}
+	return i, (i < len(p.Keys)) && (key == p.Keys[i])
+}
+
+/* clone returns a copy of the page with room for one more key. */
+func (p *blinkPage[K, V]) clone() *blinkPage[K, V] {
+	np := &blinkPage[K, V]{High: p.High, Right: p.Right, Level: p.Level}
+	np.Keys = append(make([]K, 0, len(p.Keys)+1), p.Keys...)
+	if p.Level == 0 {
+		np.Values = append(make([]V, 0, len(p.Keys)+1), p.Values...)
+	} else {
+		np.Children = append(make([]*blinkNode[K, V], 0, len(p.Children)+1), p.Children...)
+	}
+	return np
+}
+
+/* split moves upper half of the page to a new right sibling and returns separator. */
+func (p *blinkPage[K, V]) split() (*blinkNode[K, V], K) {
+	half := len(p.Keys) / 2
+
+	var sep K
+	rp := &blinkPage[K, V]{High: p.High, Right: p.Right, Level: p.Level}
+	if p.Level == 0 {
+		rp.Keys = append([]K(nil), p.Keys[half:]...)
+		rp.Values = append([]V(nil), p.Values[half:]...)
+		p.Keys = p.Keys[:half:half]
+		p.Values = p.Values[:half:half]
+		sep = rp.Keys[0]
+	} else {
+		sep = p.Keys[half]
+		rp.Keys = append([]K(nil), p.Keys[half+1:]...)
+		rp.Children = append([]*blinkNode[K, V](nil), p.Children[half+1:]...)
+		p.Keys = p.Keys[:half:half]
+		p.Children = p.Children[: half+1 : half+1]
+	}
+
+	right := new(blinkNode[K, V])
+	right.page.Store(rp)
+	p.High = sep
+	p.Right = right
+
+	return right, sep
+}
+
+func newBlinkNode[K cmp.Ordered, V any](p *blinkPage[K, V]) *blinkNode[K, V] {
+	n := new(blinkNode[K, V])
+	n.page.Store(p)
+	return n
+}
+
+func newBlinkRoot[K cmp.Ordered, V any]() *blinkRoot[K, V] {
+	r := new(blinkRoot[K, V])
+	r.node.Store(newBlinkNode(new(blinkPage[K, V])))
+	return r
+}
+
+/* getRoot returns the root, creating an empty one first if needed. */
+func (t *BlinkTree[K, V]) getRoot() *blinkRoot[K, V] {
+	if r := t.root.Load(); r != nil {
+		return r
+	}
+
+	t.rootLatch.Lock()
+	defer t.rootLatch.Unlock()
+
+	if t.root.Load() == nil {
+		if t.Order == 0 {
+			t.Order = DefaultOrder
+		}
+		t.root.Store(newBlinkRoot[K, V]())
+	}
+	return t.root.Load()
+}
+
+/* descend returns node on the level which covers the key, together with nodes it went through on upper levels. */
+func (t *BlinkTree[K, V]) descend(n *blinkNode[K, V], key K, level int) (*blinkNode[K, V], []*blinkNode[K, V]) {
+	var stack []*blinkNode[K, V]
+	for {
+		p := n.page.Load()
+		if !p.covers(key) {
+			n = p.Right
+			continue
+		}
+		if p.Level == level {
+			return n, stack
+		}
+		stack = append(stack, n)
+		n = p.Children[p.child(key)]
+	}
+}
+
+/* lockCovering latches 'n' and moves right until it reaches node that covers the key. Only one latch is held at a time. */
+func (t *BlinkTree[K, V]) lockCovering(n *blinkNode[K, V], key K) *blinkNode[K, V] {
+	for {
+		n.Lock()
+		p := n.page.Load()
+		if p.covers(key) {
+			return n
+		}
+		n.Unlock()
+		n = p.Right
+	}
+}
+
+/*
+ * grow is called when node on the root 'level' split and its parent is unknown. If root is still on that level, new
+ * root is created above every node of the level. If the tree has grown meanwhile, returns node on the level above
+ * that covers the key. Returns nil if there is nothing left to do.
+ */
+func (t *BlinkTree[K, V]) grow(r *blinkRoot[K, V], key K, level int) *blinkNode[K, V] {
+	t.rootLatch.Lock()
+	defer t.rootLatch.Unlock()
+
+	root := r.node.Load()
+	if root.page.Load().Level > level {
+		n, _ := t.descend(root, key, level+1)
+		return n
+	}
+
+	/* Root is always the leftmost node of its level. */
+	np := &blinkPage[K, V]{Level: level + 1}
+	for n := root; n != nil; {
+		p := n.page.Load()
+		np.Children = append(np.Children, n)
+		if p.Right != nil {
+			np.Keys = append(np.Keys, p.High)
+		}
+		n = p.Right
+	}
+	r.node.Store(newBlinkNode(np))
+
+	return nil
+}
+
+/* leaf returns page of the leaf that covers the key. Page is loaded once, so that a concurrent split cannot move the key out of it after the check. */
+func (t *BlinkTree[K, V]) leaf(key K) *blinkPage[K, V] {
+	r := t.root.Load()
+	if r == nil {
+		return nil
+	}
+	n, _ := t.descend(r.node.Load(), key, 0)
+	for {
+		p := n.page.Load()
+		if p.covers(key) {
+			return p
+		}
+		n = p.Right
+	}
+}
+
+/* All returns iterator over all key-value pairs in ascending order. Each leaf is seen as of one moment. */
+func (t *BlinkTree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		r := t.root.Load()
+		if r == nil {
+			return
+		}
+		n := r.node.Load()
+		for p := n.page.Load(); p.Level > 0; p = n.page.Load() {
+			n = p.Children[0]
+		}
+
+		for n != nil {
+			p := n.page.Load()
+			for i := 0; i < len(p.Keys); i++ {
+				if !yield(p.Keys[i], p.Values[i]) {
+					return
+				}
+			}
+			n = p.Right
+		}
+	}
+}
+
+/* Clear removes all keys. Operations that already passed the root finish on the old tree. */
+func (t *BlinkTree[K, V]) Clear() {
+	t.rootLatch.Lock()
+	defer t.rootLatch.Unlock()
+
+	if t.root.Load() != nil {
+		t.root.Store(newBlinkRoot[K, V]())
+	}
+}
+
+func (t *BlinkTree[K, V]) Del(key K) {
+	n, _ := t.descend(t.getRoot().node.Load(), key, 0)
+	n = t.lockCovering(n, key)
+	defer n.Unlock()
+
+	p := n.page.Load()
+	i, ok := p.find(key)
+	if !ok {
+		return
+	}
+	np := p.clone()
+	np.Keys = removeAtIndex(np.Keys, i)
+	np.Values = removeAtIndex(np.Values, i)
+	n.page.Store(np)
+}
+
+func (t *BlinkTree[K, V]) Get(key K) V {
+	var v V
+
+	p := t.leaf(key)
+	if p == nil {
+		return v
+	}
+	if i, ok := p.find(key); ok {
+		v = p.Values[i]
+	}
+	return v
+}
+
+func (t *BlinkTree[K, V]) Has(key K) bool {
+	p := t.leaf(key)
+	if p == nil {
+		return false
+	}
+	_, ok := p.find(key)
+	return ok
+}
+
+func (t *BlinkTree[K, V]) Set(key K, value V) {
+	r := t.getRoot()
+	n, stack := t.descend(r.node.Load(), key, 0)
+	n = t.lockCovering(n, key)
+
+	p := n.page.Load()
+	np := p.clone()
+	i, ok := p.find(key)
+	if ok {
+		np.Values[i] = value
+		n.page.Store(np)
+		n.Unlock()
+		return
+	}
+	np.Keys = insertAtIndex(np.Keys, key, i)
+	np.Values = insertAtIndex(np.Values, value, i)
+
+	for level := 0; len(np.Keys) >= t.Order; level++ {
+		/* Right node becomes reachable through the right-link once the left one is stored. */
+		right, sep := np.split()
+		n.page.Store(np)
+		n.Unlock()
+
+		var parent *blinkNode[K, V]
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		} else if parent = t.grow(r, sep, level); parent == nil {
+			return
+		}
+
+		n = t.lockCovering(parent, sep)
+		p = n.page.Load()
+		i := p.child(sep)
+		if (i > 0) && (p.Keys[i-1] == sep) {
+			/* Separator was added by grow. */
+			n.Unlock()
+			return
+		}
+		np = p.clone()
+		np.Keys = insertAtIndex(np.Keys, sep, i)
+		np.Children = insertAtIndex(np.Children, right, i+1)
+	}
+
+	n.page.Store(np)
+	n.Unlock()
+}
+
+/* String prints every level from left to right, separating nodes with '|'. It must not be called concurrently with modifications. */
+func (t *BlinkTree[K, V]) String() string {
+	var sb strings.Builder
+
+	r := t.root.Load()
+	if r == nil {
+		return ""
+	}
+	for n := r.node.Load(); n != nil; {
+		var first *blinkNode[K, V]
+		for m := n; m != nil; {
+			p := m.page.Load()
+			if m != n {
+				sb.WriteString(" |")
+			}
+			for i := 0; i < len(p.Keys); i++ {
+				fmt.Fprintf(&sb, "%4v", p.Keys[i])
+			}
+			if (first == nil) && (p.Level > 0) {
+				first = p.Children[0]
+			}
+			m = p.Right
+		}
+		sb.WriteRune('\n')
+		n = first
+	}
+
+	return sb.String()
+}
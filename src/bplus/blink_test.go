@@ -0,0 +1,260 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkBlink verifies every level of the tree: key order, size of nodes, high keys and right-links. Returns number of keys in leaves. */
+func checkBlink[K cmp.Ordered, V any](t *testing.T, bt *BlinkTree[K, V]) int {
+	t.Helper()
+
+	r := bt.root.Load()
+	if r == nil {
+		return 0
+	}
+
+	var count int
+	var children []*blinkNode[K, V]
+	for n := r.node.Load(); n != nil; {
+		level := n.page.Load().Level
+
+		/* Nodes of the level must be exactly the children of the level above, in the same order. */
+		var nodes []*blinkNode[K, V]
+		var next []*blinkNode[K, V]
+		var prev *blinkPage[K, V]
+		for m := n; m != nil; m = m.page.Load().Right {
+			p := m.page.Load()
+			nodes = append(nodes, m)
+
+			if p.Level != level {
+				t.Errorf("node %v on level %d, expected %d", p.Keys, p.Level, level)
+			}
+			if len(p.Keys) >= bt.Order {
+				t.Errorf("node %v has more than %d keys", p.Keys, bt.Order-1)
+			}
+			for i := 1; i < len(p.Keys); i++ {
+				if p.Keys[i-1] >= p.Keys[i] {
+					t.Errorf("keys %v are not sorted", p.Keys)
+				}
+			}
+			if (p.Right != nil) && (len(p.Keys) > 0) && (p.Keys[len(p.Keys)-1] >= p.High) {
+				t.Errorf("keys %v are not less than high key %v", p.Keys, p.High)
+			}
+			if (prev != nil) && (len(p.Keys) > 0) && (p.Keys[0] < prev.High) {
+				t.Errorf("keys %v are less than high key %v of left sibling", p.Keys, prev.High)
+			}
+			prev = p
+
+			if level == 0 {
+				count += len(p.Keys)
+				continue
+			}
+			if len(p.Children) != len(p.Keys)+1 {
+				t.Errorf("node %v has %d children", p.Keys, len(p.Children))
+				continue
+			}
+			for i, c := range p.Children {
+				cp := c.page.Load()
+				if (i < len(p.Keys)) && ((cp.Right == nil) || (cp.High != p.Keys[i])) {
+					t.Errorf("child %d of %v has high key %v", i, p.Keys, cp.High)
+				}
+			}
+			next = append(next, p.Children...)
+		}
+
+		if (children != nil) && (!slices.Equal(nodes, children)) {
+			t.Errorf("level %d has %d nodes, its parents have %d children", level, len(nodes), len(children))
+		}
+		children = next
+
+		if level == 0 {
+			break
+		}
+		n = n.page.Load().Children[0]
+	}
+
+	return count
+}
+
+func testBlinkTree(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var bt BlinkTree[int, int]
+	bt.Order = order
+
+	m := make(map[int]int)
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		bt.Set(k, i)
+		m[k] = i
+		keys = append(keys, k)
+	}
+	if n := checkBlink(t, &bt); n != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), n)
+	}
+
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+
+	for i := 0; i < len(keys); i += 2 {
+		bt.Del(keys[i])
+		delete(m, keys[i])
+	}
+	if n := checkBlink(t, &bt); n != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), n)
+	}
+
+	for _, k := range keys {
+		_, ok := m[k]
+		if got := bt.Has(k); got != ok {
+			t.Errorf("expected presence of key %v to be %v, got %v", k, ok, got)
+		}
+	}
+
+	expected := make([]int, 0, len(m))
+	for k := range m {
+		expected = append(expected, k)
+	}
+	slices.Sort(expected)
+
+	var got []int
+	for k, v := range bt.All() {
+		if v != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, v)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+
+	bt.Clear()
+	for k := range bt.All() {
+		t.Errorf("expected empty tree, found key %v", k)
+	}
+}
+
+func TestBlinkTree(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testBlinkTree(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+/* Stress test for 'go test -race': workers share the tree and compare results with a map under a mutex, while a scanner checks key order. */
+func TestBlinkTreeStress(t *testing.T) {
+	const (
+		Workers = 8
+		Keys    = 512
+	)
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder}
+
+	for _, order := range orders {
+		t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+			var bt BlinkTree[int, int]
+			bt.Order = order
+
+			/* Each key is owned by one worker, so expected state of the key is known to it exactly. */
+			var mu sync.Mutex
+			m := make(map[int]int)
+
+			done := make(chan struct{})
+			var scanner sync.WaitGroup
+			scanner.Add(1)
+			go func() {
+				defer scanner.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					prev := -1
+					for k := range bt.All() {
+						if k <= prev {
+							t.Errorf("key %v after %v", k, prev)
+						}
+						prev = k
+					}
+				}
+			}()
+
+			var wg sync.WaitGroup
+			for w := 0; w < Workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+
+					rng := rand.New(rand.NewSource(constants.Seed + int64(w)))
+					for i := 0; i < constants.N; i++ {
+						k := rng.Intn(Keys)*Workers + w
+						switch rng.Intn(3) {
+						case 0:
+							bt.Set(k, i)
+							mu.Lock()
+							m[k] = i
+							mu.Unlock()
+						case 1:
+							bt.Del(k)
+							mu.Lock()
+							delete(m, k)
+							mu.Unlock()
+						case 2:
+							mu.Lock()
+							v, ok := m[k]
+							mu.Unlock()
+							if got := bt.Has(k); got != ok {
+								t.Errorf("expected presence of key %v to be %v, got %v", k, ok, got)
+							}
+							if got := bt.Get(k); got != v {
+								t.Errorf("expected value %v for key %v, got %v", v, k, got)
+							}
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+			close(done)
+			scanner.Wait()
+
+			if n := checkBlink(t, &bt); n != len(m) {
+				t.Errorf("expected %d keys, got %d", len(m), n)
+			}
+			for k, v := range m {
+				if got := bt.Get(k); got != v {
+					t.Errorf("expected value %v for key %v, got %v", v, k, got)
+				}
+			}
+		})
+	}
+}
@@ -3,47 +3,144 @@ package bplus
 import (
 	"cmp"
 	"fmt"
+	"slices"
 	"strings"
 
+	"codec"
+	"compare"
+	"pager"
+	"wal"
+
 	"github.com/anton2920/gofa/util"
 )
 
 type Page interface{}
 
-type Node[K cmp.Ordered] struct {
+type Node[K any] struct {
 	Keys       []K
 	Children   []Page
 	ChildPage0 Page
+
+	/* Number of keys in the subtree, see Rank. */
+	size int
+
+	ID    pager.PageID
+	dirty bool
+	gen   uint64
 }
 
-type Leaf[K cmp.Ordered, V any] struct {
+type Leaf[K any, V any] struct {
 	Keys   []K
 	Values []V
 
+	/* Neighbouring leaves, if they are in memory. Leaves read from Store are not linked, see Tree.Begin. */
 	Prev *Leaf[K, V]
 	Next *Leaf[K, V]
+
+	ID    pager.PageID
+	dirty bool
+	gen   uint64
+
+	/* Chains of overflow pages with large values of the stored version of the leaf, see encodeLeaf. */
+	overflow []overflowChain
 }
 
-type PathItem[K cmp.Ordered] struct {
+type PathItem[K any] struct {
 	Node  *Node[K]
 	Index int
 }
 
-type Tree[K cmp.Ordered, V any] struct {
+type Tree[K any, V any] struct {
 	Root Page
 
+	/* Pages hold up to Order-1 keys. Leaves of raw keys in a tree with Store hold as many pairs as fit into a page instead, see sized. */
 	Order int
 
+	/* Order of keys, compare.Default is used if it is not set. See New and NewFunc. */
+	Compare func(a, b K) int
+
+	/*
+	 * Separator returns key 's' such that a < s <= b for the last key 'a' of a leaf and the first key 'b' of the next
+	 * one, which goes into the parent node. Shorter separators of string keys take less space in nodes and let more
+	 * of them fit into a page. 'b' itself is used if Separator is not set, see also ShortestSeparator.
+	 */
+	Separator func(a, b K) K
+
+	/* Page search functions, see initSearch. */
+	findLeafLinear func(*Leaf[K, V], K) (int, bool)
+	findLeafBinary func(*Leaf[K, V], K) (int, bool)
+	findNodeLinear func(*Node[K], K) int
+	findNodeBinary func(*Node[K], K) int
+	binaryOrder    int
+	findValue      func(*Tree[K, V], K) (V, bool)
+
+	/* How keys are searched within pages. */
+	Search SearchMode
+
 	SearchPath []PathItem[K]
 
+	/* Positions of batch keys within a leaf, see SetMany and DelMany. */
+	positions []int
+
+	/*
+	 * Optional on-disk storage, see Flush and Load. Pages are read from it when they are needed, so with buffer pool
+	 * the tree takes memory of the pool and of pages modified since the last Flush. Reading a leaf with values in
+	 * overflow pages pins two frames of the pool at once.
+	 */
+	Store      pager.Store
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+	rawKeys    codec.Raw[K]
+	freed      []pager.PageID
+	pageBuf    []byte
+	readBuf    []byte
+	keyBuf     []byte
+	sizes      []int
+	valueBuf   []byte
+	chainBuf   []byte
+
+	/* Optional write-ahead log, see Recover. */
+	Log      *wal.Log
+	txn      uint64
+	batch    bool
+	batchErr error
+	undo     []undo[K, V]
+	logBuf   []byte
+	err      error
+
+	/* Pages of older generations are shared with snapshots and must be copied before modification, see Snapshot. */
+	gen uint64
+
 	/* Sentinel elements for doubly-linked list of leaves, used for iterators. */
 	endSentinel  Leaf[K, V]
 	rendSentinel Leaf[K, V]
 }
 
+type SearchMode int
+
+const (
+	/*
+	 * SearchAuto picks binary search for orders of at least BinarySearchOrder, or OrderedBinarySearchOrder for keys
+	 * compared directly, and linear search otherwise.
+	 */
+	SearchAuto SearchMode = iota
+	SearchLinear
+	SearchBinary
+)
+
 const DefaultOrder = 46
 
-func findOnLeaf[K cmp.Ordered, V any](l *Leaf[K, V], key K) (int, bool) {
+/* BinarySearchOrder is the smallest order for which binary search within pages beats linear one, see BenchmarkBplusSearch. */
+const BinarySearchOrder = 24
+
+/*
+ * OrderedBinarySearchOrder is BinarySearchOrder of keys compared directly instead of calling Compare. Binary search of
+ * such keys wins in BenchmarkBplusSearch only because its few keys make branches predictable, Get of random keys in
+ * BenchmarkBplus is faster with linear search up to this order.
+ */
+const OrderedBinarySearchOrder = 192
+
+func findOnLeafLinear[K cmp.Ordered, V any](l *Leaf[K, V], key K) (int, bool) {
 	if len(l.Keys) == 0 {
 		return -1, false
 	} else if key >= l.Keys[len(l.Keys)-1] {
@@ -58,7 +155,20 @@ func findOnLeaf[K cmp.Ordered, V any](l *Leaf[K, V], key K) (int, bool) {
 	return len(l.Keys) - 1, false
 }
 
-func findOnNode[K cmp.Ordered](n *Node[K], key K) int {
+func findOnLeafBinary[K cmp.Ordered, V any](l *Leaf[K, V], key K) (int, bool) {
+	i, j := 0, len(l.Keys)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if l.Keys[h] < key {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i - 1, (i < len(l.Keys)) && (l.Keys[i] == key)
+}
+
+func findOnNodeLinear[K cmp.Ordered](n *Node[K], key K) int {
 	if key >= n.Keys[len(n.Keys)-1] {
 		return len(n.Keys) - 1
 	}
@@ -70,92 +180,86 @@ func findOnNode[K cmp.Ordered](n *Node[K], key K) int {
 	return len(n.Keys) - 1
 }
 
-/*
-func findOnLeaf(leaf *Leaf, key K) (int, bool) {
-	if len(leaf.Keys) == 0 {
-		return -1, false
+func findOnNodeBinary[K cmp.Ordered](n *Node[K], key K) int {
+	i, j := 0, len(n.Keys)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if n.Keys[h] <= key {
+			i = h + 1
+		} else {
+			j = h
+		}
 	}
+	return i - 1
+}
 
-	cmp := key.Compare(leaf.Keys[0])
-	if cmp <= 0 {
-		return -1, cmp == 0
+func findOnLeafLinearFunc[K any, V any](l *Leaf[K, V], key K, compare func(a, b K) int) (int, bool) {
+	if len(l.Keys) == 0 {
+		return -1, false
+	} else if c := compare(key, l.Keys[len(l.Keys)-1]); c >= 0 {
+		eq := c == 0
+		return len(l.Keys) - 1 - util.Bool2Int(eq), eq
 	}
-
-	cmp = key.Compare(leaf.Keys[len(leaf.Keys)-1])
-	if cmp >= 0 {
-		eq := cmp == 0
-		return len(leaf.Keys) - 1 - util.Bool2Int(eq), eq
+	for i := 0; i < len(l.Keys); i++ {
+		if c := compare(key, l.Keys[i]); c <= 0 {
+			return i - 1, c == 0
+		}
 	}
+	return len(l.Keys) - 1, false
+}
 
-	l := 1
-	r := len(leaf.Keys) - 2
-	for {
-		k := (l + r) / 2
-
-		eq := key.Compare(leaf.Keys[k])
-		if eq >= 0 {
-			l = k + 1
-		}
-		if eq <= 0 {
-			r = k - 1
-		}
-		if l > r {
-			break
+func findOnLeafBinaryFunc[K any, V any](l *Leaf[K, V], key K, compare func(a, b K) int) (int, bool) {
+	i, j := 0, len(l.Keys)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if compare(l.Keys[h], key) < 0 {
+			i = h + 1
+		} else {
+			j = h
 		}
 	}
-
-	return r, l-r > 1
+	return i - 1, (i < len(l.Keys)) && (compare(l.Keys[i], key) == 0)
 }
 
-func findOnNode(node *Node, key K) int {
-	if key.Compare(node.Keys[0]) < 0 {
-		return -1
+func findOnNodeLinearFunc[K any](n *Node[K], key K, compare func(a, b K) int) int {
+	if compare(key, n.Keys[len(n.Keys)-1]) >= 0 {
+		return len(n.Keys) - 1
 	}
-	if key.Compare(node.Keys[len(node.Keys)-1]) >= 0 {
-		return len(node.Keys) - 1
+	for i := 0; i < len(n.Keys); i++ {
+		if compare(key, n.Keys[i]) < 0 {
+			return i - 1
+		}
 	}
+	return len(n.Keys) - 1
+}
 
-	l := 1
-	r := len(node.Keys) - 2
-	for {
-		k := (l + r) / 2
-
-		cmp := key.Compare(node.Keys[k])
-		if cmp >= 0 {
-			l = k + 1
-		}
-		if cmp < 0 {
-			r = k - 1
-		}
-		if l > r {
-			break
+func findOnNodeBinaryFunc[K any](n *Node[K], key K, compare func(a, b K) int) int {
+	i, j := 0, len(n.Keys)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if compare(n.Keys[h], key) <= 0 {
+			i = h + 1
+		} else {
+			j = h
 		}
 	}
-
-	return r
+	return i - 1
 }
-*/
 
 func insertAtIndex[T any](vs []T, v T, i int) []T {
-	vs = vs[:len(vs)+1]
+	vs = append(vs, v)
 	copy(vs[i+1:], vs[i:])
 	vs[i] = v
 	return vs
 }
 
-func mergeLeaves[K cmp.Ordered, V any](self *Leaf[K, V], other *Leaf[K, V]) *Leaf[K, V] {
-	l := len(self.Keys)
-
-	self.Keys = self.Keys[:l+len(other.Keys)]
-	copy(self.Keys[l:], other.Keys)
-
-	self.Values = self.Values[:l+len(other.Values)]
-	copy(self.Values[l:], other.Values)
-
+func mergeLeaves[K any, V any](self *Leaf[K, V], other *Leaf[K, V]) *Leaf[K, V] {
+	self.Keys = append(self.Keys, other.Keys...)
+	self.Values = append(self.Values, other.Values...)
 	return self
 }
 
-func mergeNodes[K cmp.Ordered](self *Node[K], other *Node[K]) *Node[K] {
+func mergeNodes[K any](self *Node[K], other *Node[K]) *Node[K] {
 	l := len(self.Keys)
 
 	self.Keys = self.Keys[:l+len(other.Keys)]
@@ -172,27 +276,199 @@ func removeAtIndex[T any](vs []T, i int) []T {
 	return vs[:len(vs)-1]
 }
 
+func (t *Tree[K, V]) dispose(page Page) {
+	var id pager.PageID
+
+	switch page := page.(type) {
+	case *Node[K]:
+		id = page.ID
+	case *Leaf[K, V]:
+		id = page.ID
+		for _, chain := range page.overflow {
+			t.freed = append(t.freed, chain.Pages...)
+		}
+	}
+	if id != pager.InvalidPage {
+		t.freed = append(t.freed, id)
+	}
+}
+
+func (t *Tree[K, V]) disposeAll(page Page) {
+	if node, ok := page.(*Node[K]); ok {
+		for i := -1; i < len(node.Children); i++ {
+			t.disposeAll(t.child(node, i))
+		}
+	}
+	t.dispose(page)
+}
+
 func (t *Tree[K, V]) newNode(l int) *Node[K] {
-	return &Node[K]{Keys: make([]K, l, t.Order), Children: make([]Page, l, t.Order)}
+	return &Node[K]{Keys: make([]K, l, t.Order), Children: make([]Page, l, t.Order), gen: t.gen}
 }
 
 func (t *Tree[K, V]) newLeaf(l int) *Leaf[K, V] {
-	return &Leaf[K, V]{Keys: make([]K, l, t.Order), Values: make([]V, l, t.Order)}
+	return &Leaf[K, V]{Keys: make([]K, l, max(l, t.Order)), Values: make([]V, l, max(l, t.Order)), gen: t.gen}
+}
+
+/*
+ * ShortestSeparator returns the shortest prefix of 'b' greater than 'a', or 'b' if 'a' is not less than 'b'. It is
+ * the default Separator of trees of strings and byte slices ordered by default.
+ */
+func ShortestSeparator[K ~string | ~[]byte](a, b K) K {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] > b[i] {
+			return b
+		} else if a[i] < b[i] {
+			return b[:i+1]
+		}
+	}
+	if len(a) >= len(b) {
+		return b
+	}
+	return b[:n+1]
+}
+
+/* New returns tree ordering keys with cmp.Compare. */
+func New[K cmp.Ordered, V any]() *Tree[K, V] {
+	t := &Tree[K, V]{Compare: cmp.Compare[K]}
+	orderedSearch(t)
+	t.defaultSeparator()
+	return t
+}
+
+/* NewFunc returns tree ordering keys with 'compare'. */
+func NewFunc[K any, V any](compare func(a, b K) int) *Tree[K, V] {
+	return &Tree[K, V]{Compare: compare}
+}
+
+/* orderedSearch makes tree search pages with functions which compare keys directly instead of calling Compare. */
+func orderedSearch[K cmp.Ordered, V any](t *Tree[K, V]) {
+	t.findLeafLinear = findOnLeafLinear[K, V]
+	t.findLeafBinary = findOnLeafBinary[K, V]
+	t.findNodeLinear = findOnNodeLinear[K]
+	t.findNodeBinary = findOnNodeBinary[K]
+	t.binaryOrder = OrderedBinarySearchOrder
+	t.findValue = lookupOrdered[K, V]
+}
+
+/* defaultSeparator sets ShortestSeparator for strings and byte slices, unless Separator is already set. */
+func (t *Tree[K, V]) defaultSeparator() {
+	if t.Separator != nil {
+		return
+	}
+	switch t := any(t).(type) {
+	case *Tree[string, V]:
+		t.Separator = ShortestSeparator[string]
+	case *Tree[[]byte, V]:
+		t.Separator = ShortestSeparator[[]byte]
+	}
+}
+
+/*
+ * initSearch sets Compare to default comparator if it is not set and picks page search functions. Built-in ordered
+ * types with default comparator take the same fast path as trees made by New.
+ */
+func (t *Tree[K, V]) initSearch() {
+	if t.Compare == nil {
+		t.Compare = compare.Default[K]()
+		if t.Compare == nil {
+			panic(fmt.Sprintf("bplus: no default comparator for %T, use NewFunc", *new(K)))
+		}
+
+		switch t := any(t).(type) {
+		case *Tree[int, V]:
+			orderedSearch(t)
+		case *Tree[int8, V]:
+			orderedSearch(t)
+		case *Tree[int16, V]:
+			orderedSearch(t)
+		case *Tree[int32, V]:
+			orderedSearch(t)
+		case *Tree[int64, V]:
+			orderedSearch(t)
+		case *Tree[uint, V]:
+			orderedSearch(t)
+		case *Tree[uint8, V]:
+			orderedSearch(t)
+		case *Tree[uint16, V]:
+			orderedSearch(t)
+		case *Tree[uint32, V]:
+			orderedSearch(t)
+		case *Tree[uint64, V]:
+			orderedSearch(t)
+		case *Tree[uintptr, V]:
+			orderedSearch(t)
+		case *Tree[float32, V]:
+			orderedSearch(t)
+		case *Tree[float64, V]:
+			orderedSearch(t)
+		case *Tree[string, V]:
+			orderedSearch(t)
+		}
+		t.defaultSeparator()
+	}
+	if t.findLeafLinear == nil {
+		t.findLeafLinear = func(l *Leaf[K, V], key K) (int, bool) {
+			return findOnLeafLinearFunc(l, key, t.Compare)
+		}
+		t.findLeafBinary = func(l *Leaf[K, V], key K) (int, bool) {
+			return findOnLeafBinaryFunc(l, key, t.Compare)
+		}
+		t.findNodeLinear = func(n *Node[K], key K) int {
+			return findOnNodeLinearFunc(n, key, t.Compare)
+		}
+		t.findNodeBinary = func(n *Node[K], key K) int {
+			return findOnNodeBinaryFunc(n, key, t.Compare)
+		}
+		t.binaryOrder = BinarySearchOrder
+		t.findValue = lookupFunc[K, V]
+	}
 }
 
 func (t *Tree[K, V]) init() {
 	if t.Order == 0 {
 		t.Order = DefaultOrder
 	}
+	if t.findLeafLinear == nil {
+		t.initSearch()
+	}
+	if t.Store != nil {
+		t.initCodecs()
+	}
 	t.SearchPath = t.SearchPath[:0]
 }
 
-func (t *Tree[K, V]) Begin() *Leaf[K, V] {
-	leaf := t.rendSentinel.Next
-	if leaf == nil {
-		leaf = &t.endSentinel
+func (t *Tree[K, V]) binarySearch() bool {
+	return (t.Search == SearchBinary) || ((t.Search == SearchAuto) && (t.Order >= t.binaryOrder))
+}
+
+/* separator returns key between the last key 'a' of a leaf and the first key 'b' of the next one, see Separator. */
+func (t *Tree[K, V]) separator(a, b K) K {
+	if t.Separator == nil {
+		return b
 	}
-	return leaf
+	return t.Separator(a, b)
+}
+
+/* findOnLeaf returns index of the last key < 'key'. Returns true, if the next key is == 'key'. */
+func (t *Tree[K, V]) findOnLeaf(l *Leaf[K, V], key K) (int, bool) {
+	if t.binarySearch() {
+		return t.findLeafBinary(l, key)
+	}
+	return t.findLeafLinear(l, key)
+}
+
+/* findOnNode returns index of the last key <= 'key', or -1 if 'key' belongs to ChildPage0. */
+func (t *Tree[K, V]) findOnNode(n *Node[K], key K) int {
+	if t.binarySearch() {
+		return t.findNodeBinary(n, key)
+	}
+	return t.findNodeLinear(n, key)
+}
+
+func (t *Tree[K, V]) Begin() *Leaf[K, V] {
+	return t.next(&t.rendSentinel)
 }
 
 func (t *Tree[K, V]) End() *Leaf[K, V] {
@@ -200,11 +476,7 @@ func (t *Tree[K, V]) End() *Leaf[K, V] {
 }
 
 func (t *Tree[K, V]) Rbegin() *Leaf[K, V] {
-	leaf := t.endSentinel.Prev
-	if leaf == nil {
-		leaf = &t.rendSentinel
-	}
-	return leaf
+	return t.prev(&t.endSentinel)
 }
 
 func (t *Tree[K, V]) Rend() *Leaf[K, V] {
@@ -212,94 +484,132 @@ func (t *Tree[K, V]) Rend() *Leaf[K, V] {
 }
 
 func (t *Tree[K, V]) Clear() {
+	if t.Store != nil {
+		t.disposeAll(t.Root)
+	}
 	t.Root = nil
+	t.rendSentinel.Next = nil
+	t.endSentinel.Prev = nil
+}
+
+/* CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==. */
+func CompareAndSwap[K any, V comparable](t *Tree[K, V], key K, old, new V) bool {
+	return t.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+/* CompareAndSwapFunc sets value of 'key' to 'new' if it is present with value 'old' according to 'equal'. */
+func (t *Tree[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
+	t.init()
+
+	leaf, index, ok := t.search(key)
+	if (!ok) || (!equal(leaf.Values[index+1], old)) {
+		return false
+	}
+	return t.setAt(leaf, index, ok, key, new)
 }
 
 func (t *Tree[K, V]) Del(key K) {
 	t.init()
 
-	var leaf *Leaf[K, V]
-	var index int
-	var ok bool
+	leaf, index, ok := t.search(key)
+	if ok {
+		t.delAt(leaf, index, key)
+	}
+}
 
-	page := t.Root
-	for page != nil {
-		switch p := page.(type) {
-		case *Node[K]:
-			index = findOnNode[K](p, key)
-			if index == -1 {
-				page = p.ChildPage0
-			} else {
-				page = p.Children[index]
-			}
-			t.SearchPath = append(t.SearchPath, PathItem[K]{Node: p, Index: index})
-		case *Leaf[K, V]:
-			index, ok = findOnLeaf[K, V](p, key)
-			if !ok {
-				return
-			}
-			leaf = p
-			page = nil
-		}
+/* delAt logs and deletes 'key', which search found after 'index' of 'leaf'. */
+func (t *Tree[K, V]) delAt(leaf *Leaf[K, V], index int, key K) {
+	if !t.logDel(key, leaf.Values[index+1]) {
+		return
 	}
+	t.remove(t.ownPath(leaf), index+1)
+}
 
-	/* Remove key. */
-	half := t.Order/2 - (1 - t.Order%2)
-	leaf.Keys = removeAtIndex(leaf.Keys, index+1)
-	leaf.Values = removeAtIndex(leaf.Values, index+1)
-	if (len(leaf.Keys) >= half) || (leaf == t.Root) {
+/* remove deletes pair at 'pos' of 'leaf', which SearchPath leads to, merging pages as needed. */
+func (t *Tree[K, V]) remove(leaf *Leaf[K, V], pos int) {
+	for i := 0; i < len(t.SearchPath); i++ {
+		t.SearchPath[i].Node.size--
+	}
+	leaf.Keys = removeAtIndex(leaf.Keys, pos)
+	leaf.Values = removeAtIndex(leaf.Values, pos)
+	leaf.dirty = true
+	t.rebalance(leaf)
+}
+
+/*
+ * rebalance refills underfull 'leaf', which SearchPath leads to, from its neighbour or merges them, merging nodes
+ * above as needed. Leaf may lack any number of keys, while nodes may lack one at most.
+ */
+func (t *Tree[K, V]) rebalance(leaf *Leaf[K, V]) {
+	if (leaf == t.Root) || (!t.underfull(leaf)) {
 		return
 	}
+	half := t.Order/2 - (1 - t.Order%2)
 
 	rootNode := t.SearchPath[len(t.SearchPath)-1].Node
-	index = t.SearchPath[len(t.SearchPath)-1].Index
+	index := t.SearchPath[len(t.SearchPath)-1].Index
+	rootNode.dirty = true
 	if index < len(rootNode.Keys)-1 {
-		rightLeaf := leaf.Next
-		k := (len(rightLeaf.Keys) - half + 1) / 2
-		if k > 0 {
-			leaf.Keys = leaf.Keys[:len(leaf.Keys)+k]
+		rightLeaf, ok := t.child(rootNode, index+1).(*Leaf[K, V])
+		if !ok {
+			return
+		}
+		rightLeaf = t.ownLeaf(rightLeaf)
+		rootNode.Children[index+1] = rightLeaf
+		rightLeaf.dirty = true
+		if k := t.share(leaf, rightLeaf, true); k >= 0 {
+			leaf.Keys = slices.Grow(leaf.Keys, k)[:len(leaf.Keys)+k]
 			copy(leaf.Keys[len(leaf.Keys)-k:], rightLeaf.Keys[:k])
 			copy(rightLeaf.Keys, rightLeaf.Keys[k:])
 			rightLeaf.Keys = rightLeaf.Keys[:len(rightLeaf.Keys)-k]
 
-			leaf.Values = leaf.Values[:len(leaf.Values)+k]
+			leaf.Values = slices.Grow(leaf.Values, k)[:len(leaf.Values)+k]
 			copy(leaf.Values[len(leaf.Values)-k:], rightLeaf.Values[:k])
 			copy(rightLeaf.Values, rightLeaf.Values[k:])
 			rightLeaf.Values = rightLeaf.Values[:len(rightLeaf.Values)-k]
 
-			rootNode.Keys[index+1] = rightLeaf.Keys[0]
+			rootNode.Keys[index+1] = t.separator(leaf.Keys[len(leaf.Keys)-1], rightLeaf.Keys[0])
 			return
 		} else {
 			leaf = mergeLeaves(leaf, rightLeaf)
 			rootNode.Keys = removeAtIndex(rootNode.Keys, index+1)
 			rootNode.Children = removeAtIndex(rootNode.Children, index+1)
 			leaf.Next = rightLeaf.Next
-			leaf.Next.Prev = leaf
-			/* dispose(rightLeaf) */
+			if leaf.Next != nil {
+				leaf.Next.Prev = leaf
+			}
+			t.dispose(rightLeaf)
 		}
 	} else {
-		leftLeaf := leaf.Prev
-		k := (len(leftLeaf.Keys) - half + 1) / 2
-		if k > 0 {
-			leaf.Keys = leaf.Keys[:len(leaf.Keys)+k]
+		leftLeaf, ok := t.child(rootNode, index-1).(*Leaf[K, V])
+		if !ok {
+			return
+		}
+		leftLeaf = t.ownLeaf(leftLeaf)
+		t.replaceChild(rootNode, index-1, leftLeaf)
+		leftLeaf.dirty = true
+		if k := t.share(leaf, leftLeaf, false); k >= 0 {
+			leaf.Keys = slices.Grow(leaf.Keys, k)[:len(leaf.Keys)+k]
 			copy(leaf.Keys[k:], leaf.Keys)
 			copy(leaf.Keys, leftLeaf.Keys[len(leftLeaf.Keys)-k:])
 			leftLeaf.Keys = leftLeaf.Keys[:len(leftLeaf.Keys)-k]
 
-			leaf.Values = leaf.Values[:len(leaf.Values)+k]
+			leaf.Values = slices.Grow(leaf.Values, k)[:len(leaf.Values)+k]
 			copy(leaf.Values[k:], leaf.Values)
 			copy(leaf.Values, leftLeaf.Values[len(leftLeaf.Values)-k:])
 			leftLeaf.Values = leftLeaf.Values[:len(leftLeaf.Values)-k]
 
-			rootNode.Keys[index] = leaf.Keys[0]
+			rootNode.Keys[index] = t.separator(leftLeaf.Keys[len(leftLeaf.Keys)-1], leaf.Keys[0])
 			return
 		} else {
 			leftLeaf = mergeLeaves(leftLeaf, leaf)
 			rootNode.Keys = removeAtIndex(rootNode.Keys, index)
 			rootNode.Children = removeAtIndex(rootNode.Children, index)
 			leftLeaf.Next = leaf.Next
-			leftLeaf.Next.Prev = leftLeaf
-			/* dispose(leaf) */
+			if leftLeaf.Next != nil {
+				leftLeaf.Next.Prev = leftLeaf
+			}
+			t.dispose(leaf)
 		}
 	}
 
@@ -312,8 +622,15 @@ func (t *Tree[K, V]) Del(key K) {
 
 		rootNode = t.SearchPath[p].Node
 		index := t.SearchPath[p].Index
+		rootNode.dirty = true
 		if index < len(rootNode.Keys)-1 {
-			rightNode := rootNode.Children[index+1].(*Node[K])
+			rightNode, ok := t.child(rootNode, index+1).(*Node[K])
+			if !ok {
+				return
+			}
+			rightNode = t.ownNode(rightNode)
+			rootNode.Children[index+1] = rightNode
+			rightNode.dirty = true
 			k := (len(rightNode.Keys) - half + 1) / 2
 			if k > 0 {
 				newKey := rightNode.Keys[k-1]
@@ -332,23 +649,27 @@ func (t *Tree[K, V]) Del(key K) {
 				rightNode.Children = rightNode.Children[:len(rightNode.Children)-k]
 
 				rootNode.Keys[index+1] = newKey
+				t.recount(node)
+				t.recount(rightNode)
 				return
 			} else {
 				node.Keys = append(node.Keys, rootNode.Keys[index+1])
 				node.Children = append(node.Children, rightNode.ChildPage0)
 
 				node = mergeNodes(node, rightNode)
+				node.size += rightNode.size
 				rootNode.Keys = removeAtIndex(rootNode.Keys, index+1)
 				rootNode.Children = removeAtIndex(rootNode.Children, index+1)
-				/* dispose(rightNode) */
+				t.dispose(rightNode)
 			}
 		} else {
-			var leftNode *Node[K]
-			if index == 0 {
-				leftNode = rootNode.ChildPage0.(*Node[K])
-			} else {
-				leftNode = rootNode.Children[index-1].(*Node[K])
+			leftNode, ok := t.child(rootNode, index-1).(*Node[K])
+			if !ok {
+				return
 			}
+			leftNode = t.ownNode(leftNode)
+			t.replaceChild(rootNode, index-1, leftNode)
+			leftNode.dirty = true
 
 			k := (len(leftNode.Keys) - half + 1) / 2
 			if k > 0 {
@@ -368,15 +689,18 @@ func (t *Tree[K, V]) Del(key K) {
 				leftNode.Children = leftNode.Children[:len(leftNode.Children)-k]
 
 				rootNode.Keys[index] = newKey
+				t.recount(node)
+				t.recount(leftNode)
 				return
 			} else {
 				leftNode.Keys = append(leftNode.Keys, rootNode.Keys[index])
 				leftNode.Children = append(leftNode.Children, node.ChildPage0)
 
 				leftNode = mergeNodes(leftNode, node)
+				leftNode.size += node.size
 				rootNode.Keys = removeAtIndex(rootNode.Keys, index)
 				rootNode.Children = removeAtIndex(rootNode.Children, index)
-				/* dispose(node) */
+				t.dispose(node)
 			}
 		}
 	}
@@ -384,62 +708,172 @@ func (t *Tree[K, V]) Del(key K) {
 	rootNode = t.Root.(*Node[K])
 	if len(rootNode.Keys) == 0 {
 		t.Root = rootNode.ChildPage0
+		t.dispose(rootNode)
 	}
 }
 
 func (t *Tree[K, V]) Get(key K) V {
-	var v V
+	t.init()
+
+	v, _ := t.lookup(key)
+	return v
+}
 
+/* GetOrSet returns value of 'key' and true if it is present, otherwise it sets 'key' to 'value'. */
+func (t *Tree[K, V]) GetOrSet(key K, value V) (V, bool) {
+	t.init()
+
+	leaf, index, ok := t.search(key)
+	if ok {
+		return leaf.Values[index+1], true
+	}
+	t.setAt(leaf, index, ok, key, value)
+	return value, false
+}
+
+func (t *Tree[K, V]) Has(key K) bool {
 	t.init()
 
 	page := t.Root
 	for page != nil {
 		switch p := page.(type) {
 		case *Node[K]:
-			index := findOnNode[K](p, key)
-			if index == -1 {
-				page = p.ChildPage0
-			} else {
-				page = p.Children[index]
-			}
+			page = t.child(p, t.findOnNode(p, key))
 		case *Leaf[K, V]:
-			index, ok := findOnLeaf[K, V](p, key)
+			_, ok := t.findOnLeaf(p, key)
+			return ok
+		}
+	}
+
+	return false
+}
+
+/* Lookup returns value of 'key' and whether it is present. */
+func (t *Tree[K, V]) Lookup(key K) (V, bool) {
+	t.init()
+
+	return t.lookup(key)
+}
+
+/*
+ * lookup is Lookup of initialized tree. It does not modify the tree, so without Store it may run concurrently with
+ * itself, as in View and VersionedTree. With Store pages are read into a shared buffer.
+ */
+func (t *Tree[K, V]) lookup(key K) (V, bool) {
+	return t.findValue(t, key)
+}
+
+/* lookupFunc is lookup of keys compared with Compare. */
+func lookupFunc[K any, V any](t *Tree[K, V], key K) (V, bool) {
+	var v V
+
+	page := t.Root
+	for page != nil {
+		switch p := page.(type) {
+		case *Node[K]:
+			page = t.child(p, t.findOnNode(p, key))
+		case *Leaf[K, V]:
+			index, ok := t.findOnLeaf(p, key)
 			if ok {
-				v = p.Values[index+1]
+				return p.Values[index+1], true
 			}
 			page = nil
 		}
 	}
 
-	return v
+	return v, false
 }
 
-func (t *Tree[K, V]) Has(key K) bool {
-	t.init()
+/*
+ * lookupOrdered is lookup of keys compared directly. It calls page search functions by name, so that they are inlined
+ * like in trees before comparators, and reads child from Store only when it meets a reference to it.
+ */
+func lookupOrdered[K cmp.Ordered, V any](t *Tree[K, V], key K) (V, bool) {
+	var v V
 
+	binary := t.binarySearch()
 	page := t.Root
 	for page != nil {
 		switch p := page.(type) {
 		case *Node[K]:
-			index := findOnNode[K](p, key)
+			var index int
+			if binary {
+				index = findOnNodeBinary(p, key)
+			} else {
+				index = findOnNodeLinear(p, key)
+			}
 			if index == -1 {
 				page = p.ChildPage0
 			} else {
 				page = p.Children[index]
 			}
 		case *Leaf[K, V]:
-			_, ok := findOnLeaf[K, V](p, key)
-			return ok
+			var index int
+			var ok bool
+			if binary {
+				index, ok = findOnLeafBinary(p, key)
+			} else {
+				index, ok = findOnLeafLinear(p, key)
+			}
+			if ok {
+				return p.Values[index+1], true
+			}
+			page = nil
+		case *pageRef:
+			page = t.readChild(p)
 		}
 	}
 
-	return false
+	return v, false
+}
+
+/*
+ * search descends to the leaf where 'key' is or would be, filling SearchPath. Returns the leaf, which is nil in empty
+ * tree or if reading from Store has failed, index of the last key < 'key' in it and whether the next one is 'key'.
+ */
+func (t *Tree[K, V]) search(key K) (*Leaf[K, V], int, bool) {
+	return t.searchFrom(t.Root, key)
+}
+
+/* searchFrom is search starting at 'page', which SearchPath leads to. */
+func (t *Tree[K, V]) searchFrom(page Page, key K) (*Leaf[K, V], int, bool) {
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			index := t.findOnNode(p, key)
+			page = t.child(p, index)
+			t.SearchPath = append(t.SearchPath, PathItem[K]{Node: p, Index: index})
+		case *Leaf[K, V]:
+			index, ok := t.findOnLeaf(p, key)
+			return p, index, ok
+		default:
+			return nil, -1, false
+		}
+	}
 }
 
 func (t *Tree[K, V]) Set(key K, value V) {
 	t.init()
-	if t.Root == nil {
-		leaf := t.newLeaf(1)
+
+	leaf, index, ok := t.search(key)
+	t.setAt(leaf, index, ok, key, value)
+}
+
+/* setAt logs and stores pair where search found 'key'. Returns false if logging has failed, see Err. */
+func (t *Tree[K, V]) setAt(leaf *Leaf[K, V], index int, ok bool, key K, value V) bool {
+	var old V
+	if ok {
+		old = leaf.Values[index+1]
+	}
+	if (leaf == nil) && (t.Root != nil) {
+		return false
+	}
+	if !t.logSet(key, value, old, ok) {
+		return false
+	}
+
+	if leaf == nil {
+		leaf = t.newLeaf(1)
 		leaf.Keys[0] = key
 		leaf.Values[0] = value
 		leaf.Prev = &t.rendSentinel
@@ -447,50 +881,46 @@ func (t *Tree[K, V]) Set(key K, value V) {
 		t.Root = leaf
 		t.endSentinel.Prev = leaf
 		t.rendSentinel.Next = leaf
-		return
+		return true
 	}
 
-	var leaf *Leaf[K, V]
-	var index int
-	var ok bool
-
-	page := t.Root
-	for page != nil {
-		switch p := page.(type) {
-		case *Node[K]:
-			index = findOnNode[K](p, key)
-			if index == -1 {
-				page = p.ChildPage0
-			} else {
-				page = p.Children[index]
-			}
-			t.SearchPath = append(t.SearchPath, PathItem[K]{Node: p, Index: index})
-		case *Leaf[K, V]:
-			index, ok = findOnLeaf[K, V](p, key)
-			if ok {
-				/* Update value for existing key. */
-				p.Values[index+1] = value
-				return
-			}
-			leaf = p
-			page = nil
-		}
+	leaf = t.ownPath(leaf)
+	if ok {
+		/* Update value for existing key. */
+		leaf.Values[index+1] = value
+		leaf.dirty = true
+		return true
 	}
+	t.insert(leaf, index+1, key, value)
+	return true
+}
 
+/* insert puts pair at 'pos' of 'leaf', which SearchPath leads to, splitting pages as needed. */
+func (t *Tree[K, V]) insert(leaf *Leaf[K, V], pos int, key K, value V) {
 	half := t.Order / 2
 	var newPage Page
 	newKey := key
 
-	/* Insert new key. */
-	leaf.Keys = insertAtIndex(leaf.Keys, key, index+1)
-	leaf.Values = insertAtIndex(leaf.Values, value, index+1)
+	for i := 0; i < len(t.SearchPath); i++ {
+		t.SearchPath[i].Node.size++
+	}
+	leaf.Keys = insertAtIndex(leaf.Keys, key, pos)
+	leaf.Values = insertAtIndex(leaf.Values, value, pos)
+	leaf.dirty = true
+	if t.sized() {
+		/* Leaf sized in bytes may split into more than two pages, see splitLeaf. */
+		pages, seps := t.splitLeaf(leaf)
+		t.attach(len(t.SearchPath)-1, pages, seps)
+		t.splitPath()
+		return
+	}
 	if len(leaf.Keys) < t.Order {
 		return
 	}
 
 	/* Split leaf into two. */
 	newLeaf := t.newLeaf(half + (t.Order % 2))
-	newKey = leaf.Keys[half]
+	newKey = t.separator(leaf.Keys[half-1], leaf.Keys[half])
 	newPage = newLeaf
 
 	copy(newLeaf.Keys, leaf.Keys[half:])
@@ -501,7 +931,9 @@ func (t *Tree[K, V]) Set(key K, value V) {
 
 	newLeaf.Prev = leaf
 	newLeaf.Next = leaf.Next
-	newLeaf.Next.Prev = newLeaf
+	if newLeaf.Next != nil {
+		newLeaf.Next.Prev = newLeaf
+	}
 	leaf.Next = newLeaf
 
 	/* Update indexing structure. */
@@ -511,6 +943,7 @@ func (t *Tree[K, V]) Set(key K, value V) {
 
 		node.Keys = insertAtIndex(node.Keys, newKey, index+1)
 		node.Children = insertAtIndex(node.Children, newPage, index+1)
+		node.dirty = true
 		if len(node.Keys) < t.Order {
 			return
 		}
@@ -526,6 +959,9 @@ func (t *Tree[K, V]) Set(key K, value V) {
 		newNode.ChildPage0 = node.Children[half]
 		copy(newNode.Children, node.Children[half+1:])
 		node.Children = node.Children[:half]
+
+		t.recount(newNode)
+		node.size -= newNode.size
 	}
 
 	tmp := t.Root
@@ -533,9 +969,24 @@ func (t *Tree[K, V]) Set(key K, value V) {
 	node.Keys[0] = newKey
 	node.ChildPage0 = tmp
 	node.Children[0] = newPage
+	node.size = pageSize[K, V](tmp) + pageSize[K, V](newPage)
 	t.Root = node
 }
 
+/* Swap sets 'key' to 'value' and returns the previous value and whether there was one. */
+func (t *Tree[K, V]) Swap(key K, value V) (V, bool) {
+	var old V
+
+	t.init()
+
+	leaf, index, ok := t.search(key)
+	if ok {
+		old = leaf.Values[index+1]
+	}
+	t.setAt(leaf, index, ok, key, value)
+	return old, ok
+}
+
 func (t *Tree[K, V]) stringImpl(sb *strings.Builder, page Page, level int) {
 	if page != nil {
 		for i := 0; i < level; i++ {
@@ -548,9 +999,8 @@ func (t *Tree[K, V]) stringImpl(sb *strings.Builder, page Page, level int) {
 			}
 			sb.WriteRune('\n')
 
-			t.stringImpl(sb, page.ChildPage0, level+1)
-			for i := 0; i < len(page.Children); i++ {
-				t.stringImpl(sb, page.Children[i], level+1)
+			for i := -1; i < len(page.Children); i++ {
+				t.stringImpl(sb, t.child(page, i), level+1)
 			}
 		case *Leaf[K, V]:
 			for i := 0; i < len(page.Keys); i++ {
@@ -568,3 +1018,25 @@ func (t Tree[K, V]) String() string {
 
 	return sb.String()
 }
+
+/*
+ * Update calls 'fn' with value of 'key' and whether it is present, then sets 'key' to the returned value or, if 'fn'
+ * returns false, deletes it. 'fn' must not modify the tree.
+ */
+func (t *Tree[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	var old V
+
+	t.init()
+
+	leaf, index, ok := t.search(key)
+	if ok {
+		old = leaf.Values[index+1]
+	}
+	value, keep := fn(old, ok)
+	switch {
+	case keep:
+		t.setAt(leaf, index, ok, key, value)
+	case ok:
+		t.delAt(leaf, index, key)
+	}
+}
@@ -1,7 +1,11 @@
 package bplus
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
 	"testing"
 
 	"constants"
@@ -128,6 +132,91 @@ func TestBplus(t *testing.T) {
 	}
 }
 
+/* TestBplusPointOps runs random point operations against a map. */
+func TestBplusPointOps(t *testing.T) {
+	for _, order := range [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder} {
+		t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+			var bt Tree[int, int]
+			bt.Order = order
+
+			rng := rand.New(rand.NewSource(constants.Seed))
+			m := make(map[int]int)
+			for i := 0; i < constants.N; i++ {
+				k := rng.Intn(constants.N / 8)
+				v, ok := m[k]
+
+				switch rng.Intn(7) {
+				case 0:
+					if got, found := bt.Lookup(k); (got != v) || (found != ok) {
+						t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+					}
+				case 1:
+					if !ok {
+						m[k] = i
+					}
+					if got, loaded := bt.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+						t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+					}
+				case 2:
+					m[k] = i
+					if got, loaded := bt.Swap(k, i); (got != v) || (loaded != ok) {
+						t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+					}
+				case 3:
+					old := v + rng.Intn(2)
+					want := ok && (old == v)
+					if want {
+						m[k] = i
+					}
+					if got := CompareAndSwap(&bt, k, old, i); got != want {
+						t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 4:
+					/* Values of the same parity are equal. */
+					old := rng.Intn(2)
+					want := ok && ((old-v)%2 == 0)
+					if want {
+						m[k] = i
+					}
+					if got := bt.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+						t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 5:
+					/* Every third update deletes the key. */
+					keep := i%3 != 0
+					if keep {
+						m[k] = i
+					} else {
+						delete(m, k)
+					}
+					bt.Update(k, func(got int, found bool) (int, bool) {
+						if (got != v) || (found != ok) {
+							t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+						}
+						return i, keep
+					})
+				case 6:
+					delete(m, k)
+					bt.Del(k)
+				}
+			}
+			checkPages(t, &bt)
+
+			keys := slices.Sorted(maps.Keys(m))
+			var n int
+			for k, v := range bt.All() {
+				if (n >= len(keys)) || (keys[n] != k) || (m[k] != v) {
+					t.Fatalf("All: unexpected pair %v: %v at %d", k, v, n)
+				}
+				n++
+			}
+			if n != len(keys) {
+				t.Errorf("All: expected %d keys, got %d", len(keys), n)
+			}
+		})
+	}
+}
+
 func benchmarkBplusGet(b *testing.B, g generator.Generator, order int) {
 	b.Helper()
 
@@ -205,3 +294,349 @@ func BenchmarkBplus(b *testing.B) {
 		})
 	}
 }
+
+func TestBplusSearch(t *testing.T) {
+	for order := constants.MinOrder; order <= constants.MaxOrder; order += constants.OrderStep {
+		var bt Tree[int, int]
+		bt.Order = order
+
+		leaf := bt.newLeaf(order - 1)
+		node := bt.newNode(order - 1)
+		for i := 0; i < order-1; i++ {
+			leaf.Keys[i] = 2 * i
+			node.Keys[i] = 2 * i
+		}
+
+		for key := -1; key <= 2*order; key++ {
+			li, lok := findOnLeafLinear(leaf, key)
+			bi, bok := findOnLeafBinary(leaf, key)
+			if (li != bi) || (lok != bok) {
+				t.Errorf("Order-%d: leaf search for %v: linear gives (%v, %v), binary gives (%v, %v)", order, key, li, lok, bi, bok)
+			}
+			lfi, lfok := findOnLeafLinearFunc(leaf, key, cmp.Compare[int])
+			bfi, bfok := findOnLeafBinaryFunc(leaf, key, cmp.Compare[int])
+			if (li != lfi) || (lok != lfok) || (li != bfi) || (lok != bfok) {
+				t.Errorf("Order-%d: leaf search for %v: comparator searches give (%v, %v) and (%v, %v), expected (%v, %v)", order, key, lfi, lfok, bfi, bfok, li, lok)
+			}
+			li, bi = findOnNodeLinear(node, key), findOnNodeBinary(node, key)
+			if li != bi {
+				t.Errorf("Order-%d: node search for %v: linear gives %v, binary gives %v", order, key, li, bi)
+			}
+			lfi, bfi = findOnNodeLinearFunc(node, key, cmp.Compare[int]), findOnNodeBinaryFunc(node, key, cmp.Compare[int])
+			if (li != lfi) || (li != bfi) {
+				t.Errorf("Order-%d: node search for %v: comparator searches give %v and %v, expected %v", order, key, lfi, bfi, li)
+			}
+		}
+	}
+}
+
+func benchmarkBplusSearch(b *testing.B, mode SearchMode, order int) {
+	b.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Search = mode
+	bt.init()
+
+	leaf := bt.newLeaf(order - 1)
+	node := bt.newNode(order - 1)
+	for i := 0; i < order-1; i++ {
+		leaf.Keys[i] = 2 * i
+		node.Keys[i] = 2 * i
+	}
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	keys := make([]int, 1024)
+	for i := 0; i < len(keys); i++ {
+		keys[i] = g.Generate() % (2 * order)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		bt.findOnNode(node, key)
+		bt.findOnLeaf(leaf, key)
+	}
+}
+
+/* BenchmarkBplusSearch compares linear and binary search within full pages, it is used to choose BinarySearchOrder. */
+func BenchmarkBplusSearch(b *testing.B) {
+	modes := [...]struct {
+		Name string
+		Mode SearchMode
+	}{
+		{"Linear", SearchLinear},
+		{"Binary", SearchBinary},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.Name, func(b *testing.B) {
+			for order := constants.MinOrder; order <= constants.MaxOrder; order += constants.OrderStep {
+				b.Run(fmt.Sprintf("Order-%d", order), func(b *testing.B) {
+					benchmarkBplusSearch(b, mode.Mode, order)
+				})
+			}
+		})
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func comparePoints(a, b point) int {
+	return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+}
+
+func testBplusFunc(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	bt := NewFunc[point, int](comparePoints)
+	bt.Order = constants.MinOrder
+
+	m := make(map[point]int)
+	for i := 0; i < constants.N; i++ {
+		/* Many keys share X, so that Y decides their order. */
+		x := g.Generate()
+		k := point{X: x % 100, Y: x}
+
+		m[k] = i
+		bt.Set(k, i)
+	}
+
+	check := func() {
+		t.Helper()
+
+		keys := slices.SortedFunc(maps.Keys(m), comparePoints)
+		var i int
+		for k, v := range bt.All() {
+			if (i >= len(keys)) || (k != keys[i]) || (v != m[k]) {
+				t.Errorf("All: unexpected pair %v: %v at %d", k, v, i)
+				return
+			}
+			i++
+		}
+		if i != len(keys) {
+			t.Errorf("All: expected %d keys, got %d", len(keys), i)
+		}
+	}
+	check()
+
+	for k := range m {
+		if k.Y%2 == 0 {
+			bt.Del(k)
+			if bt.Has(k) {
+				t.Errorf("expected key %v to be removed, but it's still present", k)
+			}
+			delete(m, k)
+		}
+	}
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	check()
+}
+
+func TestBplusFunc(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testBplusFunc(t, generator)
+		})
+	}
+}
+
+func benchmarkBplusCompareGet(b *testing.B, bt *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = bt.Get(g.Generate())
+	}
+}
+
+func benchmarkBplusCompareSet(b *testing.B, bt *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+}
+
+/* BenchmarkBplusCompare compares zero value tree and New, which compare keys directly, with NewFunc, which calls comparator. */
+func BenchmarkBplusCompare(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, *Tree[int, int])
+	}{
+		{"Get", benchmarkBplusCompareGet},
+		{"Set", benchmarkBplusCompareSet},
+	}
+
+	trees := [...]struct {
+		Name string
+		New  func() *Tree[int, int]
+	}{
+		{"Zero", func() *Tree[int, int] { return new(Tree[int, int]) }},
+		{"New", New[int, int]},
+		{"Func", func() *Tree[int, int] { return NewFunc[int, int](cmp.Compare[int]) }},
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, tree := range trees {
+				b.Run(tree.Name, func(b *testing.B) {
+					op.Func(b, tree.New())
+				})
+			}
+		})
+	}
+}
+
+func TestShortestSeparator(t *testing.T) {
+	tests := [...]struct {
+		A, B, Expected string
+	}{
+		{"abc", "abz", "abz"},
+		{"abc", "abzzz", "abz"},
+		{"ab", "abc", "abc"},
+		{"ab", "abcd", "abc"},
+		{"", "b", "b"},
+		{"/usr/share/doc/1/19", "/usr/share/doc/2/2", "/usr/share/doc/2"},
+		{"abc", "abc", "abc"},
+		{"b", "a", "a"},
+	}
+
+	for _, test := range tests {
+		if got := ShortestSeparator(test.A, test.B); got != test.Expected {
+			t.Errorf("ShortestSeparator(%q, %q): expected %q, got %q", test.A, test.B, test.Expected, got)
+		}
+		if got := ShortestSeparator([]byte(test.A), []byte(test.B)); string(got) != test.Expected {
+			t.Errorf("ShortestSeparator(%q, %q) of bytes: expected %q, got %q", test.A, test.B, test.Expected, got)
+		}
+	}
+}
+
+/* checkSeparators verifies that keys of every page are within [lo, hi). Returns number of separators and their total length. */
+func checkSeparators(t *testing.T, page Page, lo, hi string, bounded bool) (int, int) {
+	t.Helper()
+
+	switch p := page.(type) {
+	case *Node[string]:
+		n, length := len(p.Keys), 0
+		for i := -1; i < len(p.Keys); i++ {
+			clo, chi, cbounded, child := lo, hi, bounded, p.ChildPage0
+			if i >= 0 {
+				clo, child = p.Keys[i], p.Children[i]
+				length += len(p.Keys[i])
+			}
+			if i+1 < len(p.Keys) {
+				chi, cbounded = p.Keys[i+1], true
+			}
+			cn, clength := checkSeparators(t, child, clo, chi, cbounded)
+			n, length = n+cn, length+clength
+		}
+		return n, length
+	case *Leaf[string, int]:
+		for _, key := range p.Keys {
+			if (key < lo) || ((bounded) && (key >= hi)) {
+				t.Errorf("key %q is out of [%q, %q)", key, lo, hi)
+			}
+		}
+	}
+	return 0, 0
+}
+
+func testBplusSeparator(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var bt Tree[string, int]
+	bt.Order = order
+
+	var length int
+	m := make(map[string]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		key := fmt.Sprintf("/usr/share/doc/%d/%d", k%16, k)
+		if _, ok := m[key]; !ok {
+			length += len(key)
+		}
+
+		m[key] = i
+		bt.Set(key, i)
+	}
+	if n, l := checkSeparators(t, bt.Root, "", "", false); (n > 0) && (l*len(m) >= length*n) {
+		t.Errorf("separators take %d bytes per key, keys take %d", l/n, length/len(m))
+	}
+
+	for k := range m {
+		if k[len(k)-1]%3 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+	}
+	checkSeparators(t, bt.Root, "", "", false)
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	if err := bt.BulkLoad(func(yield func(string, int) bool) {
+		for _, k := range keys {
+			if !yield(k, m[k]) {
+				return
+			}
+		}
+	}, 1); err != nil {
+		t.Fatalf("failed to bulk load: %v", err)
+	}
+	checkSeparators(t, bt.Root, "", "", false)
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func TestBplusSeparator(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		t.Run(generator.String(), func(t *testing.T) {
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					generator.Reset()
+					testBplusSeparator(t, generator, order)
+				})
+			}
+		})
+	}
+}
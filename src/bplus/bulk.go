@@ -0,0 +1,122 @@
+package bplus
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/anton2920/gofa/util"
+)
+
+var (
+	ErrBadFill  = errors.New("fill factor must be in (0, 1]")
+	ErrUnsorted = errors.New("keys are not in strictly ascending order")
+)
+
+/* parts splits 'n' elements into groups of about 'target' elements each, so that every group has from 'lo' to 'hi' elements. Sizes of groups differ at most by one. */
+func parts(n, target, lo, hi int) []int {
+	m := max((n+target-1)/target, 1)
+	for (m > 1) && (n < m*lo) {
+		m--
+	}
+	for n > m*hi {
+		m++
+	}
+
+	sizes := make([]int, m)
+	for i := 0; i < m; i++ {
+		sizes[i] = n/m + util.Bool2Int(i < n%m)
+	}
+	return sizes
+}
+
+/*
+ * BulkLoad replaces contents of the tree with pairs from 'seq', which must come in strictly ascending order of keys.
+ * Tree is built bottom-up, with every page filled to 'fill' fraction of its capacity, but never below the minimum Del keeps.
+ * On invalid input the tree is left untouched. Bulk loading is not logged, so tree with Log is flushed as a new checkpoint.
+ */
+func (t *Tree[K, V]) BulkLoad(seq iter.Seq2[K, V], fill float64) error {
+	if (fill <= 0) || (fill > 1) {
+		return ErrBadFill
+	}
+	if (t.Log != nil) && (t.Store == nil) {
+		return ErrNoStore
+	}
+	t.init()
+
+	var keys []K
+	var values []V
+	for k, v := range seq {
+		if (len(keys) > 0) && (t.Compare(k, keys[len(keys)-1]) <= 0) {
+			return fmt.Errorf("%w: %v after %v", ErrUnsorted, k, keys[len(keys)-1])
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+
+	t.Clear()
+	if len(keys) > 0 {
+		half := t.Order/2 - (1 - t.Order%2)
+
+		/* Separator before the first key of every page's subtree goes into its parent. */
+		var pages []Page
+		var firsts []K
+
+		var sizes []int
+		if t.sized() {
+			room := t.measure(&Leaf[K, V]{Keys: keys, Values: values}, nil)
+			sizes = fit(t.sizes, max(int(fill*float64(room)), 1), room)
+		} else {
+			sizes = parts(len(keys), max(int(fill*float64(t.Order-1)), 1), half, t.Order-1)
+		}
+
+		prev := &t.rendSentinel
+		offset := 0
+		for _, n := range sizes {
+			leaf := t.newLeaf(n)
+			copy(leaf.Keys, keys[offset:])
+			copy(leaf.Values, values[offset:])
+			leaf.dirty = true
+
+			leaf.Prev = prev
+			prev.Next = leaf
+			prev = leaf
+
+			pages = append(pages, leaf)
+			if offset == 0 {
+				firsts = append(firsts, leaf.Keys[0])
+			} else {
+				firsts = append(firsts, t.separator(keys[offset-1], keys[offset]))
+			}
+			offset += n
+		}
+		prev.Next = &t.endSentinel
+		t.endSentinel.Prev = prev
+
+		for len(pages) > 1 {
+			var nodes []Page
+			var nodeFirsts []K
+
+			offset = 0
+			for _, n := range parts(len(pages), max(int(fill*float64(t.Order)), 2), half+1, t.Order) {
+				node := t.newNode(n - 1)
+				node.ChildPage0 = pages[offset]
+				copy(node.Children, pages[offset+1:offset+n])
+				copy(node.Keys, firsts[offset+1:offset+n])
+				node.dirty = true
+				t.recount(node)
+
+				nodes = append(nodes, node)
+				nodeFirsts = append(nodeFirsts, firsts[offset])
+				offset += n
+			}
+			pages, firsts = nodes, nodeFirsts
+		}
+		t.Root = pages[0]
+	}
+
+	if t.Log != nil {
+		return t.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/* latchedNode is both node and leaf of ConcurrentTree: leaves have no children. Node keys separate children, so that len(Children) == len(Keys)+1. */
+type latchedNode[K cmp.Ordered, V any] struct {
+	sync.RWMutex
+
+	Keys     []K
+	Values   []V
+	Children []*latchedNode[K, V]
+}
+
+/*
+ * ConcurrentTree is a B+tree safe for concurrent use. Every node has its own latch and operations use latch crabbing:
+ * readers hold at most two shared latches on their way down, writers keep exclusive latches only on the part of the
+ * path that split or merge may reach, releasing ancestors as soon as they reach a node that is safe to modify.
+ * Leaves are not linked, so there is no horizontal traffic that could deadlock with descents.
+ */
+type ConcurrentTree[K cmp.Ordered, V any] struct {
+	Order int
+
+	/* Protects root pointer itself, acts as latch of a virtual parent of the root. */
+	latch sync.RWMutex
+	root  *latchedNode[K, V]
+}
+
+func (n *latchedNode[K, V]) leaf() bool {
+	return n.Children == nil
+}
+
+/* child returns index of the child subtree which may contain the key. */
+func (n *latchedNode[K, V]) child(key K) int {
+	i := 0
+	for (i < len(n.Keys)) && (key >= n.Keys[i]) {
+		i++
+	}
+	return i
+}
+
+/* find returns position of the key in the leaf, or position where it should be inserted. */
+func (n *latchedNode[K, V]) find(key K) (int, bool) {
+	i := 0
+	for (i < len(n.Keys)) && (key > n.Keys[i]) {
+		i++
+	}
+	return i, (i < len(n.Keys)) && (key == n.Keys[i])
+}
+
+func (t *ConcurrentTree[K, V]) init() {
+	if t.Order == 0 {
+		t.Order = DefaultOrder
+	}
+	if t.root == nil {
+		t.root = t.newNode(true)
+	}
+}
+
+/* newNode allocates node with room for one extra key, so insertions can go in place before split. */
+func (t *ConcurrentTree[K, V]) newNode(leaf bool) *latchedNode[K, V] {
+	n := &latchedNode[K, V]{Keys: make([]K, 0, t.Order)}
+	if leaf {
+		n.Values = make([]V, 0, t.Order)
+	} else {
+		n.Children = make([]*latchedNode[K, V], 0, t.Order+1)
+	}
+	return n
+}
+
+func (t *ConcurrentTree[K, V]) minKeys() int {
+	return (t.Order - 1) / 2
+}
+
+/* release unlocks all exclusively latched nodes on the path, and the root latch if it is still held. */
+func (t *ConcurrentTree[K, V]) release(path []*latchedNode[K, V], root bool) {
+	for _, n := range path {
+		n.Unlock()
+	}
+	if root {
+		t.latch.Unlock()
+	}
+}
+
+/* descend returns exclusively latched path from the root to the leaf for the key, starting from the deepest ancestor that is not safe according to 'safe'. */
+func (t *ConcurrentTree[K, V]) descend(key K, safe func(*latchedNode[K, V]) bool) ([]*latchedNode[K, V], bool) {
+	t.latch.Lock()
+	t.init()
+
+	n := t.root
+	n.Lock()
+
+	root := true
+	path := []*latchedNode[K, V]{n}
+	if safe(n) {
+		t.latch.Unlock()
+		root = false
+	}
+
+	for !n.leaf() {
+		c := n.Children[n.child(key)]
+		c.Lock()
+		if safe(c) {
+			t.release(path, root)
+			path = path[:0]
+			root = false
+		}
+		path = append(path, c)
+		n = c
+	}
+
+	return path, root
+}
+
+/* leaf returns leaf for the key with shared latch held. */
+func (t *ConcurrentTree[K, V]) leaf(key K) *latchedNode[K, V] {
+	t.latch.RLock()
+	n := t.root
+	if n == nil {
+		t.latch.RUnlock()
+		return nil
+	}
+	n.RLock()
+	t.latch.RUnlock()
+
+	for !n.leaf() {
+		c := n.Children[n.child(key)]
+		c.RLock()
+		n.RUnlock()
+		n = c
+	}
+	return n
+}
+
+/* Clear removes all keys. Operations that already passed the root finish on the old tree. */
+func (t *ConcurrentTree[K, V]) Clear() {
+	t.latch.Lock()
+	defer t.latch.Unlock()
+
+	t.root = nil
+}
+
+/* rebalance fixes underflow of parent.Children[i] by borrowing from or merging with its sibling. Caller holds exclusive latches on both 'parent' and the child. */
+func (t *ConcurrentTree[K, V]) rebalance(parent *latchedNode[K, V], i int) {
+	n := parent.Children[i]
+
+	if i > 0 {
+		left := parent.Children[i-1]
+		left.Lock()
+		defer left.Unlock()
+
+		if len(left.Keys) > t.minKeys() {
+			last := len(left.Keys) - 1
+			if n.leaf() {
+				n.Keys = insertAtIndex(n.Keys, left.Keys[last], 0)
+				n.Values = insertAtIndex(n.Values, left.Values[last], 0)
+				left.Values = left.Values[:last]
+				parent.Keys[i-1] = left.Keys[last]
+			} else {
+				n.Keys = insertAtIndex(n.Keys, parent.Keys[i-1], 0)
+				n.Children = insertAtIndex(n.Children, left.Children[last+1], 0)
+				left.Children = left.Children[:last+1]
+				parent.Keys[i-1] = left.Keys[last]
+			}
+			left.Keys = left.Keys[:last]
+			return
+		}
+		t.merge(parent, i-1)
+		return
+	}
+
+	right := parent.Children[i+1]
+	right.Lock()
+	defer right.Unlock()
+
+	if len(right.Keys) > t.minKeys() {
+		if n.leaf() {
+			n.Keys = append(n.Keys, right.Keys[0])
+			n.Values = append(n.Values, right.Values[0])
+			right.Values = removeAtIndex(right.Values, 0)
+			right.Keys = removeAtIndex(right.Keys, 0)
+			parent.Keys[i] = right.Keys[0]
+		} else {
+			n.Keys = append(n.Keys, parent.Keys[i])
+			n.Children = append(n.Children, right.Children[0])
+			parent.Keys[i] = right.Keys[0]
+			right.Keys = removeAtIndex(right.Keys, 0)
+			right.Children = removeAtIndex(right.Children, 0)
+		}
+		return
+	}
+	t.merge(parent, i)
+}
+
+/* merge appends parent.Children[i+1] to parent.Children[i] and removes the former from parent. */
+func (t *ConcurrentTree[K, V]) merge(parent *latchedNode[K, V], i int) {
+	left, right := parent.Children[i], parent.Children[i+1]
+	if left.leaf() {
+		left.Keys = append(left.Keys, right.Keys...)
+		left.Values = append(left.Values, right.Values...)
+	} else {
+		left.Keys = append(left.Keys, parent.Keys[i])
+		left.Keys = append(left.Keys, right.Keys...)
+		left.Children = append(left.Children, right.Children...)
+	}
+	parent.Keys = removeAtIndex(parent.Keys, i)
+	parent.Children = removeAtIndex(parent.Children, i+1)
+}
+
+func (t *ConcurrentTree[K, V]) Del(key K) {
+	path, root := t.descend(key, func(n *latchedNode[K, V]) bool {
+		return len(n.Keys) > t.minKeys()
+	})
+	defer func() { t.release(path, root) }()
+
+	leaf := path[len(path)-1]
+	i, ok := leaf.find(key)
+	if !ok {
+		return
+	}
+	leaf.Keys = removeAtIndex(leaf.Keys, i)
+	leaf.Values = removeAtIndex(leaf.Values, i)
+
+	for j := len(path) - 1; j > 0; j-- {
+		if len(path[j].Keys) >= t.minKeys() {
+			break
+		}
+		t.rebalance(path[j-1], path[j-1].child(key))
+	}
+
+	if (root) && (!t.root.leaf()) && (len(t.root.Keys) == 0) {
+		t.root = t.root.Children[0]
+	}
+}
+
+func (t *ConcurrentTree[K, V]) Get(key K) V {
+	var v V
+
+	leaf := t.leaf(key)
+	if leaf == nil {
+		return v
+	}
+	if i, ok := leaf.find(key); ok {
+		v = leaf.Values[i]
+	}
+	leaf.RUnlock()
+
+	return v
+}
+
+func (t *ConcurrentTree[K, V]) Has(key K) bool {
+	leaf := t.leaf(key)
+	if leaf == nil {
+		return false
+	}
+	_, ok := leaf.find(key)
+	leaf.RUnlock()
+
+	return ok
+}
+
+func (t *ConcurrentTree[K, V]) Set(key K, value V) {
+	path, root := t.descend(key, func(n *latchedNode[K, V]) bool {
+		return len(n.Keys) < t.Order-1
+	})
+	defer func() { t.release(path, root) }()
+
+	leaf := path[len(path)-1]
+	i, ok := leaf.find(key)
+	if ok {
+		leaf.Values[i] = value
+		return
+	}
+	leaf.Keys = insertAtIndex(leaf.Keys, key, i)
+	leaf.Values = insertAtIndex(leaf.Values, value, i)
+
+	/* Split full pages bottom-up. Every page being split has its parent latched, because it was not safe. */
+	for j := len(path) - 1; (j >= 0) && (len(path[j].Keys) == t.Order); j-- {
+		n := path[j]
+		half := len(n.Keys) / 2
+
+		var sep K
+		right := t.newNode(n.leaf())
+		if n.leaf() {
+			right.Keys = append(right.Keys, n.Keys[half:]...)
+			right.Values = append(right.Values, n.Values[half:]...)
+			n.Keys = n.Keys[:half]
+			n.Values = n.Values[:half]
+			sep = right.Keys[0]
+		} else {
+			sep = n.Keys[half]
+			right.Keys = append(right.Keys, n.Keys[half+1:]...)
+			right.Children = append(right.Children, n.Children[half+1:]...)
+			n.Keys = n.Keys[:half]
+			n.Children = n.Children[:half+1]
+		}
+
+		if j == 0 {
+			/* Only the root may split without latched parent, and then root latch is still held. */
+			t.root = t.newNode(false)
+			t.root.Keys = append(t.root.Keys, sep)
+			t.root.Children = append(t.root.Children, n, right)
+			break
+		}
+		parent := path[j-1]
+		k := parent.child(sep)
+		parent.Keys = insertAtIndex(parent.Keys, sep, k)
+		parent.Children = insertAtIndex(parent.Children, right, k+1)
+	}
+}
+
+func (t *ConcurrentTree[K, V]) stringImpl(sb *strings.Builder, n *latchedNode[K, V], level int) {
+	for i := 0; i < level; i++ {
+		sb.WriteRune('\t')
+	}
+	for i := 0; i < len(n.Keys); i++ {
+		fmt.Fprintf(sb, "%4v", n.Keys[i])
+	}
+	sb.WriteRune('\n')
+
+	for i := 0; i < len(n.Children); i++ {
+		t.stringImpl(sb, n.Children[i], level+1)
+	}
+}
+
+/* String must not be called concurrently with modifications. */
+func (t *ConcurrentTree[K, V]) String() string {
+	var sb strings.Builder
+
+	if t.root != nil {
+		t.stringImpl(&sb, t.root, 0)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,191 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkLatched verifies key order and node fill of the subtree, returning its height. */
+func checkLatched[K cmp.Ordered, V any](t *testing.T, ct *ConcurrentTree[K, V], n *latchedNode[K, V], root bool) int {
+	t.Helper()
+
+	if (!root) && (len(n.Keys) < ct.minKeys()) {
+		t.Errorf("node %v has less than %d keys", n.Keys, ct.minKeys())
+	}
+	if len(n.Keys) >= ct.Order {
+		t.Errorf("node %v has more than %d keys", n.Keys, ct.Order-1)
+	}
+	for i := 1; i < len(n.Keys); i++ {
+		if n.Keys[i-1] >= n.Keys[i] {
+			t.Errorf("keys %v are not sorted", n.Keys)
+		}
+	}
+	if n.leaf() {
+		return 1
+	}
+
+	if len(n.Children) != len(n.Keys)+1 {
+		t.Errorf("node %v has %d children", n.Keys, len(n.Children))
+	}
+	height := checkLatched(t, ct, n.Children[0], false)
+	for i := 1; i < len(n.Children); i++ {
+		if h := checkLatched(t, ct, n.Children[i], false); h != height {
+			t.Errorf("subtrees of %v have different heights %d and %d", n.Keys, height, h)
+		}
+	}
+	return height + 1
+}
+
+func countLatched[K cmp.Ordered, V any](n *latchedNode[K, V]) int {
+	if n.leaf() {
+		return len(n.Keys)
+	}
+
+	var count int
+	for _, c := range n.Children {
+		count += countLatched(c)
+	}
+	return count
+}
+
+func testConcurrentTree(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var ct ConcurrentTree[int, int]
+	ct.Order = order
+
+	m := make(map[int]int)
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		ct.Set(k, i)
+		m[k] = i
+		keys = append(keys, k)
+	}
+	checkLatched(t, &ct, ct.root, true)
+
+	for k, v := range m {
+		if got := ct.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+
+	for i := 0; i < len(keys); i += 2 {
+		ct.Del(keys[i])
+		delete(m, keys[i])
+	}
+	checkLatched(t, &ct, ct.root, true)
+
+	for _, k := range keys {
+		_, ok := m[k]
+		if got := ct.Has(k); got != ok {
+			t.Errorf("expected presence of key %v to be %v, got %v", k, ok, got)
+		}
+	}
+
+	for _, k := range keys {
+		ct.Del(k)
+	}
+	if len(ct.root.Keys) != 0 {
+		t.Errorf("expected empty tree, got %v", ct.String())
+	}
+}
+
+func TestConcurrentTree(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testConcurrentTree(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+/* Stress test for 'go test -race': workers share the tree and compare results with a map under a mutex. */
+func TestConcurrentTreeStress(t *testing.T) {
+	const (
+		Workers = 8
+		Keys    = 512
+	)
+
+	orders := [...]int{constants.MinOrder, DefaultOrder}
+
+	for _, order := range orders {
+		t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+			var ct ConcurrentTree[int, int]
+			ct.Order = order
+
+			/* Each key is owned by one worker, so expected state of the key is known to it exactly. */
+			var mu sync.Mutex
+			m := make(map[int]int)
+
+			var wg sync.WaitGroup
+			for w := 0; w < Workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+
+					rng := rand.New(rand.NewSource(constants.Seed + int64(w)))
+					for i := 0; i < constants.N; i++ {
+						k := rng.Intn(Keys)*Workers + w
+						switch rng.Intn(3) {
+						case 0:
+							ct.Set(k, i)
+							mu.Lock()
+							m[k] = i
+							mu.Unlock()
+						case 1:
+							ct.Del(k)
+							mu.Lock()
+							delete(m, k)
+							mu.Unlock()
+						case 2:
+							mu.Lock()
+							v, ok := m[k]
+							mu.Unlock()
+							if got := ct.Has(k); got != ok {
+								t.Errorf("expected presence of key %v to be %v, got %v", k, ok, got)
+							}
+							if got := ct.Get(k); got != v {
+								t.Errorf("expected value %v for key %v, got %v", v, k, got)
+							}
+						}
+						/* Readers of foreign keys only exercise latches. */
+						ct.Get(rng.Intn(Keys * Workers))
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			checkLatched(t, &ct, ct.root, true)
+			for k, v := range m {
+				if got := ct.Get(k); got != v {
+					t.Errorf("expected value %v for key %v, got %v", v, k, got)
+				}
+			}
+			n := countLatched(ct.root)
+			if n != len(m) {
+				t.Errorf("expected %d keys, got %d", len(m), n)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package bplus
+
+import "iter"
+
+/* Cursor is a position in the sequence of keys. It is invalidated by any modification of the tree. */
+type Cursor[K any, V any] struct {
+	tree  *Tree[K, V]
+	leaf  *Leaf[K, V]
+	index int
+}
+
+/* Cursor returns cursor positioned before the first key, so that Next moves it to the first key. */
+func (t *Tree[K, V]) Cursor() *Cursor[K, V] {
+	return &Cursor[K, V]{tree: t, leaf: t.Rend()}
+}
+
+/* First positions cursor at the smallest key. */
+func (c *Cursor[K, V]) First() {
+	c.leaf = c.tree.Rend()
+	c.index = 0
+	c.Next()
+}
+
+func (c *Cursor[K, V]) Key() K {
+	return c.leaf.Keys[c.index]
+}
+
+/* Last positions cursor at the largest key. */
+func (c *Cursor[K, V]) Last() {
+	c.leaf = c.tree.End()
+	c.index = 0
+	c.Prev()
+}
+
+/* Next moves cursor to the next key. Past the last key cursor becomes invalid, but Prev brings it back. */
+func (c *Cursor[K, V]) Next() {
+	end := c.tree.End()
+
+	c.index++
+	for (c.leaf != end) && (c.index >= len(c.leaf.Keys)) {
+		c.leaf = c.tree.next(c.leaf)
+		c.index = 0
+	}
+}
+
+/* Prev moves cursor to the previous key. Before the first key cursor becomes invalid, but Next brings it back. */
+func (c *Cursor[K, V]) Prev() {
+	rend := c.tree.Rend()
+
+	c.index--
+	for (c.leaf != rend) && (c.index < 0) {
+		c.leaf = c.tree.prev(c.leaf)
+		c.index = len(c.leaf.Keys) - 1
+	}
+}
+
+/* Seek positions cursor at the smallest key greater than or equal to 'key'. */
+func (c *Cursor[K, V]) Seek(key K) {
+	page := c.tree.Root
+	for page != nil {
+		switch p := page.(type) {
+		case *Node[K]:
+			page = c.tree.child(p, c.tree.findOnNode(p, key))
+		case *Leaf[K, V]:
+			index, _ := c.tree.findOnLeaf(p, key)
+			c.leaf = p
+			c.index = index
+			c.Next()
+			return
+		}
+	}
+
+	c.leaf = c.tree.End()
+	c.index = 0
+}
+
+/* SeekLast positions cursor at the largest key less than or equal to 'key'. */
+func (c *Cursor[K, V]) SeekLast(key K) {
+	c.Seek(key)
+	if (!c.Valid()) || (c.tree.Compare(c.Key(), key) > 0) {
+		c.Prev()
+	}
+}
+
+func (c *Cursor[K, V]) Valid() bool {
+	return (c.index >= 0) && (c.index < len(c.leaf.Keys))
+}
+
+func (c *Cursor[K, V]) Value() V {
+	return c.leaf.Values[c.index]
+}
+
+func (c *Cursor[K, V]) entry() (K, V, bool) {
+	if !c.Valid() {
+		var k K
+		var v V
+		return k, v, false
+	}
+	return c.Key(), c.Value(), true
+}
+
+/*
+ * next returns the leaf after 'leaf', End after the last one. Leaves of a tree with Store are read and replaced
+ * behind their neighbours' backs, so their links are not followed: the leaf is found by descending from the root,
+ * and 'leaf' must not be empty unless it is the root.
+ */
+func (t *Tree[K, V]) next(leaf *Leaf[K, V]) *Leaf[K, V] {
+	if (leaf.Next != nil) && (t.Store == nil) {
+		return leaf.Next
+	}
+
+	var page Page
+	if leaf == &t.rendSentinel {
+		page = t.Root
+	} else if len(leaf.Keys) > 0 {
+		path := t.path(leaf.Keys[len(leaf.Keys)-1])
+		for p := len(path) - 1; (page == nil) && (p >= 0); p-- {
+			if path[p].Index < len(path[p].Node.Children)-1 {
+				page = t.child(path[p].Node, path[p].Index+1)
+			}
+		}
+	}
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			page = t.child(p, -1)
+		case *Leaf[K, V]:
+			return p
+		default:
+			return &t.endSentinel
+		}
+	}
+}
+
+/* prev returns the leaf before 'leaf', Rend before the first one, see next. */
+func (t *Tree[K, V]) prev(leaf *Leaf[K, V]) *Leaf[K, V] {
+	if (leaf.Prev != nil) && (t.Store == nil) {
+		return leaf.Prev
+	}
+
+	var page Page
+	if leaf == &t.endSentinel {
+		page = t.Root
+	} else if len(leaf.Keys) > 0 {
+		path := t.path(leaf.Keys[0])
+		for p := len(path) - 1; (page == nil) && (p >= 0); p-- {
+			if path[p].Index >= 0 {
+				page = t.child(path[p].Node, path[p].Index-1)
+			}
+		}
+	}
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			page = t.child(p, len(p.Children)-1)
+		case *Leaf[K, V]:
+			return p
+		default:
+			return &t.rendSentinel
+		}
+	}
+}
+
+/* path returns nodes leading to the leaf with 'key', without touching SearchPath. */
+func (t *Tree[K, V]) path(key K) []PathItem[K] {
+	var path []PathItem[K]
+
+	page := t.Root
+	for {
+		p, ok := page.(*Node[K])
+		if !ok {
+			return path
+		}
+		index := t.findOnNode(p, key)
+		path = append(path, PathItem[K]{Node: p, Index: index})
+		page = t.child(p, index)
+	}
+}
+
+/* All returns iterator over all key-value pairs in ascending order. */
+func (t *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for leaf := t.Begin(); leaf != t.End(); leaf = t.next(leaf) {
+			for i := 0; i < len(leaf.Keys); i++ {
+				if !yield(leaf.Keys[i], leaf.Values[i]) {
+					return
+				}
+			}
+		}
+	}
+}
+
+/* Ceiling returns the smallest key greater than or equal to 'key'. */
+func (t *Tree[K, V]) Ceiling(key K) (K, V, bool) {
+	c := t.Cursor()
+	c.Seek(key)
+	return c.entry()
+}
+
+/* Floor returns the largest key less than or equal to 'key'. */
+func (t *Tree[K, V]) Floor(key K) (K, V, bool) {
+	c := t.Cursor()
+	c.SeekLast(key)
+	return c.entry()
+}
+
+func (t *Tree[K, V]) Max() (K, V, bool) {
+	c := t.Cursor()
+	c.Last()
+	return c.entry()
+}
+
+func (t *Tree[K, V]) Min() (K, V, bool) {
+	c := t.Cursor()
+	c.First()
+	return c.entry()
+}
+
+/*
+ * Range returns iterator over keys from 'lo' up to, but not including, 'hi'. If 'lo' is greater than 'hi', keys are
+ * visited in descending order, again from 'lo' inclusive to 'hi' exclusive.
+ */
+func (t *Tree[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if t.Root == nil {
+			return
+		}
+
+		c := t.Cursor()
+		if t.Compare(lo, hi) <= 0 {
+			for c.Seek(lo); (c.Valid()) && (t.Compare(c.Key(), hi) < 0); c.Next() {
+				if !yield(c.Key(), c.Value()) {
+					return
+				}
+			}
+		} else {
+			for c.SeekLast(lo); (c.Valid()) && (t.Compare(c.Key(), hi) > 0); c.Prev() {
+				if !yield(c.Key(), c.Value()) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package bplus
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* Number of random ranges checked per test. */
+const Ranges = 64
+
+func testBplusCursor(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		m[k] = i
+		bt.Set(k, i)
+	}
+	/* Leave some leaves sparse. */
+	for k := range m {
+		if k%3 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+	}
+
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	c := bt.Cursor()
+	i := 0
+	for c.First(); c.Valid(); c.Next() {
+		if c.Key() != keys[i] {
+			t.Fatalf("expected key %v at %d, got %v", keys[i], i, c.Key())
+		}
+		if c.Value() != m[keys[i]] {
+			t.Errorf("expected value %v for key %v, got %v", m[keys[i]], keys[i], c.Value())
+		}
+		i++
+	}
+	if i != len(keys) {
+		t.Errorf("expected %d keys going forward, got %d", len(keys), i)
+	}
+
+	/* Cursor past the end comes back with Prev. */
+	c.Prev()
+	for i = len(keys) - 1; c.Valid(); c.Prev() {
+		if c.Key() != keys[i] {
+			t.Fatalf("expected key %v at %d, got %v", keys[i], i, c.Key())
+		}
+		i--
+	}
+	if i != -1 {
+		t.Errorf("expected %d keys going backward, got %d", len(keys), len(keys)-1-i)
+	}
+
+	var all []int
+	for k := range bt.All() {
+		all = append(all, k)
+	}
+	if !slices.Equal(all, keys) {
+		t.Errorf("expected keys %v, got %v", keys, all)
+	}
+	if k, _, _ := bt.Min(); k != keys[0] {
+		t.Errorf("expected minimum %v, got %v", keys[0], k)
+	}
+	if k, _, _ := bt.Max(); k != keys[len(keys)-1] {
+		t.Errorf("expected maximum %v, got %v", keys[len(keys)-1], k)
+	}
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for j := 0; j < Ranges; j++ {
+		lo := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+		hi := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+
+		c.Seek(lo)
+		i, found := slices.BinarySearch(keys, lo)
+		if (i < len(keys)) != c.Valid() {
+			t.Errorf("Seek(%v): expected validity %v, got %v", lo, i < len(keys), c.Valid())
+		} else if (c.Valid()) && (c.Key() != keys[i]) {
+			t.Errorf("Seek(%v): expected key %v, got %v", lo, keys[i], c.Key())
+		}
+		if k, _, ok := bt.Ceiling(lo); (ok) && (k != keys[i]) {
+			t.Errorf("Ceiling(%v): expected key %v, got %v", lo, keys[i], k)
+		}
+		if !found {
+			i--
+		}
+		k, _, ok := bt.Floor(lo)
+		if (i >= 0) != ok {
+			t.Errorf("Floor(%v): expected presence %v, got %v", lo, i >= 0, ok)
+		} else if (ok) && (k != keys[i]) {
+			t.Errorf("Floor(%v): expected key %v, got %v", lo, keys[i], k)
+		}
+
+		var expected []int
+		if lo <= hi {
+			for i := 0; i < len(keys); i++ {
+				if (keys[i] >= lo) && (keys[i] < hi) {
+					expected = append(expected, keys[i])
+				}
+			}
+		} else {
+			for i := len(keys) - 1; i >= 0; i-- {
+				if (keys[i] <= lo) && (keys[i] > hi) {
+					expected = append(expected, keys[i])
+				}
+			}
+		}
+
+		var got []int
+		for k, v := range bt.Range(lo, hi) {
+			if v != m[k] {
+				t.Errorf("Range(%v, %v): expected value %v for key %v, got %v", lo, hi, m[k], k, v)
+			}
+			got = append(got, k)
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("Range(%v, %v): expected %v, got %v", lo, hi, expected, got)
+		}
+	}
+
+	for range bt.Range(keys[0], keys[len(keys)-1]) {
+		break
+	}
+
+	bt.Clear()
+	c = bt.Cursor()
+	if c.First(); c.Valid() {
+		t.Errorf("expected no keys after Clear, got %v", c.Key())
+	}
+	if _, _, ok := bt.Max(); ok {
+		t.Errorf("expected no maximum after Clear")
+	}
+}
+
+func TestBplusCursor(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testBplusCursor(t, generator, order)
+				})
+			}
+		})
+	}
+}
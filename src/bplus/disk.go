@@ -0,0 +1,728 @@
+package bplus
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"codec"
+	"pager"
+)
+
+/*
+ * Page layout, all integers are little-endian:
+ *	Node: [type:1][count:2][childPage0:4, size0:4][children and their sizes:8*count][keys...]
+ *	Leaf: [type:1][count:2][key, flag:1, value | key, flag:1, length:4, first:4 ...]
+ *	Prefix leaf: [type:1][count:2][prefixLength:uvarint][prefix][suffixLength:uvarint, suffix, flag:1, value... ]
+ *	Overflow: [type:1][next:4][length:2][data...]
+ *
+ * Values longer than a quarter of a page are stored in chains of overflow pages, so that a leaf always fits
+ * several of them. Leaf refers to the first page of the chain and keeps total length of the value.
+ *
+ * Keys which KeyCodec can store as raw bytes, such as strings, go into prefix leaves. Prefix shared by all keys of
+ * the leaf is stored once, so that pages of keys like paths and URLs take less space. Such leaves are split by size
+ * rather than by Order, see sized.
+ */
+const (
+	pageNode     = 1
+	pageLeaf     = 2
+	pageOverflow = 3
+	pageLeafRaw  = 4
+
+	pageHeaderSize     = 3
+	overflowHeaderSize = 7
+
+	valueInline   = 0
+	valueOverflow = 1
+)
+
+var (
+	ErrNoStore      = errors.New("tree has no page store")
+	ErrPageOverflow = errors.New("page contents do not fit into page")
+	ErrCorrupted    = errors.New("page is corrupted")
+)
+
+/*
+ * pageRef is a child which stays in Store until an operation needs it, see child. Tree with Store keeps in memory
+ * only its root and pages modified since the last Flush, which replaces them with references once they are written.
+ */
+type pageRef struct {
+	ID   pager.PageID
+	size int
+}
+
+func (t *Tree[K, V]) initCodecs() {
+	if t.KeyCodec == nil {
+		t.KeyCodec = codec.Default[K]{}
+	}
+	if t.ValueCodec == nil {
+		t.ValueCodec = codec.Default[V]{}
+	}
+
+	t.rawKeys = nil
+	if raw, ok := t.KeyCodec.(codec.Raw[K]); ok && raw.Raw() {
+		t.rawKeys = raw
+	}
+}
+
+func pageID[K any, V any](page Page) pager.PageID {
+	switch page := page.(type) {
+	case *Node[K]:
+		return page.ID
+	case *Leaf[K, V]:
+		return page.ID
+	case *pageRef:
+		return page.ID
+	}
+	return pager.InvalidPage
+}
+
+/* child returns child at 'index' of 'n', where -1 is ChildPage0, reading it from Store if needed. Child that is read is not put into 'n'. Returns nil if reading has failed, see Err. */
+func (t *Tree[K, V]) child(n *Node[K], index int) Page {
+	page := n.ChildPage0
+	if index >= 0 {
+		page = n.Children[index]
+	}
+
+	ref, ok := page.(*pageRef)
+	if !ok {
+		return page
+	}
+	return t.readChild(ref)
+}
+
+/* readChild reads page that 'ref' refers to. Returns nil if reading has failed, see Err. */
+func (t *Tree[K, V]) readChild(ref *pageRef) Page {
+	page, err := t.readPage(ref.ID)
+	if err != nil {
+		t.setErr(fmt.Errorf("failed to read page: %w", err))
+		return nil
+	}
+	return page
+}
+
+func (t *Tree[K, V]) encodeNode(buf []byte, node *Node[K]) []byte {
+	buf = append(buf, pageNode)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(node.Keys)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pageID[K, V](node.ChildPage0)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pageSize[K, V](node.ChildPage0)))
+	for i := 0; i < len(node.Children); i++ {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(pageID[K, V](node.Children[i])))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(pageSize[K, V](node.Children[i])))
+	}
+	for i := 0; i < len(node.Keys); i++ {
+		buf = t.KeyCodec.Append(buf, node.Keys[i])
+	}
+	return buf
+}
+
+/* overflowChain is a chain of overflow pages holding one value. Value is told by its digest, so that unchanged values keep their chains. */
+type overflowChain struct {
+	First  pager.PageID
+	Pages  []pager.PageID
+	Digest [sha256.Size]byte
+}
+
+/*
+ * encodeLeaf moves large values of the leaf to overflow pages. Values that the previous version of the leaf already
+ * stored keep their chains, other chains of the previous version are released.
+ */
+func (t *Tree[K, V]) encodeLeaf(buf []byte, leaf *Leaf[K, V]) ([]byte, error) {
+	/* Slice may be shared with a snapshot, see ownLeaf. */
+	old := slices.Clone(leaf.overflow)
+	leaf.overflow = nil
+	defer func() {
+		for _, chain := range old {
+			t.freed = append(t.freed, chain.Pages...)
+		}
+	}()
+
+	if t.rawKeys == nil {
+		buf = append(buf, pageLeaf)
+	} else {
+		buf = append(buf, pageLeafRaw)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(leaf.Keys)))
+
+	var prefix int
+	if t.rawKeys != nil {
+		buf, prefix = t.appendPrefix(buf, leaf.Keys)
+	}
+	for i := 0; i < len(leaf.Keys); i++ {
+		if t.rawKeys == nil {
+			buf = t.KeyCodec.Append(buf, leaf.Keys[i])
+		} else {
+			t.keyBuf = t.rawKeys.AppendRaw(t.keyBuf[:0], leaf.Keys[i])
+			buf = binary.AppendUvarint(buf, uint64(len(t.keyBuf)-prefix))
+			buf = append(buf, t.keyBuf[prefix:]...)
+		}
+
+		t.valueBuf = t.ValueCodec.Append(t.valueBuf[:0], leaf.Values[i])
+		if len(t.valueBuf) <= t.Store.PageSize()/4 {
+			buf = append(buf, valueInline)
+			buf = append(buf, t.valueBuf...)
+			continue
+		}
+
+		digest := sha256.Sum256(t.valueBuf)
+		j := slices.IndexFunc(old, func(chain overflowChain) bool { return chain.Digest == digest })
+		if j != -1 {
+			leaf.overflow = append(leaf.overflow, old[j])
+			old = slices.Delete(old, j, j+1)
+		} else if err := t.writeOverflow(leaf, t.valueBuf, digest); err != nil {
+			return nil, err
+		}
+		buf = append(buf, valueOverflow)
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(t.valueBuf)))
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(leaf.overflow[len(leaf.overflow)-1].First))
+	}
+	return buf, nil
+}
+
+/* appendPrefix appends the longest prefix shared by raw encodings of 'keys' to 'buf' and returns its length. */
+func (t *Tree[K, V]) appendPrefix(buf []byte, keys []K) ([]byte, int) {
+	prefix := t.prefix(keys)
+	buf = binary.AppendUvarint(buf, uint64(prefix))
+	return append(buf, t.keyBuf[:prefix]...), prefix
+}
+
+/* prefix returns length of the longest prefix shared by raw encodings of all 'keys', which is left at the start of keyBuf. */
+func (t *Tree[K, V]) prefix(keys ...[]K) int {
+	first := -1
+	prefix := 0
+	for _, keys := range keys {
+		for i := 0; i < len(keys); i++ {
+			if first == -1 {
+				t.keyBuf = t.rawKeys.AppendRaw(t.keyBuf[:0], keys[i])
+				first = len(t.keyBuf)
+				prefix = first
+				continue
+			}
+			if prefix == 0 {
+				return 0
+			}
+
+			/* Keys are not required to be in byte order, so every one of them is compared with the first one. */
+			t.keyBuf = t.rawKeys.AppendRaw(t.keyBuf[:first], keys[i])
+			key := t.keyBuf[first:]
+
+			n := 0
+			for (n < min(prefix, len(key))) && (t.keyBuf[n] == key[n]) {
+				n++
+			}
+			prefix = n
+		}
+	}
+	return prefix
+}
+
+/* sized reports whether leaves hold as many pairs as fit into a page instead of Order-1, which is the case for raw keys of a tree with Store. */
+func (t *Tree[K, V]) sized() bool {
+	return (t.rawKeys != nil) && (t.Store != nil)
+}
+
+/*
+ * measure puts encoded sizes of pairs of 'leaf' and optional 'next' into 'sizes', without the prefix shared by all of
+ * them, and returns room for pairs in a page. Any run of these pairs fits into a page when their sizes add up to no
+ * more than the room, since the prefix of the run is not shorter than the shared one.
+ */
+func (t *Tree[K, V]) measure(leaf, next *Leaf[K, V]) int {
+	var varint [binary.MaxVarintLen64]byte
+
+	var prefix int
+	if next == nil {
+		prefix = t.prefix(leaf.Keys)
+	} else {
+		prefix = t.prefix(leaf.Keys, next.Keys)
+	}
+
+	t.sizes = t.sizes[:0]
+	for _, leaf := range [...]*Leaf[K, V]{leaf, next} {
+		if leaf == nil {
+			break
+		}
+		for i := 0; i < len(leaf.Keys); i++ {
+			t.keyBuf = t.rawKeys.AppendRaw(t.keyBuf[:0], leaf.Keys[i])
+			suffix := len(t.keyBuf) - prefix
+
+			value := 8
+			t.valueBuf = t.ValueCodec.Append(t.valueBuf[:0], leaf.Values[i])
+			if len(t.valueBuf) <= t.Store.PageSize()/4 {
+				value = len(t.valueBuf)
+			}
+			t.sizes = append(t.sizes, binary.PutUvarint(varint[:], uint64(suffix))+suffix+1+value)
+		}
+	}
+	return t.Store.PageSize() - pageHeaderSize - binary.MaxVarintLen32 - prefix
+}
+
+/* fit groups 'sizes' into parts of about equal totals near 'target', none of them over 'room' unless it has a single element. Returns numbers of elements in parts. */
+func fit(sizes []int, target, room int) []int {
+	var total int
+	for _, size := range sizes {
+		total += size
+	}
+	m := max((total+target-1)/target, 1)
+	target = (total + m - 1) / m
+
+	var counts []int
+	var n, sum int
+	for _, size := range sizes {
+		if (n > 0) && ((sum+size > room) || (sum >= target)) {
+			counts = append(counts, n)
+			n, sum = 0, 0
+		}
+		n++
+		sum += size
+	}
+	return append(counts, n)
+}
+
+/* overfull reports whether 'leaf' has to be split, see sized. */
+func (t *Tree[K, V]) overfull(leaf *Leaf[K, V]) bool {
+	if !t.sized() {
+		return len(leaf.Keys) >= t.Order
+	}
+	if len(leaf.Keys) < 2 {
+		return false
+	}
+	room := t.measure(leaf, nil)
+	for _, size := range t.sizes {
+		room -= size
+	}
+	return room < 0
+}
+
+/* underfull reports whether 'leaf' has to be refilled or merged with its neighbour. Sized leaves have to fill a quarter of the room. */
+func (t *Tree[K, V]) underfull(leaf *Leaf[K, V]) bool {
+	if !t.sized() {
+		half := t.Order/2 - (1 - t.Order%2)
+		return len(leaf.Keys) < half
+	}
+	room := t.measure(leaf, nil)
+	for _, size := range t.sizes {
+		room -= 4 * size
+	}
+	return room > 0
+}
+
+/*
+ * share returns how many pairs move to underfull 'leaf' from its neighbour 'sibling', on the 'right' or on the left,
+ * to even them out, or -1 if they fit into one page and have to be merged.
+ */
+func (t *Tree[K, V]) share(leaf, sibling *Leaf[K, V], right bool) int {
+	if !t.sized() {
+		half := t.Order/2 - (1 - t.Order%2)
+		if len(leaf.Keys)+len(sibling.Keys) < 2*half {
+			return -1
+		}
+		return (len(sibling.Keys) - len(leaf.Keys)) / 2
+	}
+
+	room := t.measure(leaf, sibling)
+	var total, sum int
+	for i, size := range t.sizes {
+		total += size
+		if i < len(leaf.Keys) {
+			sum += size
+		}
+	}
+	if total <= room {
+		return -1
+	}
+
+	var k int
+	for ; sum < total/2; k++ {
+		if right {
+			sum += t.sizes[len(leaf.Keys)+k]
+		} else {
+			sum += t.sizes[len(t.sizes)-1-k]
+		}
+	}
+	return k
+}
+
+/* writeOverflow puts 'data' with 'digest' into a chain of newly allocated pages owned by 'leaf'. */
+func (t *Tree[K, V]) writeOverflow(leaf *Leaf[K, V], data []byte, digest [sha256.Size]byte) error {
+	if cap(t.chainBuf) < t.Store.PageSize() {
+		t.chainBuf = make([]byte, t.Store.PageSize())
+	}
+	buf := t.chainBuf[:t.Store.PageSize()]
+	chunk := len(buf) - overflowHeaderSize
+
+	/* Chain is recorded before it is written, so that its pages are released even if writing fails. */
+	leaf.overflow = append(leaf.overflow, overflowChain{Digest: digest})
+	chain := &leaf.overflow[len(leaf.overflow)-1]
+
+	/* Chain is written from its end, so that every page knows the next one. */
+	next := pager.InvalidPage
+	for end := len(data); end > 0; {
+		start := (end - 1) / chunk * chunk
+
+		id, err := t.Store.Alloc()
+		if err != nil {
+			return err
+		}
+		chain.Pages = append(chain.Pages, id)
+
+		buf[0] = pageOverflow
+		binary.LittleEndian.PutUint32(buf[1:], uint32(next))
+		binary.LittleEndian.PutUint16(buf[5:], uint16(end-start))
+		n := copy(buf[overflowHeaderSize:], data[start:end])
+		clear(buf[overflowHeaderSize+n:])
+		if err := t.Store.Write(id, buf); err != nil {
+			return err
+		}
+		next, end = id, start
+	}
+	chain.First = next
+	return nil
+}
+
+/* readOverflow appends value of 'length' bytes stored in the chain starting at 'id' to 'data' and records the chain in 'leaf'. */
+func (t *Tree[K, V]) readOverflow(leaf *Leaf[K, V], data []byte, id pager.PageID, length int) ([]byte, error) {
+	chain := overflowChain{First: id}
+
+	if cap(t.chainBuf) < t.Store.PageSize() {
+		t.chainBuf = make([]byte, t.Store.PageSize())
+	}
+	buf := t.chainBuf[:t.Store.PageSize()]
+	start := len(data)
+
+	for length > 0 {
+		if id == pager.InvalidPage {
+			return nil, fmt.Errorf("%w: overflow chain ends %d bytes early", ErrCorrupted, length)
+		}
+		if err := t.Store.Read(id, buf); err != nil {
+			return nil, err
+		}
+		n := int(binary.LittleEndian.Uint16(buf[5:]))
+		if (buf[0] != pageOverflow) || (n > min(length, len(buf)-overflowHeaderSize)) {
+			return nil, fmt.Errorf("%w: overflow page %d", ErrCorrupted, id)
+		}
+		chain.Pages = append(chain.Pages, id)
+
+		data = append(data, buf[overflowHeaderSize:overflowHeaderSize+n]...)
+		length -= n
+		id = pager.PageID(binary.LittleEndian.Uint32(buf[1:]))
+	}
+
+	chain.Digest = sha256.Sum256(data[start:])
+	leaf.overflow = append(leaf.overflow, chain)
+	return data, nil
+}
+
+/* writePage puts 'buf' into a newly allocated page. Previous version of the page is released only after Flush makes new root durable. */
+func (t *Tree[K, V]) writePage(id *pager.PageID, buf []byte) error {
+	if len(buf) > t.Store.PageSize() {
+		return fmt.Errorf("%w: %d bytes, page size is %d", ErrPageOverflow, len(buf), t.Store.PageSize())
+	}
+
+	newID, err := t.Store.Alloc()
+	if err != nil {
+		return err
+	}
+	if *id != pager.InvalidPage {
+		t.freed = append(t.freed, *id)
+	}
+	*id = newID
+
+	n := len(buf)
+	buf = buf[:t.Store.PageSize()]
+	clear(buf[n:])
+	return t.Store.Write(*id, buf)
+}
+
+/*
+ * flushPage writes 'page' and its descendants if they were modified since the last Flush. Children go first, so
+ * parents always see assigned IDs. Written children are replaced with references, unless 'page' is shared with
+ * snapshots. Returns true if 'page' was moved.
+ */
+func (t *Tree[K, V]) flushPage(page Page) (bool, error) {
+	switch page := page.(type) {
+	case *Node[K]:
+		for i := -1; i < len(page.Children); i++ {
+			child := page.ChildPage0
+			if i >= 0 {
+				child = page.Children[i]
+			}
+			moved, err := t.flushPage(child)
+			if err != nil {
+				return false, err
+			}
+			if moved {
+				page.dirty = true
+			}
+			if (page.gen == t.gen) && (t.owned(child)) {
+				t.evict(child)
+				t.replaceChild(page, i, &pageRef{ID: pageID[K, V](child), size: pageSize[K, V](child)})
+			}
+		}
+		if (!page.dirty) && (page.ID != pager.InvalidPage) {
+			return false, nil
+		}
+		if err := t.writePage(&page.ID, t.encodeNode(t.pageBuffer(), page)); err != nil {
+			return false, err
+		}
+		page.dirty = false
+	case *Leaf[K, V]:
+		if (!page.dirty) && (page.ID != pager.InvalidPage) {
+			return false, nil
+		}
+		buf, err := t.encodeLeaf(t.pageBuffer(), page)
+		if err != nil {
+			return false, err
+		}
+		if err := t.writePage(&page.ID, buf); err != nil {
+			return false, err
+		}
+		page.dirty = false
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+/* evict unlinks leaf that is replaced with reference from its neighbours, so that links always lead to leaves in memory. */
+func (t *Tree[K, V]) evict(page Page) {
+	leaf, ok := page.(*Leaf[K, V])
+	if !ok {
+		return
+	}
+	if leaf.Prev != nil {
+		leaf.Prev.Next = nil
+	}
+	if leaf.Next != nil {
+		leaf.Next.Prev = nil
+	}
+	leaf.Prev = nil
+	leaf.Next = nil
+}
+
+func (t *Tree[K, V]) pageBuffer() []byte {
+	if cap(t.pageBuf) < t.Store.PageSize() {
+		t.pageBuf = make([]byte, 0, t.Store.PageSize())
+	}
+	return t.pageBuf[:0]
+}
+
+/*
+ * Flush writes all modified pages and makes the result durable. Flush never overwrites pages of the previous
+ * checkpoint, so crash at any point leaves either old or new version of the tree on disk. With Log set, Flush
+ * also syncs the log before writing any pages and starts new log after the checkpoint, unless batch is in progress.
+ * Written pages leave memory, except the root and pages shared with snapshots.
+ */
+func (t *Tree[K, V]) Flush() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+	t.initCodecs()
+
+	if t.Log != nil {
+		if err := t.Log.Sync(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := t.flushPage(t.Root); err != nil {
+		return fmt.Errorf("failed to flush tree: %w", err)
+	}
+	t.Store.SetRoot(pageID[K, V](t.Root))
+	if t.Log != nil {
+		t.Store.SetLSN(uint64(t.Log.End()))
+	}
+	if err := t.Store.Sync(); err != nil {
+		return err
+	}
+
+	for len(t.freed) > 0 {
+		if err := t.Store.Free(t.freed[len(t.freed)-1]); err != nil {
+			return fmt.Errorf("failed to free page: %w", err)
+		}
+		t.freed = t.freed[:len(t.freed)-1]
+	}
+	if err := t.Store.Sync(); err != nil {
+		return err
+	}
+
+	if (t.Log != nil) && (!t.batch) {
+		return t.Log.Reset()
+	}
+	return nil
+}
+
+/* readPage reads page 'id' from Store. Children of a node are references, leaf is not linked to its neighbours. */
+func (t *Tree[K, V]) readPage(id pager.PageID) (Page, error) {
+	var page Page
+
+	err := t.viewPage(id, func(buf []byte) error {
+		var err error
+		page, err = t.decodePage(id, buf)
+		return err
+	})
+	return page, err
+}
+
+/* viewPage calls 'fn' with contents of page 'id', which are not copied if Store is pager.Viewer, like buffer pool. */
+func (t *Tree[K, V]) viewPage(id pager.PageID, fn func([]byte) error) error {
+	if v, ok := t.Store.(pager.Viewer); ok {
+		return v.View(id, fn)
+	}
+
+	if cap(t.readBuf) < t.Store.PageSize() {
+		t.readBuf = make([]byte, t.Store.PageSize())
+	}
+	buf := t.readBuf[:t.Store.PageSize()]
+	if err := t.Store.Read(id, buf); err != nil {
+		return err
+	}
+	return fn(buf)
+}
+
+func (t *Tree[K, V]) decodePage(id pager.PageID, buf []byte) (Page, error) {
+
+	count := int(binary.LittleEndian.Uint16(buf[1:]))
+	if (count >= t.Order) && (buf[0] != pageLeafRaw) {
+		return nil, fmt.Errorf("%w: page %d has %d keys, order is %d", ErrCorrupted, id, count, t.Order)
+	}
+
+	switch buf[0] {
+	case pageNode:
+		node := t.newNode(count)
+		node.ID = id
+
+		offset := pageHeaderSize + 8*(count+1)
+		if offset > len(buf) {
+			return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+		}
+		for i := -1; i < count; i++ {
+			ref := &pageRef{
+				ID:   pager.PageID(binary.LittleEndian.Uint32(buf[pageHeaderSize+8*(i+1):])),
+				size: int(binary.LittleEndian.Uint32(buf[pageHeaderSize+8*(i+1)+4:])),
+			}
+			t.replaceChild(node, i, ref)
+		}
+		for i := 0; i < count; i++ {
+			key, n, err := t.KeyCodec.Decode(buf[offset:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode key on page %d: %w", id, err)
+			}
+			node.Keys[i] = key
+			offset += n
+		}
+		t.recount(node)
+		return node, nil
+	case pageLeaf, pageLeafRaw:
+		leaf := t.newLeaf(count)
+		leaf.ID = id
+
+		offset := pageHeaderSize
+		var prefix []byte
+		if buf[0] == pageLeafRaw {
+			if t.rawKeys == nil {
+				return nil, fmt.Errorf("%w: page %d has raw keys, key codec does not support them", ErrCorrupted, id)
+			}
+			l, n := binary.Uvarint(buf[offset:])
+			if (n <= 0) || (uint64(len(buf)-offset-n) < l) {
+				return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+			}
+			prefix = buf[offset+n : offset+n+int(l)]
+			offset += n + int(l)
+		}
+
+		for i := 0; i < count; i++ {
+			var key K
+			var err error
+			if buf[0] == pageLeaf {
+				var n int
+				key, n, err = t.KeyCodec.Decode(buf[offset:])
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode key on page %d: %w", id, err)
+				}
+				offset += n
+			} else {
+				l, n := binary.Uvarint(buf[offset:])
+				if (n <= 0) || (uint64(len(buf)-offset-n) < l) {
+					return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+				}
+				t.keyBuf = append(append(t.keyBuf[:0], prefix...), buf[offset+n:offset+n+int(l)]...)
+				offset += n + int(l)
+
+				key, err = t.rawKeys.DecodeRaw(t.keyBuf)
+				if err != nil {
+					return nil, fmt.Errorf("failed to decode key on page %d: %w", id, err)
+				}
+			}
+
+			if offset+1 > len(buf) {
+				return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+			}
+			flag := buf[offset]
+			offset++
+
+			data := buf[offset:]
+			switch flag {
+			case valueInline:
+			case valueOverflow:
+				if offset+8 > len(buf) {
+					return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+				}
+				length := int(binary.LittleEndian.Uint32(buf[offset:]))
+				first := pager.PageID(binary.LittleEndian.Uint32(buf[offset+4:]))
+				offset += 8
+
+				t.valueBuf, err = t.readOverflow(leaf, t.valueBuf[:0], first, length)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read value on page %d: %w", id, err)
+				}
+				data = t.valueBuf
+			default:
+				return nil, fmt.Errorf("%w: page %d has unknown value flag %d", ErrCorrupted, id, flag)
+			}
+
+			value, n, err := t.ValueCodec.Decode(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode value on page %d: %w", id, err)
+			}
+			if flag == valueInline {
+				offset += n
+			}
+
+			leaf.Keys[i] = key
+			leaf.Values[i] = value
+		}
+		return leaf, nil
+	default:
+		return nil, fmt.Errorf("%w: page %d has unknown type %d", ErrCorrupted, id, buf[0])
+	}
+}
+
+/* Load replaces contents of the tree with the one stored in its page store. Only the root is read, other pages are read when they are needed. */
+func (t *Tree[K, V]) Load() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+	t.initCodecs()
+
+	t.Root = nil
+	t.freed = t.freed[:0]
+	t.rendSentinel.Next = nil
+	t.endSentinel.Prev = nil
+
+	id := t.Store.Root()
+	if id == pager.InvalidPage {
+		return nil
+	}
+
+	root, err := t.readPage(id)
+	if err != nil {
+		return fmt.Errorf("failed to load tree: %w", err)
+	}
+	t.Root = root
+
+	return nil
+}
@@ -0,0 +1,701 @@
+package bplus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"codec"
+	"constants"
+	"generator"
+	"pager"
+)
+
+func testBplusFlush(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 2*pager.DefaultPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Store = p
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		bt.Set(k, v)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	i := 0
+	for k := range m {
+		if i%2 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+		i++
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	var loaded Tree[int, int]
+	loaded.Order = order
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+
+	for k, v := range m {
+		if got := loaded.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+
+	n := 0
+	prev, first := 0, true
+	for k := range loaded.All() {
+		if (!first) && (k <= prev) {
+			t.Fatalf("keys out of order: %v after %v", k, prev)
+		}
+		prev, first = k, false
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys in leaves, got %d", len(m), n)
+	}
+
+	n = 0
+	c := loaded.Cursor()
+	for c.Last(); c.Valid(); c.Prev() {
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys backwards, got %d", len(m), n)
+	}
+
+	/* Pages read from the store are modified and written again. */
+	i = 0
+	for k := range m {
+		if i%2 == 0 {
+			loaded.Del(k)
+			delete(m, k)
+		} else {
+			loaded.Set(k, k)
+			m[k] = k
+		}
+		i++
+	}
+	if err := loaded.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	if err := loaded.Err(); err != nil {
+		t.Fatalf("failed to read tree: %v", err)
+	}
+
+	var reloaded Tree[int, int]
+	reloaded.Order = order
+	reloaded.Store = p
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	n = 0
+	for k, v := range reloaded.All() {
+		if m[k] != v {
+			t.Errorf("expected value %v, got %v", m[k], v)
+		}
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys after reload, got %d", len(m), n)
+	}
+}
+
+func TestBplusFlush(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testBplusFlush(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+func TestBplusFlushEvicts(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Order = constants.MinOrder
+	bt.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	root, ok := bt.Root.(*Node[int])
+	if !ok {
+		t.Fatalf("expected root node")
+	}
+	for i := -1; i < len(root.Children); i++ {
+		child := root.ChildPage0
+		if i >= 0 {
+			child = root.Children[i]
+		}
+		if _, ok := child.(*pageRef); !ok {
+			t.Fatalf("expected child %d to be written out", i)
+		}
+	}
+	for i := 0; i < constants.N; i++ {
+		if got := bt.Get(i); got != i {
+			t.Errorf("expected value %v, got %v", i, got)
+		}
+	}
+	if _, ok := root.ChildPage0.(*pageRef); !ok {
+		t.Errorf("expected Get not to keep pages in memory")
+	}
+
+	view := bt.Snapshot()
+	for i := 0; i < constants.N; i++ {
+		bt.Del(i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	n := 0
+	for k, v := range view.All() {
+		if k != v {
+			t.Errorf("expected value %v, got %v", k, v)
+		}
+		n++
+	}
+	if n != constants.N {
+		t.Errorf("expected %d keys in snapshot, got %d", constants.N, n)
+	}
+}
+
+func TestBplusFlushReusesPages(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	count := p.PageCount()
+
+	bt.Clear()
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages, got %d", count, p.PageCount())
+	}
+}
+
+func TestBplusFlushOverflow(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), pager.MinPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, string]
+	bt.Order = 8
+	bt.Store = p
+
+	/* Values from empty ones to ones taking several pages, some of them exactly fill overflow pages. */
+	m := make(map[int]string)
+	set := func(round int) {
+		for i := 0; i < constants.N/16; i++ {
+			v := strings.Repeat(string(rune('a'+(i+round)%26)), i*37%(3*pager.MinPageSize))
+			if i%10 == 0 {
+				v = strings.Repeat("z", (i/10%4+1)*(pager.MinPageSize-overflowHeaderSize)-4)
+			}
+			m[i] = v
+			bt.Set(i, v)
+		}
+	}
+	check := func() {
+		t.Helper()
+
+		if err := bt.Flush(); err != nil {
+			t.Fatalf("failed to flush tree: %v", err)
+		}
+
+		var loaded Tree[int, string]
+		loaded.Order = bt.Order
+		loaded.Store = p
+		if err := loaded.Load(); err != nil {
+			t.Fatalf("failed to load tree: %v", err)
+		}
+		if loaded.Len() != len(m) {
+			t.Errorf("expected %d keys, got %d", len(m), loaded.Len())
+		}
+		for k, v := range m {
+			if got := loaded.Get(k); got != v {
+				t.Errorf("Get(%v): expected value of %d bytes, got %d", k, len(v), len(got))
+			}
+		}
+	}
+
+	set(0)
+	check()
+
+	/* Rewritten values reuse pages freed by the previous checkpoint. */
+	set(1)
+	check()
+	count := p.PageCount()
+	set(2)
+	check()
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages after overwrite, got %d", count, p.PageCount())
+	}
+
+	for k := range m {
+		if k%3 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+	}
+	check()
+	set(3)
+	check()
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages after delete, got %d", count, p.PageCount())
+	}
+
+	/* Keys are never moved out of leaves. */
+	var big Tree[string, int]
+	big.Store = p
+	big.Set(strings.Repeat("x", pager.MinPageSize), 0)
+	if err := big.Flush(); !errors.Is(err, ErrPageOverflow) {
+		t.Errorf("expected error %v, got %v", ErrPageOverflow, err)
+	}
+}
+
+/* writeCounter counts pages written to Store. */
+type writeCounter struct {
+	pager.Store
+	Writes int
+}
+
+func (w *writeCounter) Write(id pager.PageID, data []byte) error {
+	w.Writes++
+	return w.Store.Write(id, data)
+}
+
+func TestBplusFlushKeepsOverflow(t *testing.T) {
+	/* Height of the tree, while rewriting values of a leaf takes more than 20 pages. */
+	const MaxWrites = 6
+
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), pager.MinPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, string]
+	bt.Order = 8
+	bt.Store = &writeCounter{Store: p}
+
+	/* Every value takes several overflow pages. */
+	m := make(map[int]string)
+	for i := 0; i < constants.N/16; i++ {
+		m[i] = strings.Repeat(string(rune('a'+i%26)), 3*pager.MinPageSize)
+		bt.Set(i, m[i])
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	for _, store := range [...]string{"memory", "disk"} {
+		if store == "disk" {
+			if err := bt.Load(); err != nil {
+				t.Fatalf("failed to load tree: %v", err)
+			}
+		}
+
+		/* Only the leaf and its few ancestors are written, other values of the leaf keep their chains. */
+		bt.Store.(*writeCounter).Writes = 0
+		m[0] = "small"
+		bt.Set(0, m[0])
+		if err := bt.Flush(); err != nil {
+			t.Fatalf("failed to flush tree: %v", err)
+		}
+		if writes := bt.Store.(*writeCounter).Writes; writes > MaxWrites {
+			t.Errorf("%s: expected at most %d pages to be written, got %d", store, MaxWrites, writes)
+		}
+	}
+
+	var loaded Tree[int, string]
+	loaded.Order = bt.Order
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	for k, v := range m {
+		if got := loaded.Get(k); got != v {
+			t.Errorf("Get(%v): expected value of %d bytes, got %d", k, len(v), len(got))
+		}
+	}
+}
+
+/* plainCodec hides codec.Raw of the default codec, so that keys are stored with their lengths and without prefixes. */
+type plainCodec[T any] struct {
+	codec.Codec[T]
+}
+
+func TestBplusFlushRawKeys(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	prefix := strings.Repeat("https://example.com/", 4)
+	compares := [...]struct {
+		Name    string
+		Compare func(a, b string) int
+	}{
+		{"Ascending", strings.Compare},
+		{"Descending", func(a, b string) int { return strings.Compare(b, a) }},
+	}
+	for _, compare := range compares {
+		t.Run(compare.Name, func(t *testing.T) {
+			bt := NewFunc[string, int](compare.Compare)
+			bt.Order = 64
+			bt.Store = p
+
+			/* Keys share a long prefix, except for the empty one and a few that are not under it. */
+			m := map[string]int{"": -1}
+			for i := 0; i < constants.N/4; i++ {
+				k := fmt.Sprintf("%s%d", prefix, i)
+				if i%100 == 0 {
+					k = k[len(prefix)-1:]
+				}
+				m[k] = i
+				bt.Set(k, i)
+			}
+			bt.Set("", -1)
+			if err := bt.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+
+			loaded := NewFunc[string, int](compare.Compare)
+			loaded.Order = bt.Order
+			loaded.Store = p
+			if err := loaded.Load(); err != nil {
+				t.Fatalf("failed to load tree: %v", err)
+			}
+			if loaded.Len() != len(m) {
+				t.Errorf("expected %d keys, got %d", len(m), loaded.Len())
+			}
+			for k, v := range m {
+				if got, ok := loaded.Lookup(k); !ok || (got != v) {
+					t.Errorf("Lookup(%q): expected value %v, got %v, %v", k, v, got, ok)
+				}
+			}
+
+			/* Leaves under the prefix store it once instead of with every key. */
+			leaf, _, _ := loaded.search(prefix + "5000")
+			raw, err := loaded.encodeLeaf(nil, leaf)
+			if err != nil {
+				t.Fatalf("failed to encode leaf: %v", err)
+			}
+			loaded.KeyCodec = plainCodec[string]{codec.Default[string]{}}
+			loaded.initCodecs()
+			plain, err := loaded.encodeLeaf(nil, leaf)
+			if err != nil {
+				t.Fatalf("failed to encode leaf: %v", err)
+			}
+			if saved := (len(leaf.Keys) - 2) * len(prefix); len(plain)-len(raw) < saved {
+				t.Errorf("expected prefix to save %d bytes, got %d", saved, len(plain)-len(raw))
+			}
+			if _, err := loaded.readPage(loaded.Root.(*Node[string]).ID); err != nil {
+				t.Errorf("expected node to be readable without raw keys, got %v", err)
+			}
+			if _, err := loaded.decodePage(leaf.ID, append(raw, make([]byte, p.PageSize())...)); !errors.Is(err, ErrCorrupted) {
+				t.Errorf("expected error %v, got %v", ErrCorrupted, err)
+			}
+		})
+	}
+
+	/* Byte slice keys are stored raw as well. */
+	bt := NewFunc[[]byte, int](bytes.Compare)
+	bt.Store = p
+	for i := 0; i < constants.N/4; i++ {
+		bt.Set([]byte(fmt.Sprintf("%s%d", prefix, i)), i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	loaded := NewFunc[[]byte, int](bytes.Compare)
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	for i := 0; i < constants.N/4; i++ {
+		if got, ok := loaded.Lookup([]byte(fmt.Sprintf("%s%d", prefix, i))); !ok || (got != i) {
+			t.Errorf("Lookup(%d): expected value %v, got %v, %v", i, i, got, ok)
+		}
+	}
+}
+
+func TestBplusFlushIterate(t *testing.T) {
+	for _, order := range [...]int{3, 4, 5, 8} {
+		t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+			p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 0)
+			if err != nil {
+				t.Fatalf("failed to open pager: %v", err)
+			}
+			defer p.Close()
+
+			var bt Tree[int, int]
+			bt.Order = order
+			bt.Store = p
+
+			/* Leaves are read, copied for snapshots, split and merged by batches between checkpoints. */
+			rng := rand.New(rand.NewSource(constants.Seed + int64(order)))
+			m := make(map[int]int)
+			var views []*View[int, int]
+			for i := 0; i < constants.N; i++ {
+				k := rng.Intn(constants.N / 8)
+				switch op := rng.Intn(100); {
+				case op < 40:
+					m[k] = i
+					bt.Set(k, i)
+				case op < 70:
+					delete(m, k)
+					bt.Del(k)
+				case op < 80:
+					pairs := make(map[int]int)
+					for j := 0; j < 16; j++ {
+						pairs[k+j] = i
+						m[k+j] = i
+					}
+					bt.SetMany(maps.All(pairs))
+				case op < 90:
+					keys := make([]int, 16)
+					for j := range keys {
+						keys[j] = k + 2*j
+						delete(m, k+2*j)
+					}
+					bt.DelMany(slices.Values(keys))
+				case op < 95:
+					if err := bt.Flush(); err != nil {
+						t.Fatalf("failed to flush tree: %v", err)
+					}
+				default:
+					views = append(views, bt.Snapshot())
+				}
+
+				if i%(constants.N/32) != 0 {
+					continue
+				}
+				keys := slices.Sorted(maps.Keys(m))
+				var j int
+				for k, v := range bt.All() {
+					if (j >= len(keys)) || (keys[j] != k) || (m[k] != v) {
+						t.Fatalf("All: unexpected pair %v: %v at %d", k, v, j)
+					}
+					j++
+				}
+				if j != len(keys) {
+					t.Fatalf("All: expected %d keys, got %d", len(keys), j)
+				}
+				j = len(keys) - 1
+				c := bt.Cursor()
+				for c.Last(); c.Valid(); c.Prev() {
+					if (j < 0) || (keys[j] != c.Key()) {
+						t.Fatalf("Cursor: expected key %v, got %v", keys[j], c.Key())
+					}
+					j--
+				}
+				if j != -1 {
+					t.Fatalf("Cursor: %d keys are missing", j+1)
+				}
+			}
+			if len(views) == 0 {
+				t.Errorf("expected snapshots to be taken")
+			}
+		})
+	}
+}
+
+/* checkSized verifies that every leaf below 'page' fits into a page and returns the largest number of keys in a leaf. */
+func checkSized(t *testing.T, bt *Tree[string, int], page Page) int {
+	t.Helper()
+
+	switch p := page.(type) {
+	case *Node[string]:
+		var most int
+		for i := -1; i < len(p.Children); i++ {
+			most = max(most, checkSized(t, bt, bt.child(p, i)))
+		}
+		return most
+	case *Leaf[string, int]:
+		buf, err := bt.encodeLeaf(nil, p)
+		if err != nil {
+			t.Fatalf("failed to encode leaf: %v", err)
+		}
+		if len(buf) > bt.Store.PageSize() {
+			t.Errorf("leaf with %d keys takes %d bytes, page size is %d", len(p.Keys), len(buf), bt.Store.PageSize())
+		}
+		return len(p.Keys)
+	}
+	return 0
+}
+
+func TestBplusSizedLeaves(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "bplus.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	key := func(long bool) string {
+		if !long {
+			return fmt.Sprintf("%06d", rng.Intn(constants.N))
+		}
+		buf := make([]byte, 100+rng.Intn(100))
+		for i := range buf {
+			buf[i] = byte('a' + rng.Intn(26))
+		}
+		return string(buf)
+	}
+
+	for _, long := range [...]bool{false, true} {
+		t.Run(fmt.Sprintf("Long-%t", long), func(t *testing.T) {
+			bt := New[string, int]()
+			bt.Store = p
+
+			m := make(map[string]int)
+			check := func(phase string) {
+				t.Helper()
+
+				if err := bt.Flush(); err != nil {
+					t.Fatalf("%s: failed to flush tree: %v", phase, err)
+				}
+				loaded := New[string, int]()
+				loaded.Store = p
+				if err := loaded.Load(); err != nil {
+					t.Fatalf("%s: failed to load tree: %v", phase, err)
+				}
+
+				/* Short keys fill leaves over the order, long ones split them before it. */
+				most := checkSized(t, loaded, loaded.Root)
+				if (!long) && (len(m) > 2*DefaultOrder) && (most < DefaultOrder) {
+					t.Errorf("%s: expected leaves to hold more than %d keys, got %d", phase, DefaultOrder-1, most)
+				}
+
+				keys := slices.Sorted(maps.Keys(m))
+				var i int
+				for k, v := range loaded.All() {
+					if (i >= len(keys)) || (keys[i] != k) || (m[k] != v) {
+						t.Fatalf("%s: unexpected pair %q: %v at %d", phase, k, v, i)
+					}
+					i++
+				}
+				if i != len(keys) {
+					t.Errorf("%s: expected %d keys, got %d", phase, len(keys), i)
+				}
+			}
+
+			for i := 0; i < constants.N/8; i++ {
+				k := key(long)
+				m[k] = i
+				bt.Set(k, i)
+			}
+			check("Set")
+
+			var i int
+			for k := range m {
+				if i%3 != 0 {
+					delete(m, k)
+					bt.Del(k)
+				}
+				i++
+			}
+			check("Del")
+
+			pairs := make(map[string]int)
+			for i := 0; i < constants.N/8; i++ {
+				pairs[key(long)] = i
+			}
+			maps.Copy(m, pairs)
+			bt.SetMany(maps.All(pairs))
+			check("SetMany")
+
+			var keys []string
+			for k := range m {
+				if len(keys) < 3*len(m)/4 {
+					keys = append(keys, k)
+					delete(m, k)
+				}
+			}
+			bt.DelMany(slices.Values(keys))
+			check("DelMany")
+
+			sorted := func(yield func(string, int) bool) {
+				for _, k := range slices.Sorted(maps.Keys(m)) {
+					if !yield(k, m[k]) {
+						return
+					}
+				}
+			}
+			if err := bt.BulkLoad(sorted, 1); err != nil {
+				t.Fatalf("failed to load tree: %v", err)
+			}
+			check("BulkLoad")
+		})
+	}
+}
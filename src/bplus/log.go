@@ -0,0 +1,191 @@
+package bplus
+
+import (
+	"errors"
+	"fmt"
+
+	"wal"
+)
+
+var ErrNoLog = errors.New("tree has no log")
+
+/* undo is the state of a key before modification made in batch, see AbortBatch. */
+type undo[K any, V any] struct {
+	Key   K
+	Value V
+	Had   bool
+}
+
+/* AbortBatch undoes modifications applied since BeginBatch, restoring them in memory without logging. Recovery undoes uncommitted batch on its own. */
+func (t *Tree[K, V]) AbortBatch() error {
+	t.batch = false
+
+	/* Errors of undo are reported separately from errors that have led to it. */
+	saved := t.err
+	t.err = nil
+
+	log := t.Log
+	t.Log = nil
+	for i := len(t.undo) - 1; i >= 0; i-- {
+		u := &t.undo[i]
+		if u.Had {
+			t.Set(u.Key, u.Value)
+		} else {
+			t.Del(u.Key)
+		}
+	}
+	t.Log = log
+	t.undo = t.undo[:0]
+
+	err := t.err
+	if saved != nil {
+		t.err = saved
+	}
+	if err != nil {
+		return fmt.Errorf("failed to undo batch: %w", err)
+	}
+	return nil
+}
+
+/* BeginBatch groups following modifications into a single logged transaction, which is either recovered or undone as a whole. */
+func (t *Tree[K, V]) BeginBatch() {
+	t.txn++
+	t.batch = true
+	t.batchErr = nil
+	t.undo = t.undo[:0]
+}
+
+/*
+ * CommitBatch ends batch started with BeginBatch. Error means that some modifications were not applied and batch will
+ * be undone by recovery. Applied ones stay in memory until AbortBatch.
+ */
+func (t *Tree[K, V]) CommitBatch() error {
+	t.batch = false
+	if t.batchErr != nil {
+		return t.batchErr
+	}
+
+	if t.Log != nil {
+		if _, err := t.Log.Append(&wal.Record{Type: wal.RecordCommit, Txn: t.txn}); err != nil {
+			t.setErr(err)
+			return err
+		}
+	}
+	t.undo = t.undo[:0]
+	return nil
+}
+
+/* Err returns the first error that prevented modification from being logged or page from being read. Such modifications are not applied. */
+func (t *Tree[K, V]) Err() error {
+	return t.err
+}
+
+/* logRecord logs modification of 'key' and, in batch, remembers its previous state for AbortBatch. Returns false if logging has failed. */
+func (t *Tree[K, V]) logRecord(typ wal.RecordType, key K, value V, old V, hasOld bool) bool {
+	if t.batch {
+		t.undo = append(t.undo, undo[K, V]{Key: key, Value: old, Had: hasOld})
+	}
+	if t.Log == nil {
+		return true
+	}
+	t.initCodecs()
+
+	buf := t.KeyCodec.Append(t.logBuf[:0], key)
+	keyEnd := len(buf)
+	if typ == wal.RecordSet {
+		buf = t.ValueCodec.Append(buf, value)
+	}
+	valueEnd := len(buf)
+	if hasOld {
+		buf = t.ValueCodec.Append(buf, old)
+	}
+	t.logBuf = buf
+
+	if !t.batch {
+		t.txn++
+	}
+	r := wal.Record{Type: typ, Txn: t.txn, Key: buf[:keyEnd], Value: buf[keyEnd:valueEnd], Old: buf[valueEnd:], HasOld: hasOld}
+	if _, err := t.Log.Append(&r); err != nil {
+		if t.batch {
+			t.undo = t.undo[:len(t.undo)-1]
+		}
+		t.setErr(err)
+		return false
+	}
+	if !t.batch {
+		if _, err := t.Log.Append(&wal.Record{Type: wal.RecordCommit, Txn: t.txn}); err != nil {
+			t.setErr(err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t *Tree[K, V]) logDel(key K, old V) bool {
+	var zero V
+	return t.logRecord(wal.RecordDel, key, zero, old, true)
+}
+
+func (t *Tree[K, V]) logSet(key K, value V, old V, hasOld bool) bool {
+	return t.logRecord(wal.RecordSet, key, value, old, hasOld)
+}
+
+func (t *Tree[K, V]) redo(r wal.Record) error {
+	key, _, err := t.KeyCodec.Decode(r.Key)
+	if err != nil {
+		return fmt.Errorf("failed to decode key at LSN %d: %w", r.LSN, err)
+	}
+
+	switch r.Type {
+	case wal.RecordSet:
+		value, _, err := t.ValueCodec.Decode(r.Value)
+		if err != nil {
+			return fmt.Errorf("failed to decode value at LSN %d: %w", r.LSN, err)
+		}
+		t.Set(key, value)
+	case wal.RecordDel:
+		t.Del(key)
+	}
+
+	return nil
+}
+
+func (t *Tree[K, V]) setErr(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+	if t.batch && (t.batchErr == nil) {
+		t.batchErr = err
+	}
+}
+
+/* Recover loads the last checkpoint from Store, if any, and replays Log on top of it. */
+func (t *Tree[K, V]) Recover() error {
+	if t.Log == nil {
+		return ErrNoLog
+	}
+	t.init()
+	t.initCodecs()
+
+	var from wal.LSN
+	if t.Store != nil {
+		if err := t.Load(); err != nil {
+			return err
+		}
+		from = wal.LSN(t.Store.LSN())
+	} else {
+		t.Clear()
+	}
+
+	log := t.Log
+	t.Log = nil
+	txn, err := log.Recover(from, t.redo)
+	t.Log = log
+	if err != nil {
+		return fmt.Errorf("failed to recover tree: %w", err)
+	}
+	t.txn = max(t.txn, txn)
+
+	return nil
+}
@@ -0,0 +1,260 @@
+package bplus
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"constants"
+	"generator"
+	"pager"
+	"wal"
+)
+
+type logOp struct {
+	Del   bool
+	Key   int
+	Value int
+
+	/* Log position right after the operation. */
+	End wal.LSN
+}
+
+/* Number of random log truncations checked per test. */
+const Truncations = 16
+
+func copyFile(t *testing.T, dst, src string) {
+	t.Helper()
+
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		t.Fatalf("failed to copy file: %v", err)
+	}
+}
+
+func testBplusRecover(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	dir := t.TempDir()
+	p, err := pager.Open(filepath.Join(dir, "bplus.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	l, err := wal.Open(filepath.Join(dir, "bplus.log"))
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Store = p
+	bt.Log = l
+
+	/* Apply half of operations before checkpoint and half after it. */
+	var ops []logOp
+	checkpoint := 0
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		bt.Set(k, i)
+		ops = append(ops, logOp{Key: k, Value: i, End: l.End()})
+		keys = append(keys, k)
+
+		if i%4 == 3 {
+			k := keys[i/2]
+			bt.Del(k)
+			ops = append(ops, logOp{Del: true, Key: k, End: l.End()})
+		}
+		if i == constants.N/2 {
+			if err := bt.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+			checkpoint = len(ops)
+		}
+	}
+	if err := bt.Err(); err != nil {
+		t.Fatalf("failed to log operation: %v", err)
+	}
+	base := ops[checkpoint-1].End
+	end := l.End()
+	p.Close()
+	l.Close()
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for i := 0; i < Truncations; i++ {
+		cut := base + wal.LSN(rng.Int63n(int64(end-base)+1))
+
+		crashed := t.TempDir()
+		copyFile(t, filepath.Join(crashed, "bplus.db"), filepath.Join(dir, "bplus.db"))
+		copyFile(t, filepath.Join(crashed, "bplus.log"), filepath.Join(dir, "bplus.log"))
+		/* Log header is 16 bytes, LSNs count from 'base' after checkpoint. */
+		if err := os.Truncate(filepath.Join(crashed, "bplus.log"), 16+int64(cut-base)); err != nil {
+			t.Fatalf("failed to truncate log: %v", err)
+		}
+
+		p, err := pager.Open(filepath.Join(crashed, "bplus.db"), 0)
+		if err != nil {
+			t.Fatalf("failed to open pager: %v", err)
+		}
+		l, err := wal.Open(filepath.Join(crashed, "bplus.log"))
+		if err != nil {
+			t.Fatalf("failed to open log: %v", err)
+		}
+
+		var recovered Tree[int, int]
+		recovered.Order = order
+		recovered.Store = p
+		recovered.Log = l
+		if err := recovered.Recover(); err != nil {
+			t.Fatalf("failed to recover tree: %v", err)
+		}
+
+		m := make(map[int]int)
+		for _, op := range ops {
+			if op.End > cut {
+				break
+			}
+			if op.Del {
+				delete(m, op.Key)
+			} else {
+				m[op.Key] = op.Value
+			}
+		}
+		for k, v := range m {
+			if got := recovered.Get(k); got != v {
+				t.Errorf("cut at %d: expected value %v for key %v, got %v", cut, v, k, got)
+			}
+		}
+
+		n := 0
+		for range recovered.All() {
+			n++
+		}
+		if n != len(m) {
+			t.Errorf("cut at %d: expected %d keys, got %d", cut, len(m), n)
+		}
+
+		p.Close()
+		l.Close()
+	}
+}
+
+func TestBplusRecover(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testBplusRecover(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+/* Number of operations in a batch for TestBplusRecoverBatch. */
+const BatchSize = 8
+
+func TestBplusRecoverBatch(t *testing.T) {
+	dir := t.TempDir()
+	l, err := wal.Open(filepath.Join(dir, "bplus.log"))
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+
+	var bt Tree[int, int]
+	bt.Log = l
+
+	var ops []logOp
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < constants.N; i += BatchSize {
+		bt.BeginBatch()
+		for j := 0; j < BatchSize; j++ {
+			k := g.Generate() % constants.N
+			if j%3 == 2 {
+				bt.Del(k)
+				ops = append(ops, logOp{Del: true, Key: k})
+			} else {
+				bt.Set(k, i+j)
+				ops = append(ops, logOp{Key: k, Value: i + j})
+			}
+		}
+		if err := bt.CommitBatch(); err != nil {
+			t.Fatalf("failed to commit batch: %v", err)
+		}
+		/* Batches become visible as a whole, so only the last operation of a batch gets its end. */
+		ops[len(ops)-1].End = l.End()
+	}
+	end := l.End()
+	l.Close()
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for i := 0; i < Truncations; i++ {
+		cut := wal.LSN(rng.Int63n(int64(end) + 1))
+
+		crashed := t.TempDir()
+		copyFile(t, filepath.Join(crashed, "bplus.log"), filepath.Join(dir, "bplus.log"))
+		if err := os.Truncate(filepath.Join(crashed, "bplus.log"), 16+int64(cut)); err != nil {
+			t.Fatalf("failed to truncate log: %v", err)
+		}
+		l, err := wal.Open(filepath.Join(crashed, "bplus.log"))
+		if err != nil {
+			t.Fatalf("failed to open log: %v", err)
+		}
+
+		var recovered Tree[int, int]
+		recovered.Log = l
+		if err := recovered.Recover(); err != nil {
+			t.Fatalf("failed to recover tree: %v", err)
+		}
+
+		m := make(map[int]int)
+		for b := 0; b+BatchSize <= len(ops); b += BatchSize {
+			if ops[b+BatchSize-1].End > cut {
+				break
+			}
+			for _, op := range ops[b : b+BatchSize] {
+				if op.Del {
+					delete(m, op.Key)
+				} else {
+					m[op.Key] = op.Value
+				}
+			}
+		}
+		for k := 0; k < constants.N; k++ {
+			v, ok := m[k]
+			if recovered.Has(k) != ok {
+				t.Errorf("cut at %d: expected presence of key %v to be %v", cut, k, ok)
+			} else if got := recovered.Get(k); got != v {
+				t.Errorf("cut at %d: expected value %v for key %v, got %v", cut, v, k, got)
+			}
+		}
+
+		l.Close()
+	}
+}
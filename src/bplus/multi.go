@@ -0,0 +1,215 @@
+package bplus
+
+import (
+	"cmp"
+	"iter"
+)
+
+/*
+ * MultiTree is a B+tree which keeps any number of values per key, e.g. for secondary indexes. Values of the same key
+ * are stored next to each other, spilling across as many leaves as needed, in the order they were added or, if
+ * Compare is set, in the order of Compare.
+ *
+ * With duplicates the separator between two pages is only known to be no less than keys on the left and no greater
+ * than keys on the right, so the first value of a key is searched for to the left of separators equal to it.
+ */
+type MultiTree[K cmp.Ordered, V comparable] struct {
+	Order int
+
+	/* Optional order of values of the same key. */
+	Compare func(a, b V) int
+
+	tree Tree[K, V]
+}
+
+func (t *MultiTree[K, V]) init() {
+	if t.tree.Root == nil {
+		t.tree.Order = t.Order
+	}
+	t.tree.init()
+}
+
+/*
+ * first descends to the leaf where the first value of 'key' is or would be, filling SearchPath if 'path' is set.
+ * Returns the leaf and index of the last key < 'key' in it.
+ */
+func (t *MultiTree[K, V]) first(key K, path bool) (*Leaf[K, V], int) {
+	page := t.tree.Root
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			index := t.tree.findOnNode(p, key)
+			for (index >= 0) && (p.Keys[index] == key) {
+				index--
+			}
+			if index == -1 {
+				page = p.ChildPage0
+			} else {
+				page = p.Children[index]
+			}
+			if path {
+				t.tree.SearchPath = append(t.tree.SearchPath, PathItem[K]{Node: p, Index: index})
+			}
+		case *Leaf[K, V]:
+			/* Linear search takes shortcut for the last key, which is wrong with duplicates. */
+			index, _ := findOnLeafBinary(p, key)
+			return p, index
+		}
+	}
+}
+
+/* last descends to the leaf where the last value of 'key' is or would be, filling SearchPath. Returns the leaf and position after the last value. */
+func (t *MultiTree[K, V]) last(key K) (*Leaf[K, V], int) {
+	page := t.tree.Root
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			index := t.tree.findOnNode(p, key)
+			if index == -1 {
+				page = p.ChildPage0
+			} else {
+				page = p.Children[index]
+			}
+			t.tree.SearchPath = append(t.tree.SearchPath, PathItem[K]{Node: p, Index: index})
+		case *Leaf[K, V]:
+			index, _ := findOnLeafBinary(p, key)
+			pos := index + 1
+			for (pos < len(p.Keys)) && (p.Keys[pos] == key) {
+				pos++
+			}
+			return p, pos
+		}
+	}
+}
+
+/* advance moves SearchPath to the next leaf, unless separator before it is greater than 'key'. Returns the leaf or nil. */
+func (t *MultiTree[K, V]) advance(key K) *Leaf[K, V] {
+	path := t.tree.SearchPath
+
+	p := len(path) - 1
+	for (p >= 0) && (path[p].Index == len(path[p].Node.Keys)-1) {
+		p--
+	}
+	if (p < 0) || (key < path[p].Node.Keys[path[p].Index+1]) {
+		return nil
+	}
+
+	path[p].Index++
+	page := path[p].Node.Children[path[p].Index]
+	path = path[:p+1]
+	for {
+		node, ok := page.(*Node[K])
+		if !ok {
+			break
+		}
+		path = append(path, PathItem[K]{Node: node, Index: -1})
+		page = node.ChildPage0
+	}
+	t.tree.SearchPath = path
+
+	return page.(*Leaf[K, V])
+}
+
+/* Add adds 'value' to values of 'key', after the existing ones or, if Compare is set, after the ones not greater than it. */
+func (t *MultiTree[K, V]) Add(key K, value V) {
+	t.init()
+	if t.tree.Root == nil {
+		t.tree.Set(key, value)
+		return
+	}
+
+	var leaf *Leaf[K, V]
+	var pos int
+	if t.Compare == nil {
+		leaf, pos = t.last(key)
+	} else {
+		var index int
+		leaf, index = t.first(key, true)
+		for pos = index + 1; ; pos++ {
+			if pos == len(leaf.Keys) {
+				next := t.advance(key)
+				if next == nil {
+					break
+				}
+				leaf, pos = next, 0
+			}
+			if (leaf.Keys[pos] != key) || (t.Compare(leaf.Values[pos], value) > 0) {
+				break
+			}
+		}
+	}
+
+	leaf = t.tree.ownPath(leaf)
+	t.tree.insert(leaf, pos, key, value)
+}
+
+/* All returns iterator over all key-value pairs ordered by key, values of the same key in their order. */
+func (t *MultiTree[K, V]) All() iter.Seq2[K, V] {
+	return t.tree.All()
+}
+
+func (t *MultiTree[K, V]) Clear() {
+	t.tree.Clear()
+}
+
+/* GetAll returns iterator over values of 'key' in their order. */
+func (t *MultiTree[K, V]) GetAll(key K) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		if t.tree.Root == nil {
+			return
+		}
+
+		c := t.tree.Cursor()
+		c.leaf, c.index = t.first(key, false)
+		for c.Next(); (c.Valid()) && (c.Key() == key); c.Next() {
+			if !yield(c.Value()) {
+				return
+			}
+		}
+	}
+}
+
+func (t *MultiTree[K, V]) Has(key K) bool {
+	for range t.GetAll(key) {
+		return true
+	}
+	return false
+}
+
+/* Len returns number of key-value pairs. */
+func (t *MultiTree[K, V]) Len() int {
+	return t.tree.Len()
+}
+
+/* Remove removes the first of values of 'key' equal to 'value'. Returns false if there is no such value. */
+func (t *MultiTree[K, V]) Remove(key K, value V) bool {
+	t.init()
+	if t.tree.Root == nil {
+		return false
+	}
+
+	leaf, index := t.first(key, true)
+	for pos := index + 1; ; pos++ {
+		if pos == len(leaf.Keys) {
+			if leaf = t.advance(key); leaf == nil {
+				return false
+			}
+			pos = 0
+		}
+		if leaf.Keys[pos] != key {
+			return false
+		}
+		if leaf.Values[pos] == value {
+			leaf = t.tree.ownPath(leaf)
+			t.tree.remove(leaf, pos)
+			return true
+		}
+		if (t.Compare != nil) && (t.Compare(leaf.Values[pos], value) > 0) {
+			return false
+		}
+	}
+}
+
+func (t *MultiTree[K, V]) String() string {
+	return t.tree.String()
+}
@@ -0,0 +1,170 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* Keys is the number of distinct keys in multimap tests, so that every key gets many values. */
+const Keys = constants.N / 64
+
+/* checkBounds verifies that keys of every page are within separators around it. */
+func checkBounds(t *testing.T, page Page, lo, hi int) {
+	t.Helper()
+
+	switch p := page.(type) {
+	case *Node[int]:
+		for i := -1; i < len(p.Keys); i++ {
+			clo, chi, child := lo, hi, p.ChildPage0
+			if i >= 0 {
+				clo, child = p.Keys[i], p.Children[i]
+			}
+			if i+1 < len(p.Keys) {
+				chi = p.Keys[i+1]
+			}
+			if clo > chi {
+				t.Errorf("separators %v and %v are out of order", clo, chi)
+			}
+			checkBounds(t, child, clo, chi)
+		}
+	case *Leaf[int, int]:
+		for _, key := range p.Keys {
+			if (key < lo) || (key > hi) {
+				t.Errorf("key %v is out of [%v, %v]", key, lo, hi)
+			}
+		}
+	}
+}
+
+func checkMulti(t *testing.T, mt *MultiTree[int, int], m map[int][]int) {
+	t.Helper()
+
+	checkBounds(t, mt.tree.Root, math.MinInt, math.MaxInt)
+	checkSizes[int, int](t, mt.tree.Root)
+
+	var n int
+	keys := slices.Sorted(maps.Keys(m))
+	all := make([][2]int, 0, mt.Len())
+	for _, k := range keys {
+		for _, v := range m[k] {
+			all = append(all, [2]int{k, v})
+		}
+		n += len(m[k])
+
+		if got := slices.Collect(mt.GetAll(k)); !slices.Equal(got, m[k]) {
+			t.Errorf("GetAll(%v): expected %v, got %v", k, m[k], got)
+		}
+		if mt.Has(k) != (len(m[k]) > 0) {
+			t.Errorf("Has(%v): expected %v", k, len(m[k]) > 0)
+		}
+	}
+	if mt.Len() != n {
+		t.Errorf("expected length %d, got %d", n, mt.Len())
+	}
+
+	var i int
+	for k, v := range mt.All() {
+		if (i >= len(all)) || (all[i] != [2]int{k, v}) {
+			t.Errorf("All: unexpected pair %v: %v at %d", k, v, i)
+			return
+		}
+		i++
+	}
+}
+
+func testMultiTree(t *testing.T, g generator.Generator, order int, compare func(a, b int) int) {
+	t.Helper()
+
+	var mt MultiTree[int, int]
+	mt.Order = order
+	mt.Compare = compare
+
+	type pair struct{ Key, Value int }
+	var pairs []pair
+
+	m := make(map[int][]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate() % Keys
+		if i%4 == 0 {
+			/* Hot key spans many leaves at any order. */
+			k = 0
+		}
+		v := i % 100
+
+		mt.Add(k, v)
+		pairs = append(pairs, pair{k, v})
+		if compare == nil {
+			m[k] = append(m[k], v)
+		} else {
+			j := slices.IndexFunc(m[k], func(x int) bool { return compare(x, v) > 0 })
+			if j == -1 {
+				j = len(m[k])
+			}
+			m[k] = slices.Insert(m[k], j, v)
+		}
+	}
+	checkMulti(t, &mt, m)
+
+	for i, p := range pairs {
+		if i%3 != 0 {
+			continue
+		}
+		if !mt.Remove(p.Key, p.Value) {
+			t.Errorf("failed to remove %v: %v", p.Key, p.Value)
+		}
+		j := slices.Index(m[p.Key], p.Value)
+		m[p.Key] = slices.Delete(m[p.Key], j, j+1)
+	}
+	if mt.Remove(0, -1) || mt.Remove(-constants.N, 0) {
+		t.Errorf("removed missing value")
+	}
+	checkMulti(t, &mt, m)
+
+	for _, p := range pairs {
+		mt.Remove(p.Key, p.Value)
+	}
+	if mt.Len() != 0 {
+		t.Errorf("expected empty tree, got length %d", mt.Len())
+	}
+}
+
+func TestMultiTree(t *testing.T) {
+	modes := [...]struct {
+		Name    string
+		Compare func(a, b int) int
+	}{
+		{"Insertion", nil},
+		{"Value", cmp.Compare[int]},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, mode := range modes {
+		t.Run(mode.Name, func(t *testing.T) {
+			for _, generator := range generators {
+				t.Run(generator.String(), func(t *testing.T) {
+					for _, order := range orders {
+						t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+							generator.Reset()
+							testMultiTree(t, generator, order, mode.Compare)
+						})
+					}
+				})
+			}
+		})
+	}
+}
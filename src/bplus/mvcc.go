@@ -0,0 +1,248 @@
+package bplus
+
+import (
+	"cmp"
+	"errors"
+	"sync"
+)
+
+/* Version is one element of per-key version chain, newest first. */
+type Version[V any] struct {
+	Value   V
+	TS      uint64
+	Deleted bool
+
+	Prev *Version[V]
+}
+
+/*
+ * VersionedTree keeps every modification as a new version, stamped with increasing timestamp. Snapshots see only
+ * versions that were current at their timestamp, no matter how many modifications happen after they were opened.
+ * All methods are safe for concurrent use. Readers share the read lock only while they find the newest version of a
+ * key and resolve the version visible to them after releasing it: versions never change once they are pushed, and GC
+ * cuts chains only below versions visible to open snapshots.
+ */
+type VersionedTree[K cmp.Ordered, V any] struct {
+	Order int
+
+	mu   sync.RWMutex
+	tree Tree[K, *Version[V]]
+	ts   uint64
+
+	/* Reference counts of open snapshots by timestamp. */
+	snapshots map[uint64]int
+	horizon   uint64
+}
+
+type Snapshot[K cmp.Ordered, V any] struct {
+	TS uint64
+
+	tree *VersionedTree[K, V]
+
+	/* Protected by the lock of the tree. */
+	closed bool
+}
+
+var (
+	ErrSnapshotClosed = errors.New("snapshot is closed")
+	ErrSnapshotTooOld = errors.New("snapshot timestamp is older than garbage collection horizon")
+)
+
+/* visible returns newest version of chain 'v' with timestamp not after 'ts'. */
+func visible[V any](v *Version[V], ts uint64) *Version[V] {
+	for (v != nil) && (v.TS > ts) {
+		v = v.Prev
+	}
+	return v
+}
+
+func (t *VersionedTree[K, V]) init() {
+	if t.snapshots == nil {
+		t.tree.Order = t.Order
+		t.snapshots = make(map[uint64]int)
+	}
+}
+
+/* newest returns the newest version of 'key', if any. */
+func (t *VersionedTree[K, V]) newest(key K) *Version[V] {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	/* Tree is initialized by its first Set. */
+	if t.tree.Root == nil {
+		return nil
+	}
+	v, _ := t.tree.lookup(key)
+	return v
+}
+
+func (t *VersionedTree[K, V]) push(key K, value V, deleted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	prev := t.tree.Get(key)
+	if (deleted) && ((prev == nil) || (prev.Deleted)) {
+		return
+	}
+
+	t.ts++
+	t.tree.Set(key, &Version[V]{Value: value, TS: t.ts, Deleted: deleted, Prev: prev})
+}
+
+/* Clear deletes all keys in one modification, so open snapshots still see them. GC removes them with their histories. */
+func (t *VersionedTree[K, V]) Clear() {
+	var zero V
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	ts := t.ts + 1
+	for leaf := t.tree.Begin(); leaf != t.tree.End(); leaf = leaf.Next {
+		for i := 0; i < len(leaf.Values); i++ {
+			if prev := leaf.Values[i]; !prev.Deleted {
+				leaf.Values[i] = &Version[V]{Value: zero, TS: ts, Deleted: true, Prev: prev}
+				t.ts = ts
+			}
+		}
+	}
+}
+
+func (t *VersionedTree[K, V]) Del(key K) {
+	var zero V
+	t.push(key, zero, true)
+}
+
+/*
+ * GC drops versions that no open snapshot can see: for every key it keeps versions newer than the oldest snapshot and
+ * the one visible to that snapshot. Keys whose visible version is a tombstone with no newer versions are removed.
+ */
+func (t *VersionedTree[K, V]) GC() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	horizon := t.ts
+	for ts := range t.snapshots {
+		horizon = min(horizon, ts)
+	}
+	t.horizon = horizon
+
+	var dead []K
+	for leaf := t.tree.Begin(); leaf != t.tree.End(); leaf = leaf.Next {
+		for i := 0; i < len(leaf.Keys); i++ {
+			v := leaf.Values[i]
+			if v.TS <= horizon {
+				if v.Deleted {
+					dead = append(dead, leaf.Keys[i])
+				}
+				v.Prev = nil
+				continue
+			}
+
+			for v.Prev != nil {
+				if v.Prev.TS <= horizon {
+					v.Prev.Prev = nil
+					break
+				}
+				v = v.Prev
+			}
+		}
+	}
+
+	for _, key := range dead {
+		t.tree.Del(key)
+	}
+}
+
+/* Get returns the latest value of the key. */
+func (t *VersionedTree[K, V]) Get(key K) V {
+	var zero V
+
+	v := t.newest(key)
+	if (v == nil) || (v.Deleted) {
+		return zero
+	}
+	return v.Value
+}
+
+func (t *VersionedTree[K, V]) Has(key K) bool {
+	v := t.newest(key)
+	return (v != nil) && (!v.Deleted)
+}
+
+func (t *VersionedTree[K, V]) Set(key K, value V) {
+	t.push(key, value, false)
+}
+
+/* Snapshot opens snapshot at the current timestamp. It must be closed to let GC reclaim versions it sees. */
+func (t *VersionedTree[K, V]) Snapshot() *Snapshot[K, V] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	t.snapshots[t.ts]++
+	return &Snapshot[K, V]{TS: t.ts, tree: t}
+}
+
+/* SnapshotAt opens snapshot at past timestamp 'ts', as long as GC has not reclaimed versions it needs. */
+func (t *VersionedTree[K, V]) SnapshotAt(ts uint64) (*Snapshot[K, V], error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.init()
+	if ts < t.horizon {
+		return nil, ErrSnapshotTooOld
+	}
+	ts = min(ts, t.ts)
+	t.snapshots[ts]++
+	return &Snapshot[K, V]{TS: ts, tree: t}, nil
+}
+
+func (t *VersionedTree[K, V]) String() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.tree.String()
+}
+
+/* TS returns timestamp of the latest modification. */
+func (t *VersionedTree[K, V]) TS() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.ts
+}
+
+func (s *Snapshot[K, V]) Close() error {
+	t := s.tree
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s.closed {
+		return ErrSnapshotClosed
+	}
+	s.closed = true
+
+	t.snapshots[s.TS]--
+	if t.snapshots[s.TS] == 0 {
+		delete(t.snapshots, s.TS)
+	}
+	return nil
+}
+
+func (s *Snapshot[K, V]) Get(key K) V {
+	var zero V
+
+	v := visible(s.tree.newest(key), s.TS)
+	if (v == nil) || (v.Deleted) {
+		return zero
+	}
+	return v.Value
+}
+
+func (s *Snapshot[K, V]) Has(key K) bool {
+	v := visible(s.tree.newest(key), s.TS)
+	return (v != nil) && (!v.Deleted)
+}
@@ -0,0 +1,213 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+func versions[K cmp.Ordered, V any](vt *VersionedTree[K, V]) int {
+	var n int
+	for leaf := vt.tree.Begin(); leaf != vt.tree.End(); leaf = leaf.Next {
+		for _, v := range leaf.Values {
+			for ; v != nil; v = v.Prev {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func testVersionedSnapshot(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var vt VersionedTree[int, int]
+	vt.Order = order
+
+	/* Take snapshot after every quarter of operations and remember what it must see. */
+	var snapshots []*Snapshot[int, int]
+	var expected []map[int]int
+
+	m := make(map[int]int)
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		vt.Set(k, i)
+		m[k] = i
+		keys = append(keys, k)
+
+		if i%4 == 3 {
+			k := keys[i/2]
+			vt.Del(k)
+			delete(m, k)
+		}
+		if i%(constants.N/4) == 0 {
+			snapshots = append(snapshots, vt.Snapshot())
+			expected = append(expected, maps.Clone(m))
+		}
+	}
+
+	for i, s := range snapshots {
+		for _, k := range keys {
+			v, ok := expected[i][k]
+			if got := s.Has(k); got != ok {
+				t.Errorf("snapshot %d: expected presence of key %v to be %v, got %v", s.TS, k, ok, got)
+			}
+			if got := s.Get(k); got != v {
+				t.Errorf("snapshot %d: expected value %v for key %v, got %v", s.TS, v, k, got)
+			}
+		}
+	}
+
+	/* GC must keep everything the oldest snapshot sees. */
+	vt.GC()
+	for _, k := range keys {
+		if got, v := snapshots[0].Get(k), expected[0][k]; got != v {
+			t.Errorf("after GC: expected value %v for key %v, got %v", v, k, got)
+		}
+	}
+
+	for _, s := range snapshots {
+		if err := s.Close(); err != nil {
+			t.Fatalf("failed to close snapshot: %v", err)
+		}
+	}
+	if err := snapshots[0].Close(); err != ErrSnapshotClosed {
+		t.Errorf("expected %v on second close, got %v", ErrSnapshotClosed, err)
+	}
+
+	/* Without snapshots only the latest versions of live keys remain. */
+	vt.GC()
+	if n := versions(&vt); n != len(m) {
+		t.Errorf("expected %d versions after GC, got %d", len(m), n)
+	}
+	for k, v := range m {
+		if got := vt.Get(k); got != v {
+			t.Errorf("expected value %v for key %v, got %v", v, k, got)
+		}
+	}
+
+	if _, err := vt.SnapshotAt(0); err != ErrSnapshotTooOld {
+		t.Errorf("expected %v, got %v", ErrSnapshotTooOld, err)
+	}
+}
+
+func TestVersionedTree(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testVersionedSnapshot(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+func TestVersionedTreeClear(t *testing.T) {
+	var vt VersionedTree[int, int]
+	for k := 0; k < constants.N; k++ {
+		vt.Set(k, k)
+	}
+	vt.Del(0)
+
+	s := vt.Snapshot()
+	vt.Clear()
+	if vt.TS() != s.TS+1 {
+		t.Errorf("expected Clear to be one modification, got timestamps %d and %d", s.TS, vt.TS())
+	}
+	for k := 0; k < constants.N; k++ {
+		if vt.Has(k) {
+			t.Errorf("expected key %v to be removed", k)
+		}
+		if got := s.Get(k); (k > 0) && (got != k) {
+			t.Errorf("expected value %v in snapshot, got %v", k, got)
+		}
+	}
+
+	vt.GC()
+	/* Key 0 was deleted before the snapshot, every other one keeps its tombstone and the value snapshot sees. */
+	if n := versions(&vt); n != 2*(constants.N-1) {
+		t.Errorf("expected snapshot to keep %d versions, got %d", 2*(constants.N-1), n)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("failed to close snapshot: %v", err)
+	}
+	if err := s.Close(); err != ErrSnapshotClosed {
+		t.Errorf("expected error %v, got %v", ErrSnapshotClosed, err)
+	}
+	vt.GC()
+	if n := versions(&vt); n != 0 {
+		t.Errorf("expected GC to remove all versions, got %d", n)
+	}
+}
+
+/* Readers must observe the same values throughout the lifetime of their snapshots while writer keeps going. */
+func TestVersionedTreeConcurrent(t *testing.T) {
+	const Readers = 4
+
+	var vt VersionedTree[int, int]
+	for k := 0; k < constants.N; k++ {
+		vt.Set(k, 0)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < Readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				/* Writer sets all keys to the same value in increasing order, so snapshot sees a prefix of a round. */
+				s := vt.Snapshot()
+				seen := make([]int, 0, constants.N)
+				prev := s.Get(0)
+				for k := 0; k < constants.N; k++ {
+					v := s.Get(k)
+					if (v != prev) && (v != prev-1) {
+						t.Errorf("snapshot %d: unexpected value %v for key %v after %v", s.TS, v, k, prev)
+					}
+					seen = append(seen, v)
+					prev = v
+				}
+				for k := 0; k < constants.N; k++ {
+					if v := s.Get(k); v != seen[k] {
+						t.Errorf("snapshot %d: value of key %v changed from %v to %v", s.TS, k, seen[k], v)
+					}
+				}
+				s.Close()
+			}
+		}()
+	}
+
+	for round := 1; round <= 8; round++ {
+		for k := 0; k < constants.N; k++ {
+			vt.Set(k, round)
+		}
+		vt.GC()
+	}
+	close(done)
+	wg.Wait()
+}
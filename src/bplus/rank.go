@@ -0,0 +1,115 @@
+package bplus
+
+/* Every node keeps number of keys in its subtree, so order statistics take O(log n) page visits. */
+
+func pageSize[K any, V any](page Page) int {
+	switch p := page.(type) {
+	case *Node[K]:
+		return p.size
+	case *Leaf[K, V]:
+		return len(p.Keys)
+	case *pageRef:
+		return p.size
+	}
+	return 0
+}
+
+/* recount sets size of the node from sizes of its children. */
+func (t *Tree[K, V]) recount(n *Node[K]) {
+	n.size = pageSize[K, V](n.ChildPage0)
+	for i := 0; i < len(n.Children); i++ {
+		n.size += pageSize[K, V](n.Children[i])
+	}
+}
+
+/* rank returns number of keys less than 'key', or less than or equal to it if 'inclusive' is set. */
+func (t *Tree[K, V]) rank(key K, inclusive bool) int {
+	var rank int
+
+	page := t.Root
+	for page != nil {
+		switch p := page.(type) {
+		case *Node[K]:
+			index := t.findOnNode(p, key)
+			if index >= 0 {
+				rank += pageSize[K, V](p.ChildPage0)
+			}
+			for i := 0; i < index; i++ {
+				rank += pageSize[K, V](p.Children[i])
+			}
+			page = t.child(p, index)
+		case *Leaf[K, V]:
+			index, ok := t.findOnLeaf(p, key)
+			if (ok) && (inclusive) {
+				index++
+			}
+			return rank + index + 1
+		}
+	}
+
+	return rank
+}
+
+/* CountRange returns number of keys Range visits for the same arguments. */
+func (t *Tree[K, V]) CountRange(lo, hi K) int {
+	if t.Root == nil {
+		return 0
+	}
+	if t.Compare(lo, hi) <= 0 {
+		return t.rank(hi, false) - t.rank(lo, false)
+	}
+	return t.rank(lo, true) - t.rank(hi, true)
+}
+
+func (t *Tree[K, V]) Len() int {
+	return pageSize[K, V](t.Root)
+}
+
+/* Rank returns number of keys less than 'key'. */
+func (t *Tree[K, V]) Rank(key K) int {
+	return t.rank(key, false)
+}
+
+/* Select returns i-th smallest key, counting from zero. */
+func (t *Tree[K, V]) Select(i int) (K, V, bool) {
+	if (i < 0) || (i >= t.Len()) {
+		var k K
+		var v V
+		return k, v, false
+	}
+
+	page := t.Root
+	for {
+		switch p := page.(type) {
+		case *Node[K]:
+			j := -1
+			for size := pageSize[K, V](p.ChildPage0); i >= size; size = pageSize[K, V](p.Children[j]) {
+				i -= size
+				j++
+			}
+			page = t.child(p, j)
+		case *Leaf[K, V]:
+			return p.Keys[i], p.Values[i], true
+		default:
+			var k K
+			var v V
+			return k, v, false
+		}
+	}
+}
+
+func (v *View[K, V]) CountRange(lo, hi K) int {
+	return v.tree.CountRange(lo, hi)
+}
+
+func (v *View[K, V]) Len() int {
+	return v.tree.Len()
+}
+
+func (v *View[K, V]) Rank(key K) int {
+	return v.tree.Rank(key)
+}
+
+func (v *View[K, V]) Select(i int) (K, V, bool) {
+	return v.tree.Select(i)
+}
@@ -0,0 +1,145 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkSizes verifies that every node keeps number of keys in its subtree. Returns number of keys in 'page'. */
+func checkSizes[K cmp.Ordered, V any](t *testing.T, page Page) int {
+	t.Helper()
+
+	switch p := page.(type) {
+	case *Node[K]:
+		size := checkSizes[K, V](t, p.ChildPage0)
+		for _, child := range p.Children {
+			size += checkSizes[K, V](t, child)
+		}
+		if p.size != size {
+			t.Errorf("node %v has size %d, expected %d", p.Keys, p.size, size)
+		}
+		return size
+	case *Leaf[K, V]:
+		return len(p.Keys)
+	}
+	return 0
+}
+
+/* checkRank compares order statistics of the tree with sorted 'keys'. */
+func checkRank(t *testing.T, bt *Tree[int, int], keys []int) {
+	t.Helper()
+
+	checkSizes[int, int](t, bt.Root)
+	if bt.Len() != len(keys) {
+		t.Errorf("expected length %d, got %d", len(keys), bt.Len())
+	}
+	for i, key := range keys {
+		if k, _, ok := bt.Select(i); (!ok) || (k != key) {
+			t.Errorf("Select(%d): expected key %v, got %v", i, key, k)
+		}
+		if r := bt.Rank(key); r != i {
+			t.Errorf("Rank(%v): expected %d, got %d", key, i, r)
+		}
+	}
+	if _, _, ok := bt.Select(len(keys)); ok {
+		t.Errorf("Select(%d): expected nothing past the last key", len(keys))
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for j := 0; j < Ranges; j++ {
+		lo := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+		hi := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+
+		i, _ := slices.BinarySearch(keys, lo)
+		if r := bt.Rank(lo); r != i {
+			t.Errorf("Rank(%v): expected %d, got %d", lo, i, r)
+		}
+
+		var expected int
+		for range bt.Range(lo, hi) {
+			expected++
+		}
+		if n := bt.CountRange(lo, hi); n != expected {
+			t.Errorf("CountRange(%v, %v): expected %d, got %d", lo, hi, expected, n)
+		}
+	}
+}
+
+func testBplusRank(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		m[k] = i
+		bt.Set(k, i)
+	}
+	checkRank(t, &bt, slices.Sorted(maps.Keys(m)))
+
+	/* Snapshot makes Del copy pages, sizes must be copied as well. */
+	v := bt.Snapshot()
+	for k := range m {
+		if k%3 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+	}
+	keys := slices.Sorted(maps.Keys(m))
+	checkRank(t, &bt, keys)
+	if v.Len() == bt.Len() {
+		t.Errorf("expected snapshot to keep deleted keys")
+	}
+
+	if err := bt.BulkLoad(func(yield func(int, int) bool) {
+		for _, k := range keys {
+			if !yield(k, m[k]) {
+				return
+			}
+		}
+	}, 0.5); err != nil {
+		t.Fatalf("failed to bulk load: %v", err)
+	}
+	checkRank(t, &bt, keys)
+
+	for _, k := range keys {
+		bt.Del(k)
+	}
+	if bt.Len() != 0 {
+		t.Errorf("expected empty tree, got length %d", bt.Len())
+	}
+}
+
+func TestBplusRank(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		t.Run(generator.String(), func(t *testing.T) {
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					generator.Reset()
+					testBplusRank(t, generator, order)
+				})
+			}
+		})
+	}
+}
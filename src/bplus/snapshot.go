@@ -0,0 +1,256 @@
+package bplus
+
+import "iter"
+
+/*
+ * View is a read-only snapshot of Tree. It shares unchanged pages with the tree and with other views, so taking it
+ * is O(1). View does not use Prev and Next of leaves, which belong to the live tree only, so it is safe to read a view
+ * while the tree is being modified.
+ */
+type View[K any, V any] struct {
+	/* Only Root, Order, Compare, Search and page search functions are set, other fields stay unused. */
+	tree Tree[K, V]
+}
+
+/*
+ * Snapshot returns view of the current contents of the tree. From now on Set and Del path-copy pages shared with
+ * the view instead of modifying them in place, so the tree works in copy-on-write mode until its pages are replaced.
+ * View does not read Store, whose pages Flush reuses, so tree with Store reads all its pages into memory first.
+ */
+func (t *Tree[K, V]) Snapshot() *View[K, V] {
+	t.init()
+	t.readAll(t.Root)
+	t.gen++
+	return &View[K, V]{tree: Tree[K, V]{
+		Root:           t.Root,
+		Order:          t.Order,
+		Compare:        t.Compare,
+		findLeafLinear: t.findLeafLinear,
+		findLeafBinary: t.findLeafBinary,
+		findNodeLinear: t.findNodeLinear,
+		findNodeBinary: t.findNodeBinary,
+		binaryOrder:    t.binaryOrder,
+		findValue:      t.findValue,
+		Search:         t.Search,
+	}}
+}
+
+/* readAll replaces references below 'page' with pages they refer to. Pages shared with snapshots have no references. */
+func (t *Tree[K, V]) readAll(page Page) {
+	node, ok := page.(*Node[K])
+	if !ok {
+		return
+	}
+	for i := -1; i < len(node.Children); i++ {
+		child := t.child(node, i)
+		if child == nil {
+			return
+		}
+		t.replaceChild(node, i, child)
+		t.readAll(child)
+	}
+}
+
+/* ownLeaf returns leaf that may be modified: either the leaf itself or its copy, which replaces it in the list of leaves. */
+func (t *Tree[K, V]) ownLeaf(l *Leaf[K, V]) *Leaf[K, V] {
+	if l.gen == t.gen {
+		return l
+	}
+
+	c := t.newLeaf(len(l.Keys))
+	copy(c.Keys, l.Keys)
+	copy(c.Values, l.Values)
+	c.Prev = l.Prev
+	c.Next = l.Next
+	c.ID = l.ID
+	c.dirty = l.dirty
+	c.overflow = l.overflow
+
+	if c.Prev != nil {
+		c.Prev.Next = c
+	}
+	if c.Next != nil {
+		c.Next.Prev = c
+	}
+	return c
+}
+
+/* owned reports whether 'page' is in memory and not shared with snapshots. */
+func (t *Tree[K, V]) owned(page Page) bool {
+	switch page := page.(type) {
+	case *Node[K]:
+		return page.gen == t.gen
+	case *Leaf[K, V]:
+		return page.gen == t.gen
+	}
+	return false
+}
+
+/* ownNode returns node that may be modified: either the node itself or its copy. */
+func (t *Tree[K, V]) ownNode(n *Node[K]) *Node[K] {
+	if n.gen == t.gen {
+		return n
+	}
+
+	c := t.newNode(len(n.Keys))
+	copy(c.Keys, n.Keys)
+	copy(c.Children, n.Children)
+	c.ChildPage0 = n.ChildPage0
+	c.size = n.size
+	c.ID = n.ID
+	c.dirty = n.dirty
+	return c
+}
+
+/* replaceChild puts 'page' at 'index' of 'parent', where index -1 is ChildPage0 and nil 'parent' is the root. */
+func (t *Tree[K, V]) replaceChild(parent *Node[K], index int, page Page) {
+	switch {
+	case parent == nil:
+		t.Root = page
+	case index == -1:
+		parent.ChildPage0 = page
+	default:
+		parent.Children[index] = page
+	}
+}
+
+/*
+ * ownPath makes nodes on SearchPath and 'leaf' below them modifiable, copying the ones shared with snapshots and
+ * putting the ones read from Store into the tree. Returns modifiable leaf.
+ */
+func (t *Tree[K, V]) ownPath(leaf *Leaf[K, V]) *Leaf[K, V] {
+	var parent *Node[K]
+	var index int
+
+	for i := 0; i < len(t.SearchPath); i++ {
+		node := t.ownNode(t.SearchPath[i].Node)
+		t.replaceChild(parent, index, node)
+		t.SearchPath[i].Node = node
+		parent = node
+		index = t.SearchPath[i].Index
+	}
+
+	leaf = t.ownLeaf(leaf)
+	t.replaceChild(parent, index, leaf)
+	return leaf
+}
+
+func (v *View[K, V]) ascend(page Page, lo, hi K, yield func(K, V) bool) bool {
+	switch p := page.(type) {
+	case *Node[K]:
+		for i := v.tree.findOnNode(p, lo); i < len(p.Keys); i++ {
+			child := p.ChildPage0
+			if i >= 0 {
+				if v.tree.Compare(p.Keys[i], hi) >= 0 {
+					return false
+				}
+				child = p.Children[i]
+			}
+			if !v.ascend(child, lo, hi, yield) {
+				return false
+			}
+		}
+	case *Leaf[K, V]:
+		index, _ := v.tree.findOnLeaf(p, lo)
+		for i := index + 1; i < len(p.Keys); i++ {
+			if v.tree.Compare(p.Keys[i], hi) >= 0 {
+				return false
+			}
+			if !yield(p.Keys[i], p.Values[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (v *View[K, V]) descend(page Page, lo, hi K, yield func(K, V) bool) bool {
+	switch p := page.(type) {
+	case *Node[K]:
+		for i := v.tree.findOnNode(p, lo); i >= -1; i-- {
+			child := p.ChildPage0
+			if i >= 0 {
+				child = p.Children[i]
+			}
+			if !v.descend(child, lo, hi, yield) {
+				return false
+			}
+			if (i >= 0) && (v.tree.Compare(p.Keys[i], hi) <= 0) {
+				return false
+			}
+		}
+	case *Leaf[K, V]:
+		index, ok := v.tree.findOnLeaf(p, lo)
+		if ok {
+			index++
+		}
+		for i := index; i >= 0; i-- {
+			if v.tree.Compare(p.Keys[i], hi) <= 0 {
+				return false
+			}
+			if !yield(p.Keys[i], p.Values[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (v *View[K, V]) all(page Page, yield func(K, V) bool) bool {
+	switch p := page.(type) {
+	case *Node[K]:
+		if !v.all(p.ChildPage0, yield) {
+			return false
+		}
+		for i := 0; i < len(p.Children); i++ {
+			if !v.all(p.Children[i], yield) {
+				return false
+			}
+		}
+	case *Leaf[K, V]:
+		for i := 0; i < len(p.Keys); i++ {
+			if !yield(p.Keys[i], p.Values[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/* All returns iterator over all key-value pairs in ascending order. */
+func (v *View[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		v.all(v.tree.Root, yield)
+	}
+}
+
+func (v *View[K, V]) Get(key K) V {
+	value, _ := v.tree.lookup(key)
+	return value
+}
+
+func (v *View[K, V]) Has(key K) bool {
+	_, ok := v.tree.lookup(key)
+	return ok
+}
+
+/*
+ * Range returns iterator over keys from 'lo' up to, but not including, 'hi'. If 'lo' is greater than 'hi', keys are
+ * visited in descending order, again from 'lo' inclusive to 'hi' exclusive.
+ */
+func (v *View[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if v.tree.Root == nil {
+			return
+		}
+		if v.tree.Compare(lo, hi) <= 0 {
+			v.ascend(v.tree.Root, lo, hi, yield)
+		} else {
+			v.descend(v.tree.Root, lo, hi, yield)
+		}
+	}
+}
+
+func (v *View[K, V]) String() string {
+	return v.tree.String()
+}
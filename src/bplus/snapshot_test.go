@@ -0,0 +1,185 @@
+package bplus
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkView compares contents of the view with 'm'. */
+func checkView(t *testing.T, v *View[int, int], m map[int]int) {
+	t.Helper()
+
+	keys := slices.Sorted(maps.Keys(m))
+
+	var got []int
+	for k, val := range v.All() {
+		if val != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, val)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, keys) {
+		t.Errorf("expected keys %v, got %v", keys, got)
+	}
+
+	for k, val := range m {
+		if !v.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+		if got := v.Get(k); got != val {
+			t.Errorf("expected value %v, got %v", val, got)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	lo, hi := keys[len(keys)/4], keys[3*len(keys)/4]
+	var asc, desc []int
+	for k := range v.Range(lo, hi) {
+		asc = append(asc, k)
+	}
+	for k := range v.Range(hi, lo) {
+		desc = append(desc, k)
+	}
+	expected := keys[len(keys)/4 : 3*len(keys)/4]
+	if !slices.Equal(asc, expected) {
+		t.Errorf("Range(%v, %v): expected keys %v, got %v", lo, hi, expected, asc)
+	}
+	expected = slices.Clone(keys[len(keys)/4+1 : 3*len(keys)/4+1])
+	slices.Reverse(expected)
+	if !slices.Equal(desc, expected) {
+		t.Errorf("Range(%v, %v): expected keys %v, got %v", hi, lo, expected, desc)
+	}
+}
+
+func testBplusSnapshot(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	m := make(map[int]int)
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		bt.Set(k, i)
+		m[k] = i
+		keys = append(keys, k)
+	}
+
+	views := []*View[int, int]{bt.Snapshot()}
+	saved := []map[int]int{maps.Clone(m)}
+
+	/* Every round changes values, removes and adds keys, then takes one more snapshot. */
+	for round := 0; round < 3; round++ {
+		for i := round; i < len(keys); i += 3 {
+			bt.Set(keys[i], -i)
+			m[keys[i]] = -i
+		}
+		for i := round; i < len(keys); i += 2 {
+			bt.Del(keys[i])
+			delete(m, keys[i])
+		}
+		for i := round; i < len(keys); i += 5 {
+			bt.Set(keys[i]+constants.N*(round+1), i)
+			m[keys[i]+constants.N*(round+1)] = i
+		}
+
+		views = append(views, bt.Snapshot())
+		saved = append(saved, maps.Clone(m))
+	}
+	for _, k := range keys {
+		bt.Del(k)
+		delete(m, k)
+	}
+	checkPages(t, &bt)
+
+	for i := range views {
+		checkView(t, views[i], saved[i])
+	}
+
+	/* Live tree must stay consistent, including list of leaves. */
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	expected := slices.Sorted(maps.Keys(m))
+	var got []int
+	for k := range bt.All() {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+	got = got[:0]
+	c := bt.Cursor()
+	for c.Last(); c.Valid(); c.Prev() {
+		got = append(got, c.Key())
+	}
+	slices.Reverse(expected)
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys in reverse order %v, got %v", expected, got)
+	}
+}
+
+func TestBplusSnapshot(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		t.Run(generator.String(), func(t *testing.T) {
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					generator.Reset()
+					testBplusSnapshot(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+/* TestBplusSnapshotConcurrent reads a view while the tree is being modified, for 'go test -race'. */
+func TestBplusSnapshotConcurrent(t *testing.T) {
+	const Readers = 4
+
+	var bt Tree[int, int]
+	bt.Order = constants.MinOrder
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+		m[i] = i
+	}
+	v := bt.Snapshot()
+
+	var wg sync.WaitGroup
+	for r := 0; r < Readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkView(t, v, m)
+		}()
+	}
+
+	for i := 0; i < constants.N; i += 2 {
+		bt.Del(i)
+	}
+	for i := 1; i < constants.N; i += 4 {
+		bt.Set(i, -i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,151 @@
+package bplus
+
+import (
+	"cmp"
+	"fmt"
+
+	"codec"
+	"pager"
+	"vlog"
+)
+
+/*
+ * ValueLogTree keeps values in an append-only value log and only small pointers to them in leaves, so that large
+ * values neither bloat pages nor get rewritten on every split and flush. Overwritten and deleted values stay in the
+ * log until Collect rewrites live ones and drops the oldest segment.
+ */
+type ValueLogTree[K cmp.Ordered, V any] struct {
+	Order int
+	Log   *vlog.Log
+
+	/* Optional store for the tree of pointers, see Flush and Load. */
+	Store pager.Store
+
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+
+	tree Tree[K, vlog.Pointer]
+	buf  []byte
+}
+
+func (t *ValueLogTree[K, V]) init() {
+	if t.KeyCodec == nil {
+		t.KeyCodec = codec.Default[K]{}
+	}
+	if t.ValueCodec == nil {
+		t.ValueCodec = codec.Default[V]{}
+	}
+
+	if t.tree.Root == nil {
+		t.tree.Order = t.Order
+	}
+	t.tree.Store = t.Store
+	t.tree.KeyCodec = t.KeyCodec
+	t.tree.ValueCodec = vlog.PointerCodec{}
+	t.tree.init()
+}
+
+func (t *ValueLogTree[K, V]) Clear() {
+	t.init()
+	t.tree.Clear()
+}
+
+/*
+ * Collect is a step of garbage collection: it moves values which are still referenced out of the oldest segment of the
+ * log, makes new pointers durable and removes the segment.
+ */
+func (t *ValueLogTree[K, V]) Collect() error {
+	t.init()
+
+	var key K
+	var err error
+	id, rerr := t.Log.Rewrite(func(buf []byte, p vlog.Pointer) bool {
+		key, _, err = t.KeyCodec.Decode(buf)
+		/* Record with broken key is reported by 'moved' rather than dropped. */
+		return (err != nil) || (t.tree.Get(key) == p)
+	}, func(_ []byte, p vlog.Pointer) error {
+		if err != nil {
+			return err
+		}
+		t.tree.Set(key, p)
+		return nil
+	})
+	if rerr != nil {
+		return rerr
+	}
+
+	if err := t.Flush(); err != nil {
+		return err
+	}
+	return t.Log.Remove(id)
+}
+
+func (t *ValueLogTree[K, V]) Del(key K) {
+	t.init()
+	t.tree.Del(key)
+}
+
+/* Flush makes values durable and, if the tree has a Store, writes pointers to it. */
+func (t *ValueLogTree[K, V]) Flush() error {
+	t.init()
+
+	if err := t.Log.Sync(); err != nil {
+		return err
+	}
+	if t.Store == nil {
+		return nil
+	}
+	return t.tree.Flush()
+}
+
+/* Get returns value of 'key' or zero value if there is none. */
+func (t *ValueLogTree[K, V]) Get(key K) (V, error) {
+	var value V
+
+	t.init()
+	p, ok := t.tree.Lookup(key)
+	if !ok {
+		return value, nil
+	}
+
+	buf, err := t.Log.Read(p)
+	if err != nil {
+		return value, err
+	}
+	value, _, err = t.ValueCodec.Decode(buf)
+	if err != nil {
+		return value, fmt.Errorf("failed to decode value: %w", err)
+	}
+	return value, nil
+}
+
+func (t *ValueLogTree[K, V]) Has(key K) bool {
+	t.init()
+	return t.tree.Has(key)
+}
+
+func (t *ValueLogTree[K, V]) Len() int {
+	return t.tree.Len()
+}
+
+/* Load replaces pointers with the ones stored in Store. */
+func (t *ValueLogTree[K, V]) Load() error {
+	t.init()
+	return t.tree.Load()
+}
+
+/* Set appends 'value' to the log and points 'key' to it. Value is durable only after Flush. */
+func (t *ValueLogTree[K, V]) Set(key K, value V) error {
+	t.init()
+
+	t.buf = t.KeyCodec.Append(t.buf[:0], key)
+	n := len(t.buf)
+	t.buf = t.ValueCodec.Append(t.buf, value)
+
+	p, err := t.Log.Append(t.buf[:n], t.buf[n:])
+	if err != nil {
+		return err
+	}
+	t.tree.Set(key, p)
+	return nil
+}
@@ -0,0 +1,138 @@
+package bplus
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"constants"
+	"generator"
+	"pager"
+	"vlog"
+)
+
+func checkValueLogTree(t *testing.T, vt *ValueLogTree[int, string], m map[int]string) {
+	t.Helper()
+
+	if vt.Len() != len(m) {
+		t.Errorf("expected length %d, got %d", len(m), vt.Len())
+	}
+	for k, v := range m {
+		got, err := vt.Get(k)
+		if err != nil {
+			t.Fatalf("failed to get %d: %v", k, err)
+		}
+		if got != v {
+			t.Errorf("Get(%d): expected value of %d bytes, got %d", k, len(v), len(got))
+		}
+	}
+}
+
+func testValueLogTree(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	dir := t.TempDir()
+	l, err := vlog.Open(filepath.Join(dir, "vlog"))
+	if err != nil {
+		t.Fatalf("failed to open value log: %v", err)
+	}
+	defer func() { l.Close() }()
+	l.SegmentSize = 64 << 10
+
+	p, err := pager.Open(filepath.Join(dir, "bplus.db"), pager.DefaultPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var vt ValueLogTree[int, string]
+	vt.Order = order
+	vt.Log = l
+	vt.Store = p
+
+	m := make(map[int]string)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := strings.Repeat(fmt.Sprint(i), 1+i%64)
+
+		m[k] = v
+		if err := vt.Set(k, v); err != nil {
+			t.Fatalf("failed to set %d: %v", k, err)
+		}
+	}
+	checkValueLogTree(t, &vt, m)
+
+	i := 0
+	for k := range m {
+		if i%2 == 0 {
+			vt.Del(k)
+			if vt.Has(k) {
+				t.Errorf("expected key %d to be removed, but it's still present", k)
+			}
+			delete(m, k)
+		}
+		i++
+	}
+	missing := 0
+	for vt.Has(missing) {
+		missing++
+	}
+	if got, err := vt.Get(missing); (err != nil) || (got != "") {
+		t.Errorf("Get(%d): expected empty value, got %q, %v", missing, got, err)
+	}
+
+	/* Collecting every segment written so far must leave live values only. */
+	segments, size := l.Segments(), l.Size()
+	for i := 0; i < segments; i++ {
+		if err := vt.Collect(); err != nil {
+			t.Fatalf("failed to collect value log: %v", err)
+		}
+	}
+	if 3*l.Size() > 2*size {
+		t.Errorf("expected value log of %d bytes to shrink, got %d", size, l.Size())
+	}
+	checkValueLogTree(t, &vt, m)
+
+	if err := vt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("failed to close value log: %v", err)
+	}
+	l, err = vlog.Open(filepath.Join(dir, "vlog"))
+	if err != nil {
+		t.Fatalf("failed to reopen value log: %v", err)
+	}
+
+	var loaded ValueLogTree[int, string]
+	loaded.Order = order
+	loaded.Log = l
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	checkValueLogTree(t, &loaded, m)
+}
+
+func TestValueLogTree(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder}
+
+	for _, generator := range generators {
+		t.Run(generator.String(), func(t *testing.T) {
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					generator.Reset()
+					testValueLogTree(t, generator, order)
+				})
+			}
+		})
+	}
+}
@@ -5,38 +5,93 @@ import (
 	"fmt"
 	"strings"
 
+	"codec"
+	"compare"
+	"pager"
+
 	"github.com/anton2920/gofa/util"
 )
 
-type Item[K cmp.Ordered, V any] struct {
+type Item[K any, V any] struct {
 	Key       K
 	Value     V
 	ChildPage *Page[K, V]
 }
 
-type Page[K cmp.Ordered, V any] struct {
+type Page[K any, V any] struct {
 	Items      []Item[K, V]
 	ChildPage0 *Page[K, V]
+
+	ID    pager.PageID
+	dirty bool
+
+	/* Page is only a reference to its copy in Store, see Tree.child. */
+	ref bool
 }
 
-type PathItem[K cmp.Ordered, V any] struct {
+type PathItem[K any, V any] struct {
 	Page      *Page[K, V]
 	ChildPage *Page[K, V]
 	Index     int
 }
 
-type Tree[K cmp.Ordered, V any] struct {
+type Tree[K any, V any] struct {
 	Root *Page[K, V]
 
 	Order int
 
+	/* Order of keys, compare.Default is used if it is not set. See New and NewFunc. */
+	Compare func(a, b K) int
+
+	/* Page search functions, see initSearch. */
+	findLinear  func(*Page[K, V], K) (int, bool)
+	findBinary  func(*Page[K, V], K) (int, bool)
+	binaryOrder int
+	findValue   func(*Tree[K, V], K) (V, bool)
+
+	/* How keys are searched within pages. */
+	Search SearchMode
+
 	SearchPath []PathItem[K, V]
+
+	/*
+	 * Optional on-disk storage, see Flush and Load. Pages are read from it when they are needed, so with buffer pool
+	 * the tree takes memory of the pool and of pages modified since the last Flush.
+	 */
+	Store      pager.Store
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+	freed      []pager.PageID
+	pageBuf    []byte
+	readBuf    []byte
+	err        error
 }
 
+type SearchMode int
+
+const (
+	/*
+	 * SearchAuto picks binary search for orders of at least BinarySearchOrder, or OrderedBinarySearchOrder for keys
+	 * compared directly, and linear search otherwise.
+	 */
+	SearchAuto SearchMode = iota
+	SearchLinear
+	SearchBinary
+)
+
 const DefaultOrder = 45
 
-/* findOnPage returns index of element whose key is <= 'key'. Returns true, if ==. */
-func findOnPage[K cmp.Ordered, V any](page *Page[K, V], key K) (int, bool) {
+/* BinarySearchOrder is the smallest order for which binary search within pages beats linear one, see BenchmarkBtreeSearch. */
+const BinarySearchOrder = 31
+
+/*
+ * OrderedBinarySearchOrder is BinarySearchOrder of keys compared directly instead of calling Compare. Binary search of
+ * such keys wins in BenchmarkBtreeSearch only because its few keys make branches predictable, Get of random keys in
+ * BenchmarkBtree is faster with linear search up to this order.
+ */
+const OrderedBinarySearchOrder = 192
+
+func findOnPageLinear[K cmp.Ordered, V any](page *Page[K, V], key K) (int, bool) {
 	if key >= page.Items[len(page.Items)-1].Key {
 		eq := key == page.Items[len(page.Items)-1].Key
 		return len(page.Items) - 1 - util.Bool2Int(eq), eq
@@ -49,34 +104,44 @@ func findOnPage[K cmp.Ordered, V any](page *Page[K, V], key K) (int, bool) {
 	return len(page.Items) - 1, false
 }
 
-/*
-func findOnPage[K cmp.Ordered, V any](page *Page[K, V], key K) (int, bool) {
-	if key <= page.Items[0].Key {
-		return -1, key == page.Items[0].Key
-	} else if key >= page.Items[len(page.Items)-1].Key {
-		eq := key == page.Items[len(page.Items)-1].Key
-		return len(page.Items) - 1 - util.Bool2Int(eq), eq
+func findOnPageBinary[K cmp.Ordered, V any](page *Page[K, V], key K) (int, bool) {
+	i, j := 0, len(page.Items)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if page.Items[h].Key < key {
+			i = h + 1
+		} else {
+			j = h
+		}
 	}
+	return i - 1, (i < len(page.Items)) && (page.Items[i].Key == key)
+}
 
-	l := 1
-	r := len(page.Items) - 2
-	for {
-		k := (l + r) / 2
-
-		if key >= page.Items[k].Key {
-			l = k + 1
-		}
-		if key <= page.Items[k].Key {
-			r = k - 1
-		}
-		if l > r {
-			break
+func findOnPageLinearFunc[K any, V any](page *Page[K, V], key K, compare func(a, b K) int) (int, bool) {
+	if c := compare(key, page.Items[len(page.Items)-1].Key); c >= 0 {
+		eq := c == 0
+		return len(page.Items) - 1 - util.Bool2Int(eq), eq
+	}
+	for i := 0; i < len(page.Items); i++ {
+		if c := compare(key, page.Items[i].Key); c <= 0 {
+			return i - 1, c == 0
 		}
 	}
+	return len(page.Items) - 1, false
+}
 
-	return r, l-r > 1
+func findOnPageBinaryFunc[K any, V any](page *Page[K, V], key K, compare func(a, b K) int) (int, bool) {
+	i, j := 0, len(page.Items)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if compare(page.Items[h].Key, key) < 0 {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i - 1, (i < len(page.Items)) && (compare(page.Items[i].Key, key) == 0)
 }
-*/
 
 func removeItemAtIndex[T any](vs []T, i int) []T {
 	copy(vs[i:], vs[i+1:])
@@ -92,59 +157,203 @@ func mergeItems[T any](self []T, other []T) []T {
 	return self
 }
 
+func (t *Tree[K, V]) dispose(page *Page[K, V]) {
+	if page.ID != pager.InvalidPage {
+		t.freed = append(t.freed, page.ID)
+	}
+}
+
+func (t *Tree[K, V]) disposeAll(page *Page[K, V]) {
+	if page == nil {
+		return
+	}
+	for i := -1; i < len(page.Items); i++ {
+		t.disposeAll(t.child(page, i))
+	}
+	t.dispose(page)
+}
+
+/* New returns tree ordering keys with cmp.Compare. */
+func New[K cmp.Ordered, V any]() *Tree[K, V] {
+	t := &Tree[K, V]{Compare: cmp.Compare[K]}
+	orderedSearch(t)
+	return t
+}
+
+/* NewFunc returns tree ordering keys with 'compare'. */
+func NewFunc[K any, V any](compare func(a, b K) int) *Tree[K, V] {
+	return &Tree[K, V]{Compare: compare}
+}
+
+/* orderedSearch makes tree search pages with functions which compare keys directly instead of calling Compare. */
+func orderedSearch[K cmp.Ordered, V any](t *Tree[K, V]) {
+	t.findLinear = findOnPageLinear[K, V]
+	t.findBinary = findOnPageBinary[K, V]
+	t.binaryOrder = OrderedBinarySearchOrder
+	t.findValue = lookupOrdered[K, V]
+}
+
+/*
+ * initSearch sets Compare to default comparator if it is not set and picks page search functions. Built-in ordered
+ * types with default comparator take the same fast path as trees made by New.
+ */
+func (t *Tree[K, V]) initSearch() {
+	if t.Compare == nil {
+		t.Compare = compare.Default[K]()
+		if t.Compare == nil {
+			panic(fmt.Sprintf("btree: no default comparator for %T, use NewFunc", *new(K)))
+		}
+
+		switch t := any(t).(type) {
+		case *Tree[int, V]:
+			orderedSearch(t)
+		case *Tree[int8, V]:
+			orderedSearch(t)
+		case *Tree[int16, V]:
+			orderedSearch(t)
+		case *Tree[int32, V]:
+			orderedSearch(t)
+		case *Tree[int64, V]:
+			orderedSearch(t)
+		case *Tree[uint, V]:
+			orderedSearch(t)
+		case *Tree[uint8, V]:
+			orderedSearch(t)
+		case *Tree[uint16, V]:
+			orderedSearch(t)
+		case *Tree[uint32, V]:
+			orderedSearch(t)
+		case *Tree[uint64, V]:
+			orderedSearch(t)
+		case *Tree[uintptr, V]:
+			orderedSearch(t)
+		case *Tree[float32, V]:
+			orderedSearch(t)
+		case *Tree[float64, V]:
+			orderedSearch(t)
+		case *Tree[string, V]:
+			orderedSearch(t)
+		}
+	}
+	if t.findLinear == nil {
+		t.findLinear = func(page *Page[K, V], key K) (int, bool) {
+			return findOnPageLinearFunc(page, key, t.Compare)
+		}
+		t.findBinary = func(page *Page[K, V], key K) (int, bool) {
+			return findOnPageBinaryFunc(page, key, t.Compare)
+		}
+		t.binaryOrder = BinarySearchOrder
+		t.findValue = lookupFunc[K, V]
+	}
+}
+
 func (t *Tree[K, V]) init() {
 	if t.Order == 0 {
 		t.Order = DefaultOrder
 	}
+	if t.findLinear == nil {
+		t.initSearch()
+	}
 	t.SearchPath = t.SearchPath[:0]
 }
 
+func (t *Tree[K, V]) binarySearch() bool {
+	return (t.Search == SearchBinary) || ((t.Search == SearchAuto) && (t.Order >= t.binaryOrder))
+}
+
+/* findOnPage returns index of the last item whose key is < 'key'. Returns true, if the next one is == 'key'. */
+func (t *Tree[K, V]) findOnPage(page *Page[K, V], key K) (int, bool) {
+	if t.binarySearch() {
+		return t.findBinary(page, key)
+	}
+	return t.findLinear(page, key)
+}
+
 func (t *Tree[K, V]) newPage(l int) *Page[K, V] {
 	return &Page[K, V]{Items: make([]Item[K, V], l, t.Order-1)}
 }
 
 func (t *Tree[K, V]) Clear() {
+	if t.Store != nil {
+		t.disposeAll(t.Root)
+	}
 	t.Root = nil
 }
 
-func (t *Tree[K, V]) Del(key K) {
-	var childPage *Page[K, V]
-	var index int
-	var ok bool
+/* CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==. */
+func CompareAndSwap[K any, V comparable](t *Tree[K, V], key K, old, new V) bool {
+	return t.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
 
+/* CompareAndSwapFunc sets value of 'key' to 'new' if it is present with value 'old' according to 'equal'. */
+func (t *Tree[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
 	t.init()
 
-	page := t.Root
-	for {
-		if page == nil {
-			return
-		}
+	page, index := t.search(key)
+	if (page == nil) || (!equal(page.Items[index+1].Value, old)) {
+		return false
+	}
+	page.Items[index+1].Value = new
+	page.dirty = true
+	return true
+}
 
-		index, ok = findOnPage(page, key)
-		if index == -1 {
-			childPage = page.ChildPage0
-		} else {
-			childPage = page.Items[index].ChildPage
-		}
+func (t *Tree[K, V]) Del(key K) {
+	t.init()
+
+	page, index := t.search(key)
+	if page != nil {
+		t.remove(page, index)
+	}
+}
 
+/*
+ * search finds page with 'key', filling SearchPath with pages above it. Returns the page and index of the item before
+ * the key, or nil if there is no key, and then SearchPath leads to the terminal page where it belongs. Pages on the
+ * way are put into the tree, so that they may be modified. If reading has failed, SearchPath is empty, see Err.
+ */
+func (t *Tree[K, V]) search(key K) (*Page[K, V], int) {
+	page := t.Root
+	for page != nil {
+		index, ok := t.findOnPage(page, key)
 		if ok {
-			break
+			return page, index
+		}
+
+		childPage := t.fetch(page, index)
+		if (childPage == nil) && (childAfter(page, index) != nil) {
+			t.SearchPath = t.SearchPath[:0]
+			return nil, -1
 		}
 
 		t.SearchPath = append(t.SearchPath, PathItem[K, V]{Page: page, ChildPage: childPage, Index: index})
 		page = childPage
 	}
 
+	return nil, -1
+}
+
+/* remove deletes item after 'index' of 'page', which SearchPath leads to, merging pages as needed. */
+func (t *Tree[K, V]) remove(page *Page[K, V], index int) {
+	childPage := t.fetch(page, index)
+	if (childPage == nil) && (childAfter(page, index) != nil) {
+		return
+	}
+
 	/* Found, now delete page.Items[index+1]. */
 	if childPage == nil {
 		/* 'page' is a terminal page. */
 		page.Items = removeItemAtIndex(page.Items, index+1)
+		page.dirty = true
 	} else {
 		t.SearchPath = append(t.SearchPath, PathItem[K, V]{Page: page, ChildPage: childPage, Index: index})
 		rootPage := page
 		page = childPage
 		for {
-			childPage := page.Items[len(page.Items)-1].ChildPage
+			childPage := t.fetch(page, len(page.Items)-1)
+			if (childPage == nil) && (page.Items[len(page.Items)-1].ChildPage != nil) {
+				return
+			}
 			if childPage != nil {
 				t.SearchPath = append(t.SearchPath, PathItem[K, V]{Page: page, ChildPage: childPage, Index: len(page.Items) - 1})
 				page = childPage
@@ -152,6 +361,8 @@ func (t *Tree[K, V]) Del(key K) {
 				page.Items[len(page.Items)-1].ChildPage = rootPage.Items[index+1].ChildPage
 				rootPage.Items[index+1] = page.Items[len(page.Items)-1]
 				page.Items = removeItemAtIndex(page.Items, len(page.Items)-1)
+				rootPage.dirty = true
+				page.dirty = true
 				break
 			}
 		}
@@ -166,8 +377,14 @@ func (t *Tree[K, V]) Del(key K) {
 			index := item.Index
 
 			if len(page.Items) < half {
+				rootPage.dirty = true
+				page.dirty = true
 				if index < len(rootPage.Items)-1 {
-					rightPage := rootPage.Items[index+1].ChildPage
+					rightPage := t.fetch(rootPage, index+1)
+					if rightPage == nil {
+						return
+					}
+					rightPage.dirty = true
 
 					k := (len(rightPage.Items) - half + 1) / 2
 					if k > 0 {
@@ -191,15 +408,14 @@ func (t *Tree[K, V]) Del(key K) {
 
 						page.Items = mergeItems(page.Items, rightPage.Items)
 						rootPage.Items = removeItemAtIndex(rootPage.Items, index+1)
-						/* dispose(rightPage) */
+						t.dispose(rightPage)
 					}
 				} else {
-					var leftPage *Page[K, V]
-					if index == 0 {
-						leftPage = rootPage.ChildPage0
-					} else {
-						leftPage = rootPage.Items[index-1].ChildPage
+					leftPage := t.fetch(rootPage, index-1)
+					if leftPage == nil {
+						return
 					}
+					leftPage.dirty = true
 
 					k := (len(leftPage.Items) - half + 1) / 2
 					if k > 0 {
@@ -223,7 +439,7 @@ func (t *Tree[K, V]) Del(key K) {
 
 						leftPage.Items = mergeItems(leftPage.Items, page.Items)
 						rootPage.Items = removeItemAtIndex(rootPage.Items, index)
-						/* dispose(page) */
+						t.dispose(page)
 					}
 				}
 			}
@@ -231,80 +447,127 @@ func (t *Tree[K, V]) Del(key K) {
 
 		/* Base page size was reduced. */
 		if len(t.Root.Items) == 0 {
-			t.Root = t.Root.ChildPage0
+			root := t.child(t.Root, -1)
+			if (root == nil) && (t.Root.ChildPage0 != nil) {
+				return
+			}
+			t.dispose(t.Root)
+			t.Root = root
 		}
 	}
 }
 
 func (t *Tree[K, V]) Get(key K) V {
-	var value V
+	value, _ := t.Lookup(key)
+	return value
+}
 
+/* GetOrSet returns value of 'key' and true if it is present, otherwise it sets 'key' to 'value'. */
+func (t *Tree[K, V]) GetOrSet(key K, value V) (V, bool) {
+	t.init()
+
+	page, index := t.search(key)
+	if page != nil {
+		return page.Items[index+1].Value, true
+	}
+	t.insert(Item[K, V]{Key: key, Value: value})
+	return value, false
+}
+
+func (t *Tree[K, V]) Has(key K) bool {
 	t.init()
 
 	page := t.Root
 	for page != nil {
-		index, ok := findOnPage(page, key)
+		index, ok := t.findOnPage(page, key)
 		if ok {
-			return page.Items[index+1].Value
+			return true
 		}
 
-		if index == -1 {
-			page = page.ChildPage0
-		} else {
-			page = page.Items[index].ChildPage
-		}
+		page = t.child(page, index)
 	}
 
-	return value
+	return false
 }
 
-func (t *Tree[K, V]) Has(key K) bool {
+/* Lookup returns value of 'key' and whether it is present. */
+func (t *Tree[K, V]) Lookup(key K) (V, bool) {
 	t.init()
 
+	return t.findValue(t, key)
+}
+
+/* lookupFunc is Lookup of initialized tree with keys compared with Compare. */
+func lookupFunc[K any, V any](t *Tree[K, V], key K) (V, bool) {
+	var value V
+
 	page := t.Root
 	for page != nil {
-		index, ok := findOnPage(page, key)
+		index, ok := t.findOnPage(page, key)
 		if ok {
-			return true
+			return page.Items[index+1].Value, true
 		}
 
-		if index == -1 {
-			page = page.ChildPage0
-		} else {
-			page = page.Items[index].ChildPage
-		}
+		page = t.child(page, index)
 	}
 
-	return false
+	return value, false
 }
 
-func (t *Tree[K, V]) Set(key K, value V) {
-	t.init()
+/*
+ * lookupOrdered is Lookup of initialized tree with keys compared directly. It calls page search functions by name,
+ * so that they are inlined like in trees before comparators, and calls child only for pages that are not in memory.
+ */
+func lookupOrdered[K cmp.Ordered, V any](t *Tree[K, V], key K) (V, bool) {
+	var value V
 
+	binary := t.binarySearch()
 	page := t.Root
 	for page != nil {
-		index, ok := findOnPage(page, key)
+		var index int
+		var ok bool
+		if binary {
+			index, ok = findOnPageBinary(page, key)
+		} else {
+			index, ok = findOnPageLinear(page, key)
+		}
 		if ok {
-			page.Items[index+1].Value = value
-			return
+			return page.Items[index+1].Value, true
 		}
 
-		var childPage *Page[K, V]
-		if index == -1 {
-			childPage = page.ChildPage0
-		} else {
-			childPage = page.Items[index].ChildPage
+		parent := page
+		page = childAfter(parent, index)
+		if (page != nil) && (page.ref) {
+			page = t.child(parent, index)
 		}
+	}
 
-		t.SearchPath = append(t.SearchPath, PathItem[K, V]{Page: page, Index: index})
-		page = childPage
+	return value, false
+}
+
+func (t *Tree[K, V]) Set(key K, value V) {
+	t.init()
+
+	page, index := t.search(key)
+	if page != nil {
+		page.Items[index+1].Value = value
+		page.dirty = true
+		return
+	}
+	t.insert(Item[K, V]{Key: key, Value: value})
+}
+
+/* insert puts 'newItem' into the terminal page SearchPath leads to, splitting pages as needed. */
+func (t *Tree[K, V]) insert(newItem Item[K, V]) {
+	if (len(t.SearchPath) == 0) && (t.Root != nil) {
+		return
 	}
-	newItem := Item[K, V]{Key: key, Value: value}
 
 	item := newItem
 	for p := len(t.SearchPath) - 1; p >= 0; p-- {
 		index := t.SearchPath[p].Index
 		page := t.SearchPath[p].Page
+		page.dirty = true
 
 		if len(page.Items) < t.Order-1 {
 			/* Insert 'newItem' to the right of 'page.Items[index]'. */
@@ -347,6 +610,22 @@ func (t *Tree[K, V]) Set(key K, value V) {
 	t.Root.Items[0] = item
 }
 
+/* Swap sets 'key' to 'value' and returns the previous value and whether there was one. */
+func (t *Tree[K, V]) Swap(key K, value V) (V, bool) {
+	var old V
+
+	t.init()
+
+	page, index := t.search(key)
+	if page == nil {
+		t.insert(Item[K, V]{Key: key, Value: value})
+		return old, false
+	}
+	old, page.Items[index+1].Value = page.Items[index+1].Value, value
+	page.dirty = true
+	return old, true
+}
+
 func (t *Tree[K, V]) stringImpl(sb *strings.Builder, page *Page[K, V], level int) {
 	if page == nil {
 		return
@@ -360,9 +639,8 @@ func (t *Tree[K, V]) stringImpl(sb *strings.Builder, page *Page[K, V], level int
 	}
 	sb.WriteRune('\n')
 
-	t.stringImpl(sb, page.ChildPage0, level+1)
-	for i := 0; i < len(page.Items); i++ {
-		t.stringImpl(sb, page.Items[i].ChildPage, level+1)
+	for i := -1; i < len(page.Items); i++ {
+		t.stringImpl(sb, t.child(page, i), level+1)
 	}
 }
 
@@ -371,3 +649,28 @@ func (t Tree[K, V]) String() string {
 	t.stringImpl(&sb, t.Root, 0)
 	return sb.String()
 }
+
+/*
+ * Update calls 'fn' with value of 'key' and whether it is present, then sets 'key' to the returned value or, if 'fn'
+ * returns false, deletes it. 'fn' must not modify the tree.
+ */
+func (t *Tree[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	var old V
+
+	t.init()
+
+	page, index := t.search(key)
+	if page != nil {
+		old = page.Items[index+1].Value
+	}
+	value, keep := fn(old, page != nil)
+	switch {
+	case keep && (page != nil):
+		page.Items[index+1].Value = value
+		page.dirty = true
+	case keep:
+		t.insert(Item[K, V]{Key: key, Value: value})
+	case page != nil:
+		t.remove(page, index)
+	}
+}
@@ -1,7 +1,11 @@
 package btree
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
 	"testing"
 
 	"constants"
@@ -128,6 +132,91 @@ func TestBtree(t *testing.T) {
 	}
 }
 
+/* TestBtreePointOps runs random point operations against a map. */
+func TestBtreePointOps(t *testing.T) {
+	for _, order := range [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder} {
+		t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+			var bt Tree[int, int]
+			bt.Order = order
+
+			rng := rand.New(rand.NewSource(constants.Seed))
+			m := make(map[int]int)
+			for i := 0; i < constants.N; i++ {
+				k := rng.Intn(constants.N / 8)
+				v, ok := m[k]
+
+				switch rng.Intn(7) {
+				case 0:
+					if got, found := bt.Lookup(k); (got != v) || (found != ok) {
+						t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+					}
+				case 1:
+					if !ok {
+						m[k] = i
+					}
+					if got, loaded := bt.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+						t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+					}
+				case 2:
+					m[k] = i
+					if got, loaded := bt.Swap(k, i); (got != v) || (loaded != ok) {
+						t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+					}
+				case 3:
+					old := v + rng.Intn(2)
+					want := ok && (old == v)
+					if want {
+						m[k] = i
+					}
+					if got := CompareAndSwap(&bt, k, old, i); got != want {
+						t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 4:
+					/* Values of the same parity are equal. */
+					old := rng.Intn(2)
+					want := ok && ((old-v)%2 == 0)
+					if want {
+						m[k] = i
+					}
+					if got := bt.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+						t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 5:
+					/* Every third update deletes the key. */
+					keep := i%3 != 0
+					if keep {
+						m[k] = i
+					} else {
+						delete(m, k)
+					}
+					bt.Update(k, func(got int, found bool) (int, bool) {
+						if (got != v) || (found != ok) {
+							t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+						}
+						return i, keep
+					})
+				case 6:
+					delete(m, k)
+					bt.Del(k)
+				}
+			}
+			checkPages(t, &bt)
+
+			keys := slices.Sorted(maps.Keys(m))
+			var n int
+			for k, v := range bt.All() {
+				if (n >= len(keys)) || (keys[n] != k) || (m[k] != v) {
+					t.Fatalf("All: unexpected pair %v: %v at %d", k, v, n)
+				}
+				n++
+			}
+			if n != len(keys) {
+				t.Errorf("All: expected %d keys, got %d", len(keys), n)
+			}
+		})
+	}
+}
+
 func benchmarkBtreeGet(b *testing.B, g generator.Generator, order int) {
 	b.Helper()
 
@@ -205,3 +294,206 @@ func BenchmarkBtree(b *testing.B) {
 		})
 	}
 }
+
+func TestBtreeSearch(t *testing.T) {
+	for order := constants.MinOrder; order <= constants.MaxOrder; order += constants.OrderStep {
+		var bt Tree[int, int]
+		bt.Order = order
+
+		page := bt.newPage(order - 1)
+		for i := 0; i < order-1; i++ {
+			page.Items[i].Key = 2 * i
+		}
+
+		for key := -1; key <= 2*order; key++ {
+			li, lok := findOnPageLinear(page, key)
+			bi, bok := findOnPageBinary(page, key)
+			if (li != bi) || (lok != bok) {
+				t.Errorf("Order-%d: search for %v: linear gives (%v, %v), binary gives (%v, %v)", order, key, li, lok, bi, bok)
+			}
+			lfi, lfok := findOnPageLinearFunc(page, key, cmp.Compare[int])
+			bfi, bfok := findOnPageBinaryFunc(page, key, cmp.Compare[int])
+			if (li != lfi) || (lok != lfok) || (li != bfi) || (lok != bfok) {
+				t.Errorf("Order-%d: search for %v: comparator searches give (%v, %v) and (%v, %v), expected (%v, %v)", order, key, lfi, lfok, bfi, bfok, li, lok)
+			}
+		}
+	}
+}
+
+func benchmarkBtreeSearch(b *testing.B, mode SearchMode, order int) {
+	b.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Search = mode
+	bt.init()
+
+	page := bt.newPage(order - 1)
+	for i := 0; i < order-1; i++ {
+		page.Items[i].Key = 2 * i
+	}
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	keys := make([]int, 1024)
+	for i := 0; i < len(keys); i++ {
+		keys[i] = g.Generate() % (2 * order)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bt.findOnPage(page, keys[i%len(keys)])
+	}
+}
+
+/* BenchmarkBtreeSearch compares linear and binary search within full pages, it is used to choose BinarySearchOrder. */
+func BenchmarkBtreeSearch(b *testing.B) {
+	modes := [...]struct {
+		Name string
+		Mode SearchMode
+	}{
+		{"Linear", SearchLinear},
+		{"Binary", SearchBinary},
+	}
+
+	for _, mode := range modes {
+		b.Run(mode.Name, func(b *testing.B) {
+			for order := constants.MinOrder; order <= constants.MaxOrder; order += constants.OrderStep {
+				b.Run(fmt.Sprintf("Order-%d", order), func(b *testing.B) {
+					benchmarkBtreeSearch(b, mode.Mode, order)
+				})
+			}
+		})
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func comparePoints(a, b point) int {
+	return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+}
+
+func testBtreeFunc(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	bt := NewFunc[point, int](comparePoints)
+	bt.Order = constants.MinOrder
+
+	m := make(map[point]int)
+	for i := 0; i < constants.N; i++ {
+		/* Many keys share X, so that Y decides their order. */
+		x := g.Generate()
+		k := point{X: x % 100, Y: x}
+
+		m[k] = i
+		bt.Set(k, i)
+	}
+
+	check := func() {
+		t.Helper()
+
+		keys := slices.SortedFunc(maps.Keys(m), comparePoints)
+		var i int
+		for k, v := range bt.All() {
+			if (i >= len(keys)) || (k != keys[i]) || (v != m[k]) {
+				t.Errorf("All: unexpected pair %v: %v at %d", k, v, i)
+				return
+			}
+			i++
+		}
+		if i != len(keys) {
+			t.Errorf("All: expected %d keys, got %d", len(keys), i)
+		}
+	}
+	check()
+
+	for k := range m {
+		if k.Y%2 == 0 {
+			bt.Del(k)
+			if bt.Has(k) {
+				t.Errorf("expected key %v to be removed, but it's still present", k)
+			}
+			delete(m, k)
+		}
+	}
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	check()
+}
+
+func TestBtreeFunc(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testBtreeFunc(t, generator)
+		})
+	}
+}
+
+func benchmarkBtreeCompareGet(b *testing.B, bt *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = bt.Get(g.Generate())
+	}
+}
+
+func benchmarkBtreeCompareSet(b *testing.B, bt *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+}
+
+/* BenchmarkBtreeCompare compares zero value tree and New, which compare keys directly, with NewFunc, which calls comparator. */
+func BenchmarkBtreeCompare(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, *Tree[int, int])
+	}{
+		{"Get", benchmarkBtreeCompareGet},
+		{"Set", benchmarkBtreeCompareSet},
+	}
+
+	trees := [...]struct {
+		Name string
+		New  func() *Tree[int, int]
+	}{
+		{"Zero", func() *Tree[int, int] { return new(Tree[int, int]) }},
+		{"New", New[int, int]},
+		{"Func", func() *Tree[int, int] { return NewFunc[int, int](cmp.Compare[int]) }},
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, tree := range trees {
+				b.Run(tree.Name, func(b *testing.B) {
+					op.Func(b, tree.New())
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/anton2920/gofa/util"
+)
+
+var (
+	ErrBadFill  = errors.New("fill factor must be in (0, 1]")
+	ErrUnsorted = errors.New("keys are not in strictly ascending order")
+)
+
+/* parts splits 'n' elements into groups of about 'target' elements each, so that every group has from 'lo' to 'hi' elements. Sizes of groups differ at most by one. */
+func parts(n, target, lo, hi int) []int {
+	m := max((n+target-1)/target, 1)
+	for (m > 1) && (n < m*lo) {
+		m--
+	}
+	for n > m*hi {
+		m++
+	}
+
+	sizes := make([]int, m)
+	for i := 0; i < m; i++ {
+		sizes[i] = n/m + util.Bool2Int(i < n%m)
+	}
+	return sizes
+}
+
+/*
+ * BulkLoad replaces contents of the tree with pairs from 'seq', which must come in strictly ascending order of keys.
+ * Tree is built bottom-up, with every page filled to 'fill' fraction of its capacity, but never below the minimum Del keeps.
+ * On invalid input the tree is left untouched.
+ */
+func (t *Tree[K, V]) BulkLoad(seq iter.Seq2[K, V], fill float64) error {
+	if (fill <= 0) || (fill > 1) {
+		return ErrBadFill
+	}
+	t.init()
+
+	var items []Item[K, V]
+	for k, v := range seq {
+		if (len(items) > 0) && (t.Compare(k, items[len(items)-1].Key) <= 0) {
+			return fmt.Errorf("%w: %v after %v", ErrUnsorted, k, items[len(items)-1].Key)
+		}
+		items = append(items, Item[K, V]{Key: k, Value: v})
+	}
+
+	t.Clear()
+	if len(items) == 0 {
+		return nil
+	}
+	half := t.Order/2 - (1 - t.Order%2)
+
+	/*
+	 * Every terminal page but the last is followed by an item that goes up as a separator.
+	 * Counting that item as part of the page makes all groups uniform.
+	 */
+	var pages []*Page[K, V]
+	var seps []Item[K, V]
+
+	offset := 0
+	for _, n := range parts(len(items)+1, max(int(fill*float64(t.Order-1)), 1)+1, half+1, t.Order) {
+		page := t.newPage(n - 1)
+		copy(page.Items, items[offset:])
+		page.dirty = true
+		pages = append(pages, page)
+
+		offset += n
+		if offset <= len(items) {
+			seps = append(seps, items[offset-1])
+		}
+	}
+
+	for len(pages) > 1 {
+		var parents []*Page[K, V]
+		var parentSeps []Item[K, V]
+
+		offset = 0
+		for _, n := range parts(len(pages), max(int(fill*float64(t.Order)), 2), half+1, t.Order) {
+			page := t.newPage(n - 1)
+			page.ChildPage0 = pages[offset]
+			for i := 0; i < n-1; i++ {
+				page.Items[i] = seps[offset+i]
+				page.Items[i].ChildPage = pages[offset+i+1]
+			}
+			page.dirty = true
+			parents = append(parents, page)
+
+			offset += n
+			if offset < len(pages) {
+				parentSeps = append(parentSeps, seps[offset-1])
+			}
+		}
+		pages, seps = parents, parentSeps
+	}
+	t.Root = pages[0]
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package btree
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkPages verifies that all terminal pages are on the same level and every page but the root has from half to Order-1 items. Returns number of terminal pages. */
+func checkPages[K cmp.Ordered, V any](t *testing.T, bt *Tree[K, V]) int {
+	t.Helper()
+
+	half := bt.Order/2 - (1 - bt.Order%2)
+	depth := -1
+	leaves := 0
+
+	var walk func(page *Page[K, V], level int)
+	walk = func(page *Page[K, V], level int) {
+		if page.ChildPage0 == nil {
+			if depth == -1 {
+				depth = level
+			} else if depth != level {
+				t.Errorf("terminal page at level %d, expected %d", level, depth)
+			}
+			leaves++
+		} else {
+			walk(page.ChildPage0, level+1)
+			for i := 0; i < len(page.Items); i++ {
+				walk(page.Items[i].ChildPage, level+1)
+			}
+		}
+		if n := len(page.Items); (page != bt.Root) && ((n < half) || (n > bt.Order-1)) {
+			t.Errorf("page with %d items, expected from %d to %d", n, half, bt.Order-1)
+		}
+	}
+	if bt.Root != nil {
+		walk(bt.Root, 0)
+	}
+	return leaves
+}
+
+func countPages[K cmp.Ordered, V any](page *Page[K, V]) int {
+	if page == nil {
+		return 0
+	}
+
+	n := 1 + countPages(page.ChildPage0)
+	for i := 0; i < len(page.Items); i++ {
+		n += countPages(page.Items[i].ChildPage)
+	}
+	return n
+}
+
+func testBtreeBulkLoad(t *testing.T, g generator.Generator, order int, fill float64) {
+	t.Helper()
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		m[g.Generate()] = i
+	}
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Set(keys[0]-1, 0)
+
+	sorted := func(yield func(int, int) bool) {
+		for _, k := range keys {
+			if !yield(k, m[k]) {
+				return
+			}
+		}
+	}
+	if err := bt.BulkLoad(sorted, fill); err != nil {
+		t.Fatalf("failed to bulk load: %v", err)
+	}
+	if bt.Has(keys[0] - 1) {
+		t.Errorf("expected previous contents to be replaced")
+	}
+
+	/* Terminal pages hold at least as many keys as requested by fill factor, unless that is below the minimum. */
+	leaves := checkPages(t, &bt)
+	if capacity := max(int(fill*float64(order-1)), 1); leaves > (len(keys)+capacity-1)/capacity {
+		t.Errorf("expected at most %d terminal pages, got %d", (len(keys)+capacity-1)/capacity, leaves)
+	}
+
+	var got []int
+	for k, v := range bt.All() {
+		if v != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, v)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, keys) {
+		t.Errorf("expected keys %v, got %v", keys, got)
+	}
+
+	/* Bulk loaded tree must stay valid under regular modifications. */
+	for i := 0; i < len(keys); i += 2 {
+		bt.Del(keys[i])
+		delete(m, keys[i])
+	}
+	for i := 0; i < len(keys); i += 3 {
+		bt.Set(keys[i]+1, i)
+		m[keys[i]+1] = i
+	}
+	checkPages(t, &bt)
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("expected value %v for key %v, got %v", v, k, got)
+		}
+	}
+
+	unsorted := func(yield func(int, int) bool) {
+		for i := len(keys) - 1; i >= 0; i-- {
+			if !yield(keys[i], 0) {
+				return
+			}
+		}
+	}
+	if err := bt.BulkLoad(unsorted, fill); !errors.Is(err, ErrUnsorted) {
+		t.Errorf("expected %v, got %v", ErrUnsorted, err)
+	}
+	for k, v := range m {
+		if got := bt.Get(k); got != v {
+			t.Errorf("after failed bulk load: expected value %v for key %v, got %v", v, k, got)
+		}
+	}
+}
+
+func TestBtreeBulkLoad(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+	fills := [...]float64{0.1, 0.5, 0.75, 1}
+
+	for _, generator := range generators {
+		t.Run(generator.String(), func(t *testing.T) {
+			for _, order := range orders {
+				for _, fill := range fills {
+					t.Run(fmt.Sprintf("Order-%d/Fill-%v", order, fill), func(t *testing.T) {
+						generator.Reset()
+						testBtreeBulkLoad(t, generator, order, fill)
+					})
+				}
+			}
+		})
+	}
+
+	var bt Tree[int, int]
+	if err := bt.BulkLoad(func(func(int, int) bool) {}, 0); err != ErrBadFill {
+		t.Errorf("expected %v, got %v", ErrBadFill, err)
+	}
+}
+
+func benchmarkBtreeBulkLoad(b *testing.B, g generator.Generator, order int) {
+	b.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	if err := bt.BulkLoad(func(yield func(int, int) bool) {
+		for i := 0; i < b.N; i++ {
+			if !yield(g.Generate(), 0) {
+				return
+			}
+		}
+	}, 1); err != nil {
+		b.Fatalf("failed to bulk load: %v", err)
+	}
+
+	b.StopTimer()
+	b.ReportMetric(float64(countPages(bt.Root)), "pages")
+}
+
+func benchmarkBtreeBulkSet(b *testing.B, g generator.Generator, order int) {
+	b.Helper()
+
+	var bt Tree[int, int]
+	bt.Order = order
+
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+	}
+
+	b.StopTimer()
+	b.ReportMetric(float64(countPages(bt.Root)), "pages")
+}
+
+func BenchmarkBtreeBulkLoad(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, generator.Generator, int)
+	}{
+		{"BulkLoad", benchmarkBtreeBulkLoad},
+		{"Set", benchmarkBtreeBulkSet},
+	}
+
+	g := new(generator.AscendingGenerator)
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for order := constants.MinOrder; order <= constants.MaxOrder; order += constants.OrderStep {
+				b.Run(fmt.Sprintf("Order-%d", order), func(b *testing.B) {
+					g.Reset()
+					op.Func(b, g, order)
+				})
+			}
+		})
+	}
+}
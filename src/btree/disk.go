@@ -0,0 +1,259 @@
+package btree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"codec"
+	"pager"
+)
+
+/*
+ * Page layout, all integers are little-endian:
+ *	[count:2][childPage0:4][childPage:4, key, value...]
+ */
+const pageHeaderSize = 6
+
+var (
+	ErrNoStore      = errors.New("tree has no page store")
+	ErrPageOverflow = errors.New("page contents do not fit into page")
+	ErrCorrupted    = errors.New("page is corrupted")
+)
+
+func (t *Tree[K, V]) initCodecs() {
+	if t.KeyCodec == nil {
+		t.KeyCodec = codec.Default[K]{}
+	}
+	if t.ValueCodec == nil {
+		t.ValueCodec = codec.Default[V]{}
+	}
+}
+
+/* Err returns the first error of reading pages from Store. Modification that needed such page is not applied. */
+func (t *Tree[K, V]) Err() error {
+	return t.err
+}
+
+/* child returns child after 'index' of 'page', where -1 is ChildPage0, reading it from Store if needed. Child that is read is not put into 'page'. Returns nil if reading has failed, see Err. */
+func (t *Tree[K, V]) child(page *Page[K, V], index int) *Page[K, V] {
+	child := childAfter(page, index)
+	if (child == nil) || (!child.ref) {
+		return child
+	}
+
+	child, err := t.readPage(child.ID)
+	if err != nil {
+		if t.err == nil {
+			t.err = fmt.Errorf("failed to read page: %w", err)
+		}
+		return nil
+	}
+	return child
+}
+
+/* fetch is child which puts child into 'page', so that it may be modified. */
+func (t *Tree[K, V]) fetch(page *Page[K, V], index int) *Page[K, V] {
+	child := t.child(page, index)
+	if child == nil {
+		return nil
+	}
+	if index == -1 {
+		page.ChildPage0 = child
+	} else {
+		page.Items[index].ChildPage = child
+	}
+	return child
+}
+
+func pageID[K any, V any](page *Page[K, V]) pager.PageID {
+	if page == nil {
+		return pager.InvalidPage
+	}
+	return page.ID
+}
+
+func (t *Tree[K, V]) encodePage(buf []byte, page *Page[K, V]) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(page.Items)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(pageID(page.ChildPage0)))
+	for i := 0; i < len(page.Items); i++ {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(pageID(page.Items[i].ChildPage)))
+		buf = t.KeyCodec.Append(buf, page.Items[i].Key)
+		buf = t.ValueCodec.Append(buf, page.Items[i].Value)
+	}
+	return buf
+}
+
+/*
+ * flushPage writes 'page' and its descendants if they were modified since the last Flush. Children go first, so
+ * parents always see assigned IDs. Written children are replaced with references.
+ */
+func (t *Tree[K, V]) flushPage(page *Page[K, V]) error {
+	if (page == nil) || (page.ref) {
+		return nil
+	}
+
+	for i := -1; i < len(page.Items); i++ {
+		child := childAfter(page, i)
+		if err := t.flushPage(child); err != nil {
+			return err
+		}
+		if (child != nil) && (!child.ref) {
+			ref := &Page[K, V]{ID: child.ID, ref: true}
+			if i == -1 {
+				page.ChildPage0 = ref
+			} else {
+				page.Items[i].ChildPage = ref
+			}
+		}
+	}
+	if (!page.dirty) && (page.ID != pager.InvalidPage) {
+		return nil
+	}
+
+	if cap(t.pageBuf) < t.Store.PageSize() {
+		t.pageBuf = make([]byte, 0, t.Store.PageSize())
+	}
+	buf := t.encodePage(t.pageBuf[:0], page)
+	if len(buf) > t.Store.PageSize() {
+		return fmt.Errorf("%w: %d bytes, page size is %d", ErrPageOverflow, len(buf), t.Store.PageSize())
+	}
+
+	if page.ID == pager.InvalidPage {
+		id, err := t.Store.Alloc()
+		if err != nil {
+			return err
+		}
+		page.ID = id
+	}
+
+	n := len(buf)
+	buf = buf[:t.Store.PageSize()]
+	clear(buf[n:])
+	if err := t.Store.Write(page.ID, buf); err != nil {
+		return err
+	}
+	page.dirty = false
+
+	return nil
+}
+
+/* Flush writes all modified pages, releases disposed ones and makes the result durable. Written pages leave memory, except the root. */
+func (t *Tree[K, V]) Flush() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+	t.initCodecs()
+
+	for len(t.freed) > 0 {
+		if err := t.Store.Free(t.freed[len(t.freed)-1]); err != nil {
+			return fmt.Errorf("failed to free page: %w", err)
+		}
+		t.freed = t.freed[:len(t.freed)-1]
+	}
+
+	if err := t.flushPage(t.Root); err != nil {
+		return fmt.Errorf("failed to flush tree: %w", err)
+	}
+	t.Store.SetRoot(pageID(t.Root))
+
+	return t.Store.Sync()
+}
+
+/* readPage reads page 'id' from Store. Its children are references. */
+func (t *Tree[K, V]) readPage(id pager.PageID) (*Page[K, V], error) {
+	var page *Page[K, V]
+
+	err := t.viewPage(id, func(buf []byte) error {
+		var err error
+		page, err = t.decodePage(id, buf)
+		return err
+	})
+	return page, err
+}
+
+/* viewPage calls 'fn' with contents of page 'id', which are not copied if Store is pager.Viewer, like buffer pool. */
+func (t *Tree[K, V]) viewPage(id pager.PageID, fn func([]byte) error) error {
+	if v, ok := t.Store.(pager.Viewer); ok {
+		return v.View(id, fn)
+	}
+
+	if cap(t.readBuf) < t.Store.PageSize() {
+		t.readBuf = make([]byte, t.Store.PageSize())
+	}
+	buf := t.readBuf[:t.Store.PageSize()]
+	if err := t.Store.Read(id, buf); err != nil {
+		return err
+	}
+	return fn(buf)
+}
+
+func (t *Tree[K, V]) decodePage(id pager.PageID, buf []byte) (*Page[K, V], error) {
+
+	count := int(binary.LittleEndian.Uint16(buf))
+	if count >= t.Order {
+		return nil, fmt.Errorf("%w: page %d has %d items, order is %d", ErrCorrupted, id, count, t.Order)
+	}
+
+	page := t.newPage(count)
+	page.ID = id
+
+	if child := pager.PageID(binary.LittleEndian.Uint32(buf[2:])); child != pager.InvalidPage {
+		page.ChildPage0 = &Page[K, V]{ID: child, ref: true}
+	}
+
+	offset := pageHeaderSize
+	for i := 0; i < count; i++ {
+		if offset+4 > len(buf) {
+			return nil, fmt.Errorf("%w: page %d", ErrCorrupted, id)
+		}
+		if child := pager.PageID(binary.LittleEndian.Uint32(buf[offset:])); child != pager.InvalidPage {
+			page.Items[i].ChildPage = &Page[K, V]{ID: child, ref: true}
+		}
+		offset += 4
+
+		key, n, err := t.KeyCodec.Decode(buf[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key on page %d: %w", id, err)
+		}
+		offset += n
+
+		value, n, err := t.ValueCodec.Decode(buf[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode value on page %d: %w", id, err)
+		}
+		offset += n
+
+		page.Items[i].Key = key
+		page.Items[i].Value = value
+	}
+
+	return page, nil
+}
+
+/* Load replaces contents of the tree with the one stored in its page store. Only the root is read, other pages are read when they are needed. */
+func (t *Tree[K, V]) Load() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+	t.initCodecs()
+
+	t.Root = nil
+	t.freed = t.freed[:0]
+	t.err = nil
+
+	id := t.Store.Root()
+	if id == pager.InvalidPage {
+		return nil
+	}
+
+	root, err := t.readPage(id)
+	if err != nil {
+		return fmt.Errorf("failed to load tree: %w", err)
+	}
+	t.Root = root
+
+	return nil
+}
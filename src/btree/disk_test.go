@@ -0,0 +1,210 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"constants"
+	"generator"
+	"pager"
+)
+
+func testBtreeFlush(t *testing.T, g generator.Generator, order int) {
+	t.Helper()
+
+	p, err := pager.Open(filepath.Join(t.TempDir(), "btree.db"), 2*pager.DefaultPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Order = order
+	bt.Store = p
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		bt.Set(k, v)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	i := 0
+	for k := range m {
+		if i%2 == 0 {
+			bt.Del(k)
+			delete(m, k)
+		}
+		i++
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	var loaded Tree[int, int]
+	loaded.Order = order
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+
+	for k, v := range m {
+		if got := loaded.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	if loaded.String() != bt.String() {
+		t.Errorf("expected loaded tree to have the same shape")
+	}
+
+	/* Pages read from the store are modified and written again. */
+	i = 0
+	for k := range m {
+		if i%2 == 0 {
+			loaded.Del(k)
+			delete(m, k)
+		} else {
+			loaded.Set(k, k)
+			m[k] = k
+		}
+		i++
+	}
+	if err := loaded.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	if err := loaded.Err(); err != nil {
+		t.Fatalf("failed to read tree: %v", err)
+	}
+
+	var reloaded Tree[int, int]
+	reloaded.Order = order
+	reloaded.Store = p
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	n := 0
+	for k, v := range reloaded.All() {
+		if m[k] != v {
+			t.Errorf("expected value %v, got %v", m[k], v)
+		}
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys after reload, got %d", len(m), n)
+	}
+}
+
+func TestBtreeFlush(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+	orders := [...]int{constants.MinOrder, constants.MinOrder + 1, DefaultOrder, constants.MaxOrder}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, order := range orders {
+				t.Run(fmt.Sprintf("Order-%d", order), func(t *testing.T) {
+					testBtreeFlush(t, generator, order)
+				})
+			}
+		})
+	}
+}
+
+func TestBtreeFlushEvicts(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "btree.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Order = constants.MinOrder
+	bt.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+
+	if !bt.Root.ChildPage0.ref {
+		t.Fatalf("expected children of the root to be written out")
+	}
+	for i := 0; i < len(bt.Root.Items); i++ {
+		if !bt.Root.Items[i].ChildPage.ref {
+			t.Fatalf("expected children of the root to be written out")
+		}
+	}
+	for i := 0; i < constants.N; i++ {
+		if got := bt.Get(i); got != i {
+			t.Errorf("expected value %v, got %v", i, got)
+		}
+	}
+	if !bt.Root.ChildPage0.ref {
+		t.Errorf("expected Get not to keep pages in memory")
+	}
+}
+
+func TestBtreeFlushReusesPages(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "btree.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, int]
+	bt.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	count := p.PageCount()
+
+	bt.Clear()
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	for i := 0; i < constants.N; i++ {
+		bt.Set(i, i)
+	}
+	if err := bt.Flush(); err != nil {
+		t.Fatalf("failed to flush tree: %v", err)
+	}
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages, got %d", count, p.PageCount())
+	}
+}
+
+func TestBtreeFlushOverflow(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "btree.db"), pager.MinPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var bt Tree[int, string]
+	bt.Store = p
+
+	bt.Set(0, strings.Repeat("x", pager.MinPageSize))
+	if err := bt.Flush(); !errors.Is(err, ErrPageOverflow) {
+		t.Errorf("expected error %v, got %v", ErrPageOverflow, err)
+	}
+}
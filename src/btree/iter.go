@@ -0,0 +1,176 @@
+package btree
+
+import "iter"
+
+/* childAfter returns page with keys between page.Items[index] and page.Items[index+1]. */
+func childAfter[K any, V any](page *Page[K, V], index int) *Page[K, V] {
+	if index == -1 {
+		return page.ChildPage0
+	}
+	return page.Items[index].ChildPage
+}
+
+func entry[K any, V any](item *Item[K, V]) (K, V, bool) {
+	if item == nil {
+		var k K
+		var v V
+		return k, v, false
+	}
+	return item.Key, item.Value, true
+}
+
+/* ascend visits keys in [lo, hi) in ascending order. It returns false if iteration must stop. */
+func (t *Tree[K, V]) ascend(page *Page[K, V], lo, hi K, yield func(K, V) bool) bool {
+	if (page == nil) || (len(page.Items) == 0) {
+		return true
+	}
+
+	index, _ := t.findOnPage(page, lo)
+	if !t.ascend(t.child(page, index), lo, hi, yield) {
+		return false
+	}
+	for i := index + 1; i < len(page.Items); i++ {
+		if t.Compare(page.Items[i].Key, hi) >= 0 {
+			return false
+		}
+		if !yield(page.Items[i].Key, page.Items[i].Value) {
+			return false
+		}
+		if !t.ascend(t.child(page, i), lo, hi, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+/* descend visits keys in (hi, lo] in descending order. It returns false if iteration must stop. */
+func (t *Tree[K, V]) descend(page *Page[K, V], lo, hi K, yield func(K, V) bool) bool {
+	if (page == nil) || (len(page.Items) == 0) {
+		return true
+	}
+
+	index, ok := t.findOnPage(page, lo)
+	if ok {
+		index++
+	} else if !t.descend(t.child(page, index), lo, hi, yield) {
+		return false
+	}
+	for i := index; i >= 0; i-- {
+		if t.Compare(page.Items[i].Key, hi) <= 0 {
+			return false
+		}
+		if !yield(page.Items[i].Key, page.Items[i].Value) {
+			return false
+		}
+		if !t.descend(t.child(page, i-1), lo, hi, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Tree[K, V]) all(page *Page[K, V], yield func(K, V) bool) bool {
+	if page == nil {
+		return true
+	}
+
+	if !t.all(t.child(page, -1), yield) {
+		return false
+	}
+	for i := 0; i < len(page.Items); i++ {
+		if !yield(page.Items[i].Key, page.Items[i].Value) {
+			return false
+		}
+		if !t.all(t.child(page, i), yield) {
+			return false
+		}
+	}
+	return true
+}
+
+/* All returns iterator over all key-value pairs in ascending order. */
+func (t *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		t.all(t.Root, yield)
+	}
+}
+
+/* Ceiling returns the smallest key greater than or equal to 'key'. */
+func (t *Tree[K, V]) Ceiling(key K) (K, V, bool) {
+	var found *Item[K, V]
+
+	page := t.Root
+	for (page != nil) && (len(page.Items) > 0) {
+		index, ok := t.findOnPage(page, key)
+		if index+1 < len(page.Items) {
+			found = &page.Items[index+1]
+		}
+		if ok {
+			break
+		}
+		page = t.child(page, index)
+	}
+
+	return entry(found)
+}
+
+/* Floor returns the largest key less than or equal to 'key'. */
+func (t *Tree[K, V]) Floor(key K) (K, V, bool) {
+	var found *Item[K, V]
+
+	page := t.Root
+	for (page != nil) && (len(page.Items) > 0) {
+		index, ok := t.findOnPage(page, key)
+		if ok {
+			found = &page.Items[index+1]
+			break
+		}
+		if index >= 0 {
+			found = &page.Items[index]
+		}
+		page = t.child(page, index)
+	}
+
+	return entry(found)
+}
+
+func (t *Tree[K, V]) Max() (K, V, bool) {
+	var found *Item[K, V]
+
+	page := t.Root
+	for (page != nil) && (len(page.Items) > 0) {
+		found = &page.Items[len(page.Items)-1]
+		page = t.child(page, len(page.Items)-1)
+	}
+
+	return entry(found)
+}
+
+func (t *Tree[K, V]) Min() (K, V, bool) {
+	var found *Item[K, V]
+
+	page := t.Root
+	for (page != nil) && (len(page.Items) > 0) {
+		found = &page.Items[0]
+		page = t.child(page, -1)
+	}
+
+	return entry(found)
+}
+
+/*
+ * Range returns iterator over keys from 'lo' up to, but not including, 'hi'. If 'lo' is greater than 'hi', keys are
+ * visited in descending order, again from 'lo' inclusive to 'hi' exclusive.
+ */
+func (t *Tree[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if t.Root == nil {
+			return
+		}
+		if t.Compare(lo, hi) <= 0 {
+			t.ascend(t.Root, lo, hi, yield)
+		} else {
+			t.descend(t.Root, lo, hi, yield)
+		}
+	}
+}
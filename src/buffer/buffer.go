@@ -0,0 +1,243 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+
+	"pager"
+)
+
+type Frame struct {
+	ID   pager.PageID
+	Data []byte
+
+	pins  int
+	dirty bool
+}
+
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+/* Pool caches pages of a pager in a fixed number of frames. Pinned frames are never evicted. */
+type Pool struct {
+	Pager  *pager.Pager
+	Policy Policy
+
+	Stats Stats
+
+	frames []Frame
+	table  map[pager.PageID]int
+	free   []int
+}
+
+var (
+	ErrNoFrames = errors.New("all frames are pinned")
+	ErrPinned   = errors.New("page is pinned")
+)
+
+/* New creates pool holding as many pages as fit into 'budget' bytes, but at least one. */
+func New(p *pager.Pager, budget int, policy Policy) *Pool {
+	n := max(budget/p.PageSize(), 1)
+
+	bp := &Pool{Pager: p, Policy: policy}
+	bp.frames = make([]Frame, n)
+	bp.table = make(map[pager.PageID]int, n)
+	bp.free = make([]int, n)
+
+	data := make([]byte, n*p.PageSize())
+	for i := 0; i < n; i++ {
+		bp.frames[i].Data = data[i*p.PageSize() : (i+1)*p.PageSize()]
+		bp.free[i] = n - 1 - i
+	}
+	policy.Init(n)
+
+	return bp
+}
+
+func (bp *Pool) pinned(frame int) bool {
+	return bp.frames[frame].pins > 0
+}
+
+func (bp *Pool) writeBack(f *Frame) error {
+	if f.dirty {
+		if err := bp.Pager.Write(f.ID, f.Data); err != nil {
+			return err
+		}
+		f.dirty = false
+	}
+	return nil
+}
+
+/* frame returns pinned frame for page 'id'. Contents are read from disk only if 'read' is set. */
+func (bp *Pool) frame(id pager.PageID, read bool) (*Frame, error) {
+	if (id == pager.InvalidPage) || (id >= bp.Pager.PageCount()) {
+		return nil, fmt.Errorf("%w: %d", pager.ErrBadPage, id)
+	}
+
+	if i, ok := bp.table[id]; ok {
+		bp.Stats.Hits++
+
+		f := &bp.frames[i]
+		f.pins++
+		bp.Policy.Access(i)
+		return f, nil
+	}
+	bp.Stats.Misses++
+
+	var i int
+	if len(bp.free) > 0 {
+		i = bp.free[len(bp.free)-1]
+		bp.free = bp.free[:len(bp.free)-1]
+	} else {
+		i = bp.Policy.Victim(bp.pinned)
+		if i == -1 {
+			return nil, ErrNoFrames
+		}
+
+		victim := &bp.frames[i]
+		if err := bp.writeBack(victim); err != nil {
+			return nil, fmt.Errorf("failed to evict page %d: %w", victim.ID, err)
+		}
+		delete(bp.table, victim.ID)
+		bp.Policy.Remove(i)
+		bp.Stats.Evictions++
+	}
+
+	f := &bp.frames[i]
+	if read {
+		if err := bp.Pager.Read(id, f.Data); err != nil {
+			bp.free = append(bp.free, i)
+			return nil, err
+		}
+	}
+	f.ID = id
+	f.pins = 1
+	f.dirty = false
+	bp.table[id] = i
+	bp.Policy.Access(i)
+
+	return f, nil
+}
+
+func (bp *Pool) Alloc() (pager.PageID, error) {
+	return bp.Pager.Alloc()
+}
+
+func (bp *Pool) Close() error {
+	if err := bp.Sync(); err != nil {
+		bp.Pager.Close()
+		return err
+	}
+	return bp.Pager.Close()
+}
+
+/* Fetch pins page 'id', reading it from disk if needed. Each Fetch must be paired with Unpin. */
+func (bp *Pool) Fetch(id pager.PageID) (*Frame, error) {
+	return bp.frame(id, true)
+}
+
+func (bp *Pool) Free(id pager.PageID) error {
+	if i, ok := bp.table[id]; ok {
+		if bp.pinned(i) {
+			return fmt.Errorf("%w: %d", ErrPinned, id)
+		}
+		delete(bp.table, id)
+		bp.Policy.Remove(i)
+		bp.frames[i].dirty = false
+		bp.free = append(bp.free, i)
+	}
+	return bp.Pager.Free(id)
+}
+
+func (bp *Pool) Frames() int {
+	return len(bp.frames)
+}
+
+func (bp *Pool) LSN() uint64 {
+	return bp.Pager.LSN()
+}
+
+func (bp *Pool) PageSize() int {
+	return bp.Pager.PageSize()
+}
+
+func (bp *Pool) Read(id pager.PageID, buf []byte) error {
+	if len(buf) != bp.PageSize() {
+		return fmt.Errorf("%w: buffer of %d bytes", pager.ErrBadPageSize, len(buf))
+	}
+
+	f, err := bp.Fetch(id)
+	if err != nil {
+		return err
+	}
+	copy(buf, f.Data)
+	bp.Unpin(f, false)
+
+	return nil
+}
+
+func (bp *Pool) Root() pager.PageID {
+	return bp.Pager.Root()
+}
+
+func (bp *Pool) SetLSN(lsn uint64) {
+	bp.Pager.SetLSN(lsn)
+}
+
+func (bp *Pool) SetRoot(id pager.PageID) {
+	bp.Pager.SetRoot(id)
+}
+
+/* Sync writes all dirty frames back and syncs the pager. */
+func (bp *Pool) Sync() error {
+	for i := 0; i < len(bp.frames); i++ {
+		f := &bp.frames[i]
+		if err := bp.writeBack(f); err != nil {
+			return fmt.Errorf("failed to write page %d back: %w", f.ID, err)
+		}
+	}
+	return bp.Pager.Sync()
+}
+
+func (bp *Pool) Unpin(f *Frame, dirty bool) {
+	if f.pins <= 0 {
+		panic("buffer: unpin of unpinned frame")
+	}
+	f.pins--
+	f.dirty = f.dirty || dirty
+}
+
+/* View calls 'fn' with contents of page 'id' while its frame is pinned. */
+func (bp *Pool) View(id pager.PageID, fn func(data []byte) error) error {
+	f, err := bp.Fetch(id)
+	if err != nil {
+		return err
+	}
+	defer bp.Unpin(f, false)
+
+	return fn(f.Data)
+}
+
+/* Write replaces whole contents of page 'id', so the old contents are never read from disk. */
+func (bp *Pool) Write(id pager.PageID, buf []byte) error {
+	if len(buf) != bp.PageSize() {
+		return fmt.Errorf("%w: buffer of %d bytes", pager.ErrBadPageSize, len(buf))
+	}
+
+	f, err := bp.frame(id, false)
+	if err != nil {
+		return err
+	}
+	copy(f.Data, buf)
+	bp.Unpin(f, true)
+
+	return nil
+}
+
+var (
+	_ pager.Store  = &Pool{}
+	_ pager.Viewer = &Pool{}
+)
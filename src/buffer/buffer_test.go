@@ -0,0 +1,395 @@
+package buffer
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"bplus"
+	"btree"
+	"constants"
+	"generator"
+	"pager"
+)
+
+const (
+	Frames = 64
+	Pages  = 256
+
+	/* Number of tree modifications between flushes in benchmarks. */
+	FlushEvery = 100
+)
+
+func newPool(tb testing.TB, pageSize int, frames int, policy Policy) *Pool {
+	tb.Helper()
+
+	p, err := pager.Open(filepath.Join(tb.TempDir(), "buffer.db"), pageSize)
+	if err != nil {
+		tb.Fatalf("failed to open pager: %v", err)
+	}
+	return New(p, frames*p.PageSize(), policy)
+}
+
+func policies() [3]Policy {
+	return [...]Policy{NewLRU(), NewClock(), NewLRUK(DefaultK)}
+}
+
+func testPool(t *testing.T, policy Policy) {
+	t.Helper()
+
+	bp := newPool(t, pager.DefaultPageSize, Frames, policy)
+	defer bp.Close()
+
+	buf := make([]byte, bp.PageSize())
+	ids := make([]pager.PageID, Pages)
+	for i := 0; i < Pages; i++ {
+		id, err := bp.Alloc()
+		if err != nil {
+			t.Fatalf("failed to allocate page: %v", err)
+		}
+		ids[i] = id
+
+		for j := range buf {
+			buf[j] = byte(i)
+		}
+		if err := bp.Write(id, buf); err != nil {
+			t.Fatalf("failed to write page %d: %v", id, err)
+		}
+	}
+	if bp.Stats.Evictions != Pages-Frames {
+		t.Errorf("expected %d evictions, got %d", Pages-Frames, bp.Stats.Evictions)
+	}
+
+	for i := 0; i < Pages; i++ {
+		if err := bp.Read(ids[i], buf); err != nil {
+			t.Fatalf("failed to read page %d: %v", ids[i], err)
+		}
+		if !bytes.Equal(buf, bytes.Repeat([]byte{byte(i)}, len(buf))) {
+			t.Errorf("page %d has unexpected contents", ids[i])
+		}
+	}
+
+	/* Pinned frames must survive any amount of traffic. */
+	f, err := bp.Fetch(ids[0])
+	if err != nil {
+		t.Fatalf("failed to fetch page %d: %v", ids[0], err)
+	}
+	f.Data[0] = 0xFF
+	for i := 1; i < Pages; i++ {
+		if err := bp.Read(ids[i], buf); err != nil {
+			t.Fatalf("failed to read page %d: %v", ids[i], err)
+		}
+	}
+	if err := bp.Free(ids[0]); !errors.Is(err, ErrPinned) {
+		t.Errorf("expected error %v, got %v", ErrPinned, err)
+	}
+	bp.Unpin(f, true)
+
+	if err := bp.Sync(); err != nil {
+		t.Fatalf("failed to sync pool: %v", err)
+	}
+	if err := bp.Pager.Read(ids[0], buf); err != nil {
+		t.Fatalf("failed to read page %d: %v", ids[0], err)
+	}
+	if buf[0] != 0xFF {
+		t.Errorf("expected dirty page to be written back")
+	}
+}
+
+func TestPool(t *testing.T) {
+	for _, policy := range policies() {
+		t.Run(policyName(policy), func(t *testing.T) {
+			testPool(t, policy)
+		})
+	}
+}
+
+func TestPoolNoFrames(t *testing.T) {
+	bp := newPool(t, pager.MinPageSize, 1, NewLRU())
+	defer bp.Close()
+
+	id1, _ := bp.Alloc()
+	id2, _ := bp.Alloc()
+
+	f, err := bp.Fetch(id1)
+	if err != nil {
+		t.Fatalf("failed to fetch page %d: %v", id1, err)
+	}
+	if _, err := bp.Fetch(id2); err != ErrNoFrames {
+		t.Errorf("expected error %v, got %v", ErrNoFrames, err)
+	}
+	bp.Unpin(f, false)
+}
+
+func TestPoolTrees(t *testing.T) {
+	for _, policy := range policies() {
+		t.Run(policyName(policy), func(t *testing.T) {
+			bp := newPool(t, pager.DefaultPageSize, Frames, policy)
+			defer bp.Close()
+
+			/* Both trees keep their root in page store metadata, so they take turns on the same pool. */
+			var bp1 bplus.Tree[int, int]
+			bp1.Store = bp
+			for i := 0; i < constants.N; i++ {
+				bp1.Set(i, i)
+			}
+			if err := bp1.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+			var bp2 bplus.Tree[int, int]
+			bp2.Store = bp
+			if err := bp2.Load(); err != nil {
+				t.Fatalf("failed to load tree: %v", err)
+			}
+			for i := 0; i < constants.N; i++ {
+				if got := bp2.Get(i); got != i {
+					t.Errorf("expected value %v, got %v", i, got)
+				}
+			}
+			bp2.Clear()
+			if err := bp2.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+
+			var bt1 btree.Tree[int, int]
+			bt1.Store = bp
+			for i := 0; i < constants.N; i++ {
+				bt1.Set(i, i)
+			}
+			if err := bt1.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+			var bt2 btree.Tree[int, int]
+			bt2.Store = bp
+			if err := bt2.Load(); err != nil {
+				t.Fatalf("failed to load tree: %v", err)
+			}
+			for i := 0; i < constants.N; i++ {
+				if got := bt2.Get(i); got != i {
+					t.Errorf("expected value %v, got %v", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolView(t *testing.T) {
+	bp := newPool(t, pager.MinPageSize, 1, NewLRU())
+	defer bp.Close()
+
+	id, _ := bp.Alloc()
+	id2, _ := bp.Alloc()
+	buf := bytes.Repeat([]byte{1}, bp.PageSize())
+	if err := bp.Write(id, buf); err != nil {
+		t.Fatalf("failed to write page %d: %v", id, err)
+	}
+
+	err := bp.View(id, func(data []byte) error {
+		if !bytes.Equal(data, buf) {
+			t.Errorf("expected contents of page %d", id)
+		}
+		if _, err := bp.Fetch(id2); err != ErrNoFrames {
+			t.Errorf("expected frame to be pinned, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to view page %d: %v", id, err)
+	}
+	if bp.pinned(bp.table[id]) {
+		t.Errorf("expected frame to be unpinned")
+	}
+}
+
+/* testPoolTreeGets reads 'n' keys with 'get' and checks that every read went through the pool, which is smaller than the tree. */
+func testPoolTreeGets(t *testing.T, bp *Pool, n int, get func(int) int) {
+	t.Helper()
+
+	if pages := int(bp.Pager.PageCount()); pages <= 2*bp.Frames() {
+		t.Fatalf("expected tree to be larger than the pool, got %d pages and %d frames", pages, bp.Frames())
+	}
+
+	stats := bp.Stats
+	for i := 0; i < n; i++ {
+		if got := get(i); got != i {
+			t.Errorf("expected value %v, got %v", i, got)
+		}
+	}
+	if bp.Stats.Hits+bp.Stats.Misses < stats.Hits+stats.Misses+uint64(n) {
+		t.Errorf("expected every Get to fetch pages from the pool")
+	}
+	if bp.Stats.Evictions == stats.Evictions {
+		t.Errorf("expected Get to evict pages")
+	}
+}
+
+func TestPoolTreesLargerThanPool(t *testing.T) {
+	for _, policy := range policies() {
+		t.Run(policyName(policy), func(t *testing.T) {
+			bp := newPool(t, pager.MinPageSize, Frames/8, policy)
+			defer bp.Close()
+
+			var bp1 bplus.Tree[int, int]
+			bp1.Store = bp
+			for i := 0; i < constants.N; i++ {
+				bp1.Set(i, i)
+				if i%FlushEvery == 0 {
+					if err := bp1.Flush(); err != nil {
+						t.Fatalf("failed to flush tree: %v", err)
+					}
+				}
+			}
+			if err := bp1.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+			testPoolTreeGets(t, bp, constants.N, bp1.Get)
+			if err := bp1.Err(); err != nil {
+				t.Fatalf("failed to read tree: %v", err)
+			}
+			bp1.Clear()
+			if err := bp1.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+
+			var bt1 btree.Tree[int, int]
+			bt1.Store = bp
+			for i := 0; i < constants.N; i++ {
+				bt1.Set(i, i)
+				if i%FlushEvery == 0 {
+					if err := bt1.Flush(); err != nil {
+						t.Fatalf("failed to flush tree: %v", err)
+					}
+				}
+			}
+			if err := bt1.Flush(); err != nil {
+				t.Fatalf("failed to flush tree: %v", err)
+			}
+			testPoolTreeGets(t, bp, constants.N, bt1.Get)
+			if err := bt1.Err(); err != nil {
+				t.Fatalf("failed to read tree: %v", err)
+			}
+		})
+	}
+}
+
+func policyName(p Policy) string {
+	switch p.(type) {
+	case *LRU:
+		return "LRU"
+	case *Clock:
+		return "Clock"
+	case *LRUK:
+		return "LRU-K"
+	}
+	return "Unknown"
+}
+
+func reportStats(b *testing.B, bp *Pool) {
+	b.Helper()
+
+	b.ReportMetric(float64(bp.Stats.Hits)/float64(b.N), "hits/op")
+	b.ReportMetric(float64(bp.Stats.Misses)/float64(b.N), "misses/op")
+}
+
+func benchmarkPoolFetch(b *testing.B, g generator.Generator, policy Policy) {
+	b.Helper()
+
+	bp := newPool(b, pager.MinPageSize, Frames, policy)
+	defer bp.Close()
+
+	for i := 0; i < Pages; i++ {
+		if _, err := bp.Alloc(); err != nil {
+			b.Fatalf("failed to allocate page: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := g.Generate()
+		if k < 0 {
+			k = -k
+		}
+		f, err := bp.Fetch(pager.PageID(1 + k%Pages))
+		if err != nil {
+			b.Fatalf("failed to fetch page: %v", err)
+		}
+		bp.Unpin(f, false)
+	}
+	reportStats(b, bp)
+}
+
+func benchmarkPoolBplus(b *testing.B, g generator.Generator, policy Policy) {
+	b.Helper()
+
+	bp := newPool(b, pager.DefaultPageSize, Frames, policy)
+	defer bp.Close()
+
+	var bt bplus.Tree[int, int]
+	bt.Store = bp
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+		if i%FlushEvery == 0 {
+			if err := bt.Flush(); err != nil {
+				b.Fatalf("failed to flush tree: %v", err)
+			}
+		}
+	}
+	reportStats(b, bp)
+}
+
+func benchmarkPoolBtree(b *testing.B, g generator.Generator, policy Policy) {
+	b.Helper()
+
+	bp := newPool(b, pager.DefaultPageSize, Frames, policy)
+	defer bp.Close()
+
+	var bt btree.Tree[int, int]
+	bt.Store = bp
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bt.Set(g.Generate(), 0)
+		if i%FlushEvery == 0 {
+			if err := bt.Flush(); err != nil {
+				b.Fatalf("failed to flush tree: %v", err)
+			}
+		}
+	}
+	reportStats(b, bp)
+}
+
+func BenchmarkPool(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, generator.Generator, Policy)
+	}{
+		{"Fetch", benchmarkPoolFetch},
+		{"Bplus", benchmarkPoolBplus},
+		{"Btree", benchmarkPoolBtree},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, generator := range generators {
+				b.Run(generator.String(), func(b *testing.B) {
+					for _, policy := range policies() {
+						b.Run(policyName(policy), func(b *testing.B) {
+							generator.Reset()
+							op.Func(b, generator, policy)
+						})
+					}
+				})
+			}
+		})
+	}
+}
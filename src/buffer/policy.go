@@ -0,0 +1,186 @@
+package buffer
+
+/* Policy decides which unpinned frame to evict. Frames are identified by their index in the pool. */
+type Policy interface {
+	Init(frames int)
+	Access(frame int)
+	Remove(frame int)
+	Victim(pinned func(frame int) bool) int
+}
+
+type LRU struct {
+	/* Intrusive doubly-linked list of frames, 'head' is the most recently used one. */
+	prev, next []int
+	used       []bool
+	head, tail int
+}
+
+type Clock struct {
+	ref  []bool
+	used []bool
+	hand int
+}
+
+type LRUK struct {
+	K int
+
+	/* history[frame*K:(frame+1)*K] keeps last K access times, newest first. */
+	history []uint64
+	count   []int
+	now     uint64
+}
+
+const DefaultK = 2
+
+func NewLRU() *LRU {
+	return new(LRU)
+}
+
+func NewClock() *Clock {
+	return new(Clock)
+}
+
+func NewLRUK(k int) *LRUK {
+	return &LRUK{K: k}
+}
+
+func (p *LRU) Init(frames int) {
+	p.prev = make([]int, frames)
+	p.next = make([]int, frames)
+	p.used = make([]bool, frames)
+	p.head = -1
+	p.tail = -1
+}
+
+func (p *LRU) unlink(frame int) {
+	if p.prev[frame] != -1 {
+		p.next[p.prev[frame]] = p.next[frame]
+	} else {
+		p.head = p.next[frame]
+	}
+	if p.next[frame] != -1 {
+		p.prev[p.next[frame]] = p.prev[frame]
+	} else {
+		p.tail = p.prev[frame]
+	}
+}
+
+func (p *LRU) Access(frame int) {
+	if p.used[frame] {
+		p.unlink(frame)
+	}
+	p.used[frame] = true
+
+	p.prev[frame] = -1
+	p.next[frame] = p.head
+	if p.head != -1 {
+		p.prev[p.head] = frame
+	} else {
+		p.tail = frame
+	}
+	p.head = frame
+}
+
+func (p *LRU) Remove(frame int) {
+	if p.used[frame] {
+		p.unlink(frame)
+		p.used[frame] = false
+	}
+}
+
+func (p *LRU) Victim(pinned func(int) bool) int {
+	for frame := p.tail; frame != -1; frame = p.prev[frame] {
+		if !pinned(frame) {
+			return frame
+		}
+	}
+	return -1
+}
+
+func (p *Clock) Init(frames int) {
+	p.ref = make([]bool, frames)
+	p.used = make([]bool, frames)
+	p.hand = 0
+}
+
+func (p *Clock) Access(frame int) {
+	p.ref[frame] = true
+	p.used[frame] = true
+}
+
+func (p *Clock) Remove(frame int) {
+	p.ref[frame] = false
+	p.used[frame] = false
+}
+
+func (p *Clock) Victim(pinned func(int) bool) int {
+	/* Second sweep is guaranteed to find a victim, if there is one, since first sweep clears all reference bits. */
+	for i := 0; i < 2*len(p.ref); i++ {
+		frame := p.hand
+		p.hand = (p.hand + 1) % len(p.ref)
+
+		if (!p.used[frame]) || (pinned(frame)) {
+			continue
+		}
+		if p.ref[frame] {
+			p.ref[frame] = false
+			continue
+		}
+		return frame
+	}
+	return -1
+}
+
+func (p *LRUK) Init(frames int) {
+	if p.K <= 0 {
+		p.K = DefaultK
+	}
+	p.history = make([]uint64, frames*p.K)
+	p.count = make([]int, frames)
+	p.now = 0
+}
+
+func (p *LRUK) Access(frame int) {
+	p.now++
+
+	h := p.history[frame*p.K : (frame+1)*p.K]
+	copy(h[1:], h)
+	h[0] = p.now
+	p.count[frame] = min(p.count[frame]+1, p.K)
+}
+
+func (p *LRUK) Remove(frame int) {
+	p.count[frame] = 0
+}
+
+/* Victim picks frame with the largest backward K-distance. Frames with fewer than K accesses have infinite distance and are evicted in LRU order. */
+func (p *LRUK) Victim(pinned func(int) bool) int {
+	victim := -1
+	var victimInf bool
+	var victimTime uint64
+
+	for frame := 0; frame < len(p.count); frame++ {
+		if (p.count[frame] == 0) || (pinned(frame)) {
+			continue
+		}
+
+		inf := p.count[frame] < p.K
+		time := p.history[frame*p.K]
+		if !inf {
+			time = p.history[frame*p.K+p.K-1]
+		}
+		if (victim == -1) || (inf && !victimInf) || ((inf == victimInf) && (time < victimTime)) {
+			victim = frame
+			victimInf = inf
+			victimTime = time
+		}
+	}
+
+	return victim
+}
+
+var (
+	_ Policy = &LRU{}
+	_ Policy = &Clock{}
+	_ Policy = &LRUK{}
+)
@@ -0,0 +1,54 @@
+package buffer
+
+import "testing"
+
+func testPolicyVictims(t *testing.T, p Policy, accesses []int, pinned []int, expected []int) {
+	t.Helper()
+
+	p.Init(4)
+	for _, frame := range accesses {
+		p.Access(frame)
+	}
+
+	isPinned := func(frame int) bool {
+		for _, pin := range pinned {
+			if frame == pin {
+				return true
+			}
+		}
+		return false
+	}
+	for _, want := range expected {
+		got := p.Victim(isPinned)
+		if got != want {
+			t.Fatalf("expected victim %d, got %d", want, got)
+		}
+		if got != -1 {
+			p.Remove(got)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	tests := [...]struct {
+		Name     string
+		Policy   Policy
+		Accesses []int
+		Pinned   []int
+		Expected []int
+	}{
+		{"LRU", NewLRU(), []int{0, 1, 2, 3, 0, 2}, nil, []int{1, 3, 0, 2, -1}},
+		{"LRU-Pinned", NewLRU(), []int{0, 1, 2, 3}, []int{0, 1}, []int{2, 3, -1}},
+		{"Clock", NewClock(), []int{0, 1, 2, 3}, nil, []int{0, 1, 2, 3, -1}},
+		{"Clock-Pinned", NewClock(), []int{0, 1, 2, 3}, []int{0, 2}, []int{1, 3, -1}},
+		/* Frames 1 and 3 were accessed once, so their K-distance is infinite. */
+		{"LRU-K", NewLRUK(2), []int{0, 1, 0, 2, 3, 2}, nil, []int{1, 3, 0, 2, -1}},
+		{"LRU-K-Pinned", NewLRUK(2), []int{0, 1, 0, 2, 3, 2}, []int{1}, []int{3, 0, 2, -1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			testPolicyVictims(t, test.Policy, test.Accesses, test.Pinned, test.Expected)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+/* Codec serializes values of type T into pages and log records. */
+type Codec[T any] interface {
+	Append(buf []byte, v T) []byte
+	Decode(buf []byte) (T, int, error)
+}
+
+/*
+ * Raw is implemented by codecs which can store values as plain bytes without their length. Pages of a tree keep
+ * such keys with the prefix shared by all of them stored once. Raw reports whether values of T can be stored so.
+ */
+type Raw[T any] interface {
+	Raw() bool
+	AppendRaw(buf []byte, v T) []byte
+	DecodeRaw(buf []byte) (T, error)
+}
+
+/* Default handles booleans, integers, floats, strings and byte slices, including named types built on them. */
+type Default[T any] struct{}
+
+var (
+	ErrShortBuffer = errors.New("short buffer")
+	ErrUnsupported = errors.New("unsupported type")
+)
+
+func (Default[T]) Append(buf []byte, v T) []byte {
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.Bool:
+		var b byte
+		if rv.Bool() {
+			b = 1
+		}
+		return append(buf, b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(buf, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(buf, rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(rv.Float()))
+	case reflect.String:
+		buf = binary.AppendUvarint(buf, uint64(rv.Len()))
+		return append(buf, rv.String()...)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			buf = binary.AppendUvarint(buf, uint64(rv.Len()))
+			return append(buf, rv.Bytes()...)
+		}
+	}
+	panic(fmt.Sprintf("codec: %v: %v", ErrUnsupported, rv.Type()))
+}
+
+func (Default[T]) Decode(buf []byte) (T, int, error) {
+	var v T
+
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.Bool:
+		if len(buf) < 1 {
+			return v, 0, ErrShortBuffer
+		}
+		rv.SetBool(buf[0] != 0)
+		return v, 1, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, n := binary.Varint(buf)
+		if n <= 0 {
+			return v, 0, ErrShortBuffer
+		}
+		rv.SetInt(x)
+		return v, n, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return v, 0, ErrShortBuffer
+		}
+		rv.SetUint(x)
+		return v, n, nil
+	case reflect.Float32, reflect.Float64:
+		if len(buf) < 8 {
+			return v, 0, ErrShortBuffer
+		}
+		rv.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(buf)))
+		return v, 8, nil
+	case reflect.String:
+		l, n := binary.Uvarint(buf)
+		if (n <= 0) || (uint64(len(buf)-n) < l) {
+			return v, 0, ErrShortBuffer
+		}
+		rv.SetString(string(buf[n : n+int(l)]))
+		return v, n + int(l), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			l, n := binary.Uvarint(buf)
+			if (n <= 0) || (uint64(len(buf)-n) < l) {
+				return v, 0, ErrShortBuffer
+			}
+			rv.SetBytes(append([]byte(nil), buf[n:n+int(l)]...))
+			return v, n + int(l), nil
+		}
+	}
+	return v, 0, fmt.Errorf("%w: %v", ErrUnsupported, rv.Type())
+}
+
+/* Raw reports whether T is a string or a byte slice, only these can be stored as raw bytes. */
+func (Default[T]) Raw() bool {
+	t := reflect.TypeFor[T]()
+	return (t.Kind() == reflect.String) || ((t.Kind() == reflect.Slice) && (t.Elem().Kind() == reflect.Uint8))
+}
+
+func (Default[T]) AppendRaw(buf []byte, v T) []byte {
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		return append(buf, rv.String()...)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return append(buf, rv.Bytes()...)
+		}
+	}
+	panic(fmt.Sprintf("codec: %v: %v", ErrUnsupported, rv.Type()))
+}
+
+/* DecodeRaw takes the whole 'buf' as the value, which does not refer to 'buf' afterwards. */
+func (Default[T]) DecodeRaw(buf []byte) (T, error) {
+	var v T
+
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(string(buf))
+		return v, nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes(append([]byte(nil), buf...))
+			return v, nil
+		}
+	}
+	return v, fmt.Errorf("%w: %v", ErrUnsupported, rv.Type())
+}
@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func testCodec[T comparable](t *testing.T, values ...T) {
+	t.Helper()
+
+	var c Default[T]
+	var buf []byte
+
+	for _, v := range values {
+		buf = c.Append(buf, v)
+	}
+	for _, v := range values {
+		got, n, err := c.Decode(buf)
+		if err != nil {
+			t.Fatalf("failed to decode %v: %v", v, err)
+		}
+		if got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+		buf = buf[n:]
+	}
+	if len(buf) != 0 {
+		t.Errorf("expected buffer to be consumed, %d bytes left", len(buf))
+	}
+}
+
+func TestDefault(t *testing.T) {
+	type Named int
+
+	testCodec(t, true, false)
+	testCodec(t, 0, 1, -1, 1<<62, -1<<63)
+	testCodec[uint8](t, 0, 1, 255)
+	testCodec[uint64](t, 0, 1<<64-1)
+	testCodec(t, 0.0, -1.5, 3.14159)
+	testCodec[float32](t, 0.5, -2.25)
+	testCodec(t, "", "a", "hello, world")
+	testCodec[Named](t, 42, -42)
+
+	var c Default[[]byte]
+	buf := c.Append(nil, []byte("bytes"))
+	got, _, err := c.Decode(buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if !bytes.Equal(got, []byte("bytes")) {
+		t.Errorf("expected value %q, got %q", "bytes", got)
+	}
+}
+
+func TestDefaultShortBuffer(t *testing.T) {
+	var c Default[string]
+
+	buf := c.Append(nil, "truncated")
+	if _, _, err := c.Decode(buf[:len(buf)-1]); err != ErrShortBuffer {
+		t.Errorf("expected error %v, got %v", ErrShortBuffer, err)
+	}
+}
+
+func TestDefaultRaw(t *testing.T) {
+	type Named string
+
+	var s Default[Named]
+	if !s.Raw() {
+		t.Fatalf("expected %T to be raw", s)
+	}
+	buf := s.AppendRaw([]byte("x"), "named")
+	if string(buf) != "xnamed" {
+		t.Errorf("expected %q, got %q", "xnamed", buf)
+	}
+	got, err := s.DecodeRaw(buf[1:])
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if got != "named" {
+		t.Errorf("expected value %q, got %q", "named", got)
+	}
+
+	var b Default[[]byte]
+	if !b.Raw() {
+		t.Fatalf("expected %T to be raw", b)
+	}
+	buf = b.AppendRaw(nil, []byte("bytes"))
+	value, err := b.DecodeRaw(buf)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	buf[0] = 'x'
+	if !bytes.Equal(value, []byte("bytes")) {
+		t.Errorf("expected value %q not to refer to buffer, got %q", "bytes", value)
+	}
+
+	var i Default[int]
+	if i.Raw() {
+		t.Errorf("expected %T not to be raw", i)
+	}
+	if _, err := i.DecodeRaw(nil); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected error %v, got %v", ErrUnsupported, err)
+	}
+}
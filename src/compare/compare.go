@@ -0,0 +1,81 @@
+package compare
+
+import (
+	"bytes"
+	"cmp"
+	"reflect"
+)
+
+/*
+ * Comparators return a negative number, zero or a positive number if 'a' is less than, equal to or greater than 'b'.
+ *
+ * Default returns comparator for integers, floats, strings and byte slices, including named types built on them, or
+ * nil for other types. Built-in types get cmp.Compare or bytes.Compare, named types are compared through reflection,
+ * which is much slower.
+ */
+func Default[K any]() func(a, b K) int {
+	var f any
+
+	switch any(*new(K)).(type) {
+	case int:
+		f = cmp.Compare[int]
+	case int8:
+		f = cmp.Compare[int8]
+	case int16:
+		f = cmp.Compare[int16]
+	case int32:
+		f = cmp.Compare[int32]
+	case int64:
+		f = cmp.Compare[int64]
+	case uint:
+		f = cmp.Compare[uint]
+	case uint8:
+		f = cmp.Compare[uint8]
+	case uint16:
+		f = cmp.Compare[uint16]
+	case uint32:
+		f = cmp.Compare[uint32]
+	case uint64:
+		f = cmp.Compare[uint64]
+	case uintptr:
+		f = cmp.Compare[uintptr]
+	case float32:
+		f = cmp.Compare[float32]
+	case float64:
+		f = cmp.Compare[float64]
+	case string:
+		f = cmp.Compare[string]
+	case []byte:
+		f = bytes.Compare
+	}
+	if f != nil {
+		return f.(func(a, b K) int)
+	}
+
+	t := reflect.TypeFor[K]()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Int(), reflect.ValueOf(b).Int())
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Uint(), reflect.ValueOf(b).Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).Float(), reflect.ValueOf(b).Float())
+		}
+	case reflect.String:
+		return func(a, b K) int {
+			return cmp.Compare(reflect.ValueOf(a).String(), reflect.ValueOf(b).String())
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(a, b K) int {
+				return bytes.Compare(reflect.ValueOf(a).Bytes(), reflect.ValueOf(b).Bytes())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package compare
+
+import (
+	"testing"
+)
+
+func testDefault[K any](t *testing.T, values ...K) {
+	t.Helper()
+
+	f := Default[K]()
+	if f == nil {
+		t.Fatalf("no comparator for %T", *new(K))
+	}
+	for i := 0; i < len(values); i++ {
+		for j := 0; j < len(values); j++ {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if got := f(values[i], values[j]); got != expected {
+				t.Errorf("compare(%v, %v): expected %d, got %d", values[i], values[j], expected, got)
+			}
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	type Named int
+	type NamedString string
+	type NamedBytes []byte
+
+	testDefault(t, -1<<63, -1, 0, 1, 1<<62)
+	testDefault[uint8](t, 0, 1, 255)
+	testDefault[uint64](t, 0, 1, 1<<64-1)
+	testDefault(t, -1.5, 0.0, 3.14159)
+	testDefault[float32](t, -2.25, 0.5)
+	testDefault(t, "", "a", "ab", "b")
+	testDefault(t, []byte{}, []byte("a"), []byte("ab"), []byte("b"))
+	testDefault[Named](t, -42, 0, 42)
+	testDefault[NamedString](t, "a", "b")
+	testDefault[NamedBytes](t, NamedBytes("a"), NamedBytes("b"))
+
+	if Default[struct{ A, B int }]() != nil {
+		t.Errorf("expected no comparator for struct")
+	}
+}
@@ -0,0 +1,395 @@
+package exthash
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"codec"
+	"pager"
+)
+
+/*
+ * Page layouts, all integers are little-endian:
+ *	Bucket:    [depth:1][count:2][overflowPage:4][key, value...]
+ *	Directory: [nextPage:4][count:2][bucketPage:4...]
+ *
+ * Directory is a chain of pages starting at the root page of Store. Entries pointing to the same bucket hold the same
+ * page, so local depth of a bucket follows from the number of its entries.
+ */
+const (
+	bucketHeaderSize    = 7
+	directoryHeaderSize = 6
+)
+
+var (
+	ErrNoStore      = errors.New("table has no page store")
+	ErrPageOverflow = errors.New("page contents do not fit into page")
+	ErrCorrupted    = errors.New("page is corrupted")
+)
+
+/*
+ * stableHash is FNV-1a of 'buf' finished as in MurmurHash3. FNV alone mixes high bits of bytes poorly into the low bits
+ * of the hash, which select directory entries.
+ */
+func stableHash(buf []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range buf {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (t *Table[K, V]) initCodecs() {
+	if t.KeyCodec == nil {
+		t.KeyCodec = codec.Default[K]{}
+	}
+	if t.ValueCodec == nil {
+		t.ValueCodec = codec.Default[V]{}
+	}
+}
+
+/* Err returns the first error of reading buckets from Store. Modification that needed such bucket is not applied. */
+func (t *Table[K, V]) Err() error {
+	return t.err
+}
+
+func (t *Table[K, V]) setErr(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+/* view returns bucket at directory index 'd', reading it from Store if needed. Bucket that is read is not put into the directory. Returns nil if reading has failed, see Err. */
+func (t *Table[K, V]) view(d int) *Bucket[K, V] {
+	bucket := t.Directory[d]
+	if !bucket.ref {
+		return bucket
+	}
+
+	bucket, err := t.readBucket(bucket.ID, bucket.Depth)
+	if err != nil {
+		t.setErr(fmt.Errorf("failed to read bucket: %w", err))
+		return nil
+	}
+	return bucket
+}
+
+/* bucket is view which puts bucket into the directory, so that it may be modified. */
+func (t *Table[K, V]) bucket(d int) *Bucket[K, V] {
+	bucket := t.view(d)
+	if (bucket != nil) && (bucket != t.Directory[d]) {
+		*t.Directory[d] = *bucket
+		bucket = t.Directory[d]
+	}
+	return bucket
+}
+
+func (t *Table[K, V]) encodeBucket(buf []byte, page *Bucket[K, V]) []byte {
+	var overflow pager.PageID
+	if page.Overflow != nil {
+		overflow = page.Overflow.ID
+	}
+
+	buf = append(buf, byte(page.Depth))
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(page.Keys)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(overflow))
+	for i := 0; i < len(page.Keys); i++ {
+		buf = t.KeyCodec.Append(buf, page.Keys[i])
+		buf = t.ValueCodec.Append(buf, page.Values[i])
+	}
+	return buf
+}
+
+/* writePage pads 'buf' to the page size and writes it to page 'id'. */
+func (t *Table[K, V]) writePage(id pager.PageID, buf []byte) error {
+	if len(buf) > t.Store.PageSize() {
+		return fmt.Errorf("%w: %d bytes, page size is %d", ErrPageOverflow, len(buf), t.Store.PageSize())
+	}
+
+	n := len(buf)
+	buf = buf[:t.Store.PageSize()]
+	clear(buf[n:])
+	return t.Store.Write(id, buf)
+}
+
+/*
+ * flushBucket writes pages of 'bucket' that were modified since the last Flush. Pages get their IDs first, so that
+ * every page sees ID of the next one. Written bucket becomes a reference.
+ */
+func (t *Table[K, V]) flushBucket(bucket *Bucket[K, V]) error {
+	if bucket.ref {
+		return nil
+	}
+
+	for page := bucket; page != nil; page = page.Overflow {
+		if page.ID == pager.InvalidPage {
+			id, err := t.Store.Alloc()
+			if err != nil {
+				return err
+			}
+			page.ID = id
+			page.dirty = true
+		}
+	}
+	for page := bucket; page != nil; page = page.Overflow {
+		if !page.dirty {
+			continue
+		}
+		if err := t.writePage(page.ID, t.encodeBucket(t.pageBuf[:0], page)); err != nil {
+			return err
+		}
+		page.dirty = false
+	}
+
+	*bucket = Bucket[K, V]{Depth: bucket.Depth, ID: bucket.ID, ref: true}
+	return nil
+}
+
+/* flushDirectory writes the directory into as many pages as it needs, reusing pages it was written to before. */
+func (t *Table[K, V]) flushDirectory() error {
+	perPage := (t.Store.PageSize() - directoryHeaderSize) / 4
+	count := (len(t.Directory) + perPage - 1) / perPage
+
+	for len(t.dirPages) < count {
+		id, err := t.Store.Alloc()
+		if err != nil {
+			return err
+		}
+		t.dirPages = append(t.dirPages, id)
+	}
+	for len(t.dirPages) > count {
+		if err := t.Store.Free(t.dirPages[len(t.dirPages)-1]); err != nil {
+			return err
+		}
+		t.dirPages = t.dirPages[:len(t.dirPages)-1]
+	}
+
+	for i, id := range t.dirPages {
+		next := pager.InvalidPage
+		if i+1 < len(t.dirPages) {
+			next = t.dirPages[i+1]
+		}
+		entries := t.Directory[i*perPage : min((i+1)*perPage, len(t.Directory))]
+
+		buf := binary.LittleEndian.AppendUint32(t.pageBuf[:0], uint32(next))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(entries)))
+		for _, bucket := range entries {
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(bucket.ID))
+		}
+		if err := t.writePage(id, buf); err != nil {
+			return err
+		}
+	}
+	t.dirDirty = false
+
+	return nil
+}
+
+/* Flush writes all modified buckets and the directory, releases disposed pages and makes the result durable. Written buckets leave memory. */
+func (t *Table[K, V]) Flush() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+
+	for len(t.freed) > 0 {
+		if err := t.Store.Free(t.freed[len(t.freed)-1]); err != nil {
+			return fmt.Errorf("failed to free page: %w", err)
+		}
+		t.freed = t.freed[:len(t.freed)-1]
+	}
+
+	if cap(t.pageBuf) < t.Store.PageSize() {
+		t.pageBuf = make([]byte, 0, t.Store.PageSize())
+	}
+	for d, bucket := range t.Directory {
+		if d >= 1<<bucket.Depth {
+			continue
+		}
+		if err := t.flushBucket(bucket); err != nil {
+			return fmt.Errorf("failed to flush table: %w", err)
+		}
+	}
+	if (t.dirDirty) || (len(t.dirPages) == 0) {
+		if err := t.flushDirectory(); err != nil {
+			return fmt.Errorf("failed to flush table: %w", err)
+		}
+	}
+	t.Store.SetRoot(t.dirPages[0])
+
+	return t.Store.Sync()
+}
+
+/* readBucket reads bucket 'id' of local depth 'depth' together with its overflow pages from Store. */
+func (t *Table[K, V]) readBucket(id pager.PageID, depth int) (*Bucket[K, V], error) {
+	var bucket, last *Bucket[K, V]
+
+	for id != pager.InvalidPage {
+		var page *Bucket[K, V]
+		var next pager.PageID
+
+		err := t.viewPage(id, func(buf []byte) error {
+			var err error
+			page, next, err = t.decodeBucket(id, buf)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if page.Depth != depth {
+			return nil, fmt.Errorf("%w: page %d has depth %d, directory expects %d", ErrCorrupted, id, page.Depth, depth)
+		}
+
+		if last == nil {
+			bucket = page
+		} else {
+			last.Overflow = page
+		}
+		last = page
+		id = next
+	}
+
+	return bucket, nil
+}
+
+/* viewPage calls 'fn' with contents of page 'id', which are not copied if Store is pager.Viewer, like buffer pool. */
+func (t *Table[K, V]) viewPage(id pager.PageID, fn func([]byte) error) error {
+	if v, ok := t.Store.(pager.Viewer); ok {
+		return v.View(id, fn)
+	}
+
+	if cap(t.readBuf) < t.Store.PageSize() {
+		t.readBuf = make([]byte, t.Store.PageSize())
+	}
+	buf := t.readBuf[:t.Store.PageSize()]
+	if err := t.Store.Read(id, buf); err != nil {
+		return err
+	}
+	return fn(buf)
+}
+
+/* decodeBucket returns page 'id' of a bucket and ID of its next overflow page. */
+func (t *Table[K, V]) decodeBucket(id pager.PageID, buf []byte) (*Bucket[K, V], pager.PageID, error) {
+	depth := int(buf[0])
+	count := int(binary.LittleEndian.Uint16(buf[1:]))
+	next := pager.PageID(binary.LittleEndian.Uint32(buf[3:]))
+	if count > t.BucketSize {
+		return nil, 0, fmt.Errorf("%w: page %d has %d pairs, bucket size is %d", ErrCorrupted, id, count, t.BucketSize)
+	}
+
+	page := t.newBucket(depth)
+	page.ID = id
+
+	offset := bucketHeaderSize
+	for i := 0; i < count; i++ {
+		key, n, err := t.KeyCodec.Decode(buf[offset:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode key on page %d: %w", id, err)
+		}
+		offset += n
+
+		value, n, err := t.ValueCodec.Decode(buf[offset:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode value on page %d: %w", id, err)
+		}
+		offset += n
+
+		page.Keys = append(page.Keys, key)
+		page.Values = append(page.Values, value)
+	}
+
+	return page, next, nil
+}
+
+/* readDirectory returns bucket pages of all directory entries, reading the chain of pages that starts at 'id'. */
+func (t *Table[K, V]) readDirectory(id pager.PageID) ([]pager.PageID, error) {
+	var entries []pager.PageID
+
+	for id != pager.InvalidPage {
+		if len(entries) > 1<<t.MaxDepth {
+			return nil, fmt.Errorf("%w: directory has more than %d entries", ErrCorrupted, 1<<t.MaxDepth)
+		}
+		t.dirPages = append(t.dirPages, id)
+
+		err := t.viewPage(id, func(buf []byte) error {
+			count := int(binary.LittleEndian.Uint16(buf[4:]))
+			if directoryHeaderSize+4*count > len(buf) {
+				return fmt.Errorf("%w: page %d has %d entries", ErrCorrupted, id, count)
+			}
+			for i := 0; i < count; i++ {
+				entries = append(entries, pager.PageID(binary.LittleEndian.Uint32(buf[directoryHeaderSize+4*i:])))
+			}
+			id = pager.PageID(binary.LittleEndian.Uint32(buf))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+/* Load replaces contents of the table with the one stored in its page store. Only the directory is read, buckets are read when they are needed. */
+func (t *Table[K, V]) Load() error {
+	if t.Store == nil {
+		return ErrNoStore
+	}
+	t.init()
+
+	t.Directory = nil
+	t.Depth = 0
+	t.deep = 0
+	t.dirPages = t.dirPages[:0]
+	t.dirDirty = false
+	t.freed = t.freed[:0]
+	t.err = nil
+
+	entries, err := t.readDirectory(t.Store.Root())
+	if err != nil {
+		return fmt.Errorf("failed to load table: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if len(entries)&(len(entries)-1) != 0 {
+		return fmt.Errorf("failed to load table: %w: directory has %d entries", ErrCorrupted, len(entries))
+	}
+	depth := bits.TrailingZeros(uint(len(entries)))
+
+	refs := make(map[pager.PageID]int)
+	for _, id := range entries {
+		refs[id]++
+	}
+
+	buckets := make(map[pager.PageID]*Bucket[K, V], len(refs))
+	directory := make([]*Bucket[K, V], len(entries))
+	for d, id := range entries {
+		bucket, ok := buckets[id]
+		if !ok {
+			n := refs[id]
+			if (id == pager.InvalidPage) || (n&(n-1) != 0) {
+				return fmt.Errorf("failed to load table: %w: bucket page %d has %d entries", ErrCorrupted, id, n)
+			}
+			bucket = &Bucket[K, V]{Depth: depth - bits.TrailingZeros(uint(n)), ID: id, ref: true}
+			buckets[id] = bucket
+			if n == 1 {
+				t.deep++
+			}
+		}
+		directory[d] = bucket
+	}
+	t.Directory = directory
+	t.Depth = depth
+
+	return nil
+}
@@ -0,0 +1,213 @@
+package exthash
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"constants"
+	"generator"
+	"pager"
+)
+
+func testExthashFlush(t *testing.T, g generator.Generator, size int, depth int) {
+	t.Helper()
+
+	p, err := pager.Open(filepath.Join(t.TempDir(), "exthash.db"), 2*pager.DefaultPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+	ht.MaxDepth = depth
+	ht.Store = p
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		ht.Set(k, v)
+	}
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+
+	i := 0
+	for k := range m {
+		if i%2 == 0 {
+			ht.Del(k)
+			delete(m, k)
+		}
+		i++
+	}
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+
+	var loaded Table[int, int]
+	loaded.BucketSize = size
+	loaded.MaxDepth = depth
+	loaded.Store = p
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("failed to load table: %v", err)
+	}
+
+	for k, v := range m {
+		if got := loaded.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	if loaded.String() != ht.String() {
+		t.Errorf("expected loaded table to have the same shape")
+	}
+
+	/* Buckets read from the store are modified and written again. */
+	i = 0
+	for k := range m {
+		if i%2 == 0 {
+			loaded.Del(k)
+			delete(m, k)
+		} else {
+			loaded.Set(k, k)
+			m[k] = k
+		}
+		i++
+	}
+	if err := loaded.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+	if err := loaded.Err(); err != nil {
+		t.Fatalf("failed to read table: %v", err)
+	}
+
+	var reloaded Table[int, int]
+	reloaded.BucketSize = size
+	reloaded.MaxDepth = depth
+	reloaded.Store = p
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("failed to load table: %v", err)
+	}
+	n := 0
+	for k, v := range reloaded.All() {
+		if m[k] != v {
+			t.Errorf("expected value %v, got %v", m[k], v)
+		}
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys after reload, got %d", len(m), n)
+	}
+}
+
+func TestExthashFlush(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			t.Parallel()
+			for _, size := range BucketSizes {
+				/* Small MaxDepth makes buckets with overflow pages. */
+				for _, depth := range [...]int{8, DefaultMaxDepth} {
+					t.Run(fmt.Sprintf("BucketSize-%d/MaxDepth-%d", size, depth), func(t *testing.T) {
+						testExthashFlush(t, generator, size, depth)
+					})
+				}
+			}
+		})
+	}
+}
+
+func TestExthashFlushEvicts(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "exthash.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var ht Table[int, int]
+	ht.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		ht.Set(i, i)
+	}
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+
+	for _, bucket := range ht.Directory {
+		if !bucket.ref {
+			t.Fatalf("expected buckets to be written out")
+		}
+	}
+	for i := 0; i < constants.N; i++ {
+		if got := ht.Get(i); got != i {
+			t.Errorf("expected value %v, got %v", i, got)
+		}
+	}
+	for _, bucket := range ht.Directory {
+		if !bucket.ref {
+			t.Fatalf("expected Get not to keep buckets in memory")
+		}
+	}
+}
+
+func TestExthashFlushReusesPages(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "exthash.db"), 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var ht Table[int, int]
+	ht.Store = p
+
+	for i := 0; i < constants.N; i++ {
+		ht.Set(i, i)
+	}
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+	count := p.PageCount()
+
+	ht.Clear()
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+	for i := 0; i < constants.N; i++ {
+		ht.Set(i, i)
+	}
+	if err := ht.Flush(); err != nil {
+		t.Fatalf("failed to flush table: %v", err)
+	}
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages, got %d", count, p.PageCount())
+	}
+}
+
+func TestExthashFlushOverflow(t *testing.T) {
+	p, err := pager.Open(filepath.Join(t.TempDir(), "exthash.db"), pager.MinPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	defer p.Close()
+
+	var ht Table[int, string]
+	ht.Store = p
+
+	ht.Set(0, strings.Repeat("x", pager.MinPageSize))
+	if err := ht.Flush(); !errors.Is(err, ErrPageOverflow) {
+		t.Errorf("expected error %v, got %v", ErrPageOverflow, err)
+	}
+}
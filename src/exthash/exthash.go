@@ -0,0 +1,483 @@
+package exthash
+
+import (
+	"fmt"
+	"hash/maphash"
+	"iter"
+	"strings"
+
+	"codec"
+	"pager"
+)
+
+/* Bucket is a page of at most BucketSize pairs whose hashes agree in the lowest Depth bits. */
+type Bucket[K comparable, V any] struct {
+	Keys   []K
+	Values []V
+
+	/* Local depth. */
+	Depth int
+
+	/* Next page of the bucket. Only buckets of MaxDepth, which can not be split, have them, and all but the last are full. */
+	Overflow *Bucket[K, V]
+
+	ID    pager.PageID
+	dirty bool
+
+	/* Bucket is only a reference to its copy in Store, with Depth but without pairs and overflow pages, see Table.view. */
+	ref bool
+}
+
+/*
+ * Table is an extendible hash index. Directory has 2^Depth entries, entry 'i' points to the bucket holding keys whose
+ * hashes end with the bits of 'i'. Full bucket is split in two by its next hash bit, doubling the directory if bucket
+ * already uses all Depth bits. Bucket is merged back with its buddy once both fit into one half-full bucket, and the
+ * directory is halved when no bucket needs all of its bits. Directory stops growing at MaxDepth, full buckets of that
+ * depth get overflow pages instead.
+ */
+type Table[K comparable, V any] struct {
+	Directory []*Bucket[K, V]
+
+	/* Global depth. */
+	Depth int
+
+	/* Number of buckets of depth Depth, the directory is halved when there are none. */
+	deep int
+
+	BucketSize int
+
+	/* Limit of global depth, DefaultMaxDepth is used if it is not set. */
+	MaxDepth int
+
+	/*
+	 * Optional on-disk storage, see Flush and Load. Buckets are read from it when they are needed, so the table takes
+	 * memory of its directory and of buckets modified since the last Flush. With Store keys are hashed by their
+	 * KeyCodec encoding, which is the same after restart, so Store must be set before the table is used.
+	 */
+	Store      pager.Store
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+	dirPages   []pager.PageID
+	dirDirty   bool
+	freed      []pager.PageID
+	keyBuf     []byte
+	pageBuf    []byte
+	readBuf    []byte
+	err        error
+
+	seed maphash.Seed
+}
+
+const (
+	DefaultBucketSize = 64
+
+	/* Directory of DefaultMaxDepth takes 128 MiB. */
+	DefaultMaxDepth = 24
+)
+
+func (t *Table[K, V]) init() {
+	if t.BucketSize == 0 {
+		t.BucketSize = DefaultBucketSize
+	}
+	if t.MaxDepth == 0 {
+		t.MaxDepth = DefaultMaxDepth
+	}
+	if t.Store != nil {
+		t.initCodecs()
+	}
+	if t.Directory == nil {
+		t.seed = maphash.MakeSeed()
+		t.Directory = []*Bucket[K, V]{t.newBucket(0)}
+		t.Depth = 0
+		t.deep = 1
+		t.dirDirty = true
+	}
+}
+
+func (t *Table[K, V]) hash(key K) uint64 {
+	if t.Store != nil {
+		t.keyBuf = t.KeyCodec.Append(t.keyBuf[:0], key)
+		return stableHash(t.keyBuf)
+	}
+	return maphash.Comparable(t.seed, key)
+}
+
+func (t *Table[K, V]) index(key K) int {
+	return int(t.hash(key) & (1<<t.Depth - 1))
+}
+
+func (t *Table[K, V]) newBucket(depth int) *Bucket[K, V] {
+	return &Bucket[K, V]{Keys: make([]K, 0, t.BucketSize), Values: make([]V, 0, t.BucketSize), Depth: depth}
+}
+
+/* find returns page of the bucket holding the key and index of the key in it, or -1 if it is absent. Bucket may be nil. */
+func (b *Bucket[K, V]) find(key K) (*Bucket[K, V], int) {
+	for page := b; page != nil; page = page.Overflow {
+		for i := 0; i < len(page.Keys); i++ {
+			if page.Keys[i] == key {
+				return page, i
+			}
+		}
+	}
+	return nil, -1
+}
+
+/* get is lookup which does not keep bucket read from Store in memory. */
+func (t *Table[K, V]) get(key K) (*Bucket[K, V], int) {
+	return t.view(t.index(key)).find(key)
+}
+
+/*
+ * lookup returns directory index for the key, its bucket, page of the bucket holding the key and index of the key in
+ * that page or -1. Bucket is nil if it could not be read, see Err.
+ */
+func (t *Table[K, V]) lookup(key K) (int, *Bucket[K, V], *Bucket[K, V], int) {
+	d := t.index(key)
+	bucket := t.bucket(d)
+	page, i := bucket.find(key)
+	return d, bucket, page, i
+}
+
+/* add appends pair to the last page of 'bucket', adding overflow page if it is full. */
+func (t *Table[K, V]) add(bucket *Bucket[K, V], key K, value V) {
+	last := bucket
+	for last.Overflow != nil {
+		last = last.Overflow
+	}
+	if len(last.Keys) == t.BucketSize {
+		last.Overflow = t.newBucket(bucket.Depth)
+		last.dirty = true
+		last = last.Overflow
+	}
+	last.Keys = append(last.Keys, key)
+	last.Values = append(last.Values, value)
+	last.dirty = true
+}
+
+func (t *Table[K, V]) dispose(bucket *Bucket[K, V]) {
+	for page := bucket; page != nil; page = page.Overflow {
+		if page.ID != pager.InvalidPage {
+			t.freed = append(t.freed, page.ID)
+		}
+	}
+}
+
+/* split moves keys with the next hash bit set from bucket at directory index 'd', which has no overflow pages, to a new bucket. */
+func (t *Table[K, V]) split(d int) {
+	bucket := t.Directory[d]
+	if bucket.Depth == t.Depth {
+		t.Directory = append(t.Directory, t.Directory...)
+		t.Depth++
+		t.deep = 0
+	}
+
+	bit := uint64(1) << bucket.Depth
+	low := d & int(bit-1)
+	bucket.Depth++
+	bucket.dirty = true
+	sibling := t.newBucket(bucket.Depth)
+	if bucket.Depth == t.Depth {
+		t.deep += 2
+	}
+
+	n := 0
+	for i := 0; i < len(bucket.Keys); i++ {
+		if t.hash(bucket.Keys[i])&bit != 0 {
+			sibling.Keys = append(sibling.Keys, bucket.Keys[i])
+			sibling.Values = append(sibling.Values, bucket.Values[i])
+		} else {
+			bucket.Keys[n] = bucket.Keys[i]
+			bucket.Values[n] = bucket.Values[i]
+			n++
+		}
+	}
+	clear(bucket.Keys[n:])
+	clear(bucket.Values[n:])
+	bucket.Keys = bucket.Keys[:n]
+	bucket.Values = bucket.Values[:n]
+
+	for i := low | int(bit); i < len(t.Directory); i += 2 * int(bit) {
+		t.Directory[i] = sibling
+	}
+	t.dirDirty = true
+}
+
+/* merge joins bucket at directory index 'd' with its buddy while they both fit into half of a bucket, then shrinks the directory. */
+func (t *Table[K, V]) merge(d int) {
+	for {
+		bucket := t.Directory[d]
+		if bucket.Depth == 0 {
+			break
+		}
+
+		bit := 1 << (bucket.Depth - 1)
+		if t.Directory[d^bit].Depth != bucket.Depth {
+			break
+		}
+		buddy := t.bucket(d ^ bit)
+		if (buddy == nil) || (bucket.Overflow != nil) || (buddy.Overflow != nil) ||
+			(len(bucket.Keys)+len(buddy.Keys) > t.BucketSize/2) {
+			break
+		}
+
+		/* Keep the bucket whose bits end with zero. */
+		if d&bit != 0 {
+			bucket, buddy = buddy, bucket
+		}
+		bucket.Keys = append(bucket.Keys, buddy.Keys...)
+		bucket.Values = append(bucket.Values, buddy.Values...)
+		if bucket.Depth == t.Depth {
+			t.deep -= 2
+		}
+		bucket.Depth--
+		bucket.dirty = true
+		t.dispose(buddy)
+
+		low := d & (bit - 1)
+		for i := low; i < len(t.Directory); i += bit {
+			t.Directory[i] = bucket
+		}
+		t.dirDirty = true
+		d = low
+	}
+
+	for (t.Depth > 0) && (t.deep == 0) {
+		half := len(t.Directory) / 2
+		clear(t.Directory[half:])
+		t.Directory = t.Directory[:half]
+		t.Depth--
+		t.dirDirty = true
+
+		/* Bucket of depth Depth has a single entry. */
+		for _, bucket := range t.Directory {
+			if bucket.Depth == t.Depth {
+				t.deep++
+			}
+		}
+	}
+}
+
+/*
+ * All returns iterator over all key-value pairs in order of their hashes, which is not the order of keys. Read errors
+ * stop iteration and are reported by Err.
+ */
+func (t *Table[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for d, bucket := range t.Directory {
+			/* Bucket is visited from the first directory entry that points to it. */
+			if d >= 1<<bucket.Depth {
+				continue
+			}
+			bucket = t.view(d)
+			if bucket == nil {
+				return
+			}
+			for page := bucket; page != nil; page = page.Overflow {
+				for i := 0; i < len(page.Keys); i++ {
+					if !yield(page.Keys[i], page.Values[i]) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+func (t *Table[K, V]) Clear() {
+	if t.Store != nil {
+		for d, bucket := range t.Directory {
+			if d >= 1<<bucket.Depth {
+				continue
+			}
+			/* Overflow pages of bucket that can not be read are lost. */
+			if page := t.view(d); page != nil {
+				bucket = page
+			}
+			t.dispose(bucket)
+		}
+		t.freed = append(t.freed, t.dirPages...)
+		t.dirPages = nil
+	}
+	t.Directory = nil
+	t.Depth = 0
+	t.deep = 0
+}
+
+/* CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==. */
+func CompareAndSwap[K, V comparable](t *Table[K, V], key K, old, new V) bool {
+	return t.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+/* CompareAndSwapFunc sets value of 'key' to 'new' if it is present with value 'old' according to 'equal'. */
+func (t *Table[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
+	t.init()
+
+	_, _, page, i := t.lookup(key)
+	if (i == -1) || (!equal(page.Values[i], old)) {
+		return false
+	}
+	page.Values[i] = new
+	page.dirty = true
+	return true
+}
+
+func (t *Table[K, V]) Del(key K) {
+	t.init()
+
+	d, _, page, i := t.lookup(key)
+	if i != -1 {
+		t.delAt(d, page, i)
+	}
+}
+
+/* delAt removes pair 'i' from 'page' of bucket at directory index 'd'. The hole is filled from the last page of the bucket. */
+func (t *Table[K, V]) delAt(d int, page *Bucket[K, V], i int) {
+	var prev *Bucket[K, V]
+
+	last := t.Directory[d]
+	for last.Overflow != nil {
+		prev, last = last, last.Overflow
+	}
+
+	n := len(last.Keys) - 1
+	page.Keys[i] = last.Keys[n]
+	page.Values[i] = last.Values[n]
+	clear(last.Keys[n:])
+	clear(last.Values[n:])
+	last.Keys = last.Keys[:n]
+	last.Values = last.Values[:n]
+	page.dirty = true
+	last.dirty = true
+
+	if (n == 0) && (prev != nil) {
+		prev.Overflow = nil
+		prev.dirty = true
+		t.dispose(last)
+	}
+
+	t.merge(d)
+}
+
+func (t *Table[K, V]) Get(key K) V {
+	var v V
+
+	t.init()
+	if page, i := t.get(key); i != -1 {
+		v = page.Values[i]
+	}
+	return v
+}
+
+/* GetOrSet returns value of 'key' and true if it is present, otherwise it sets 'key' to 'value'. */
+func (t *Table[K, V]) GetOrSet(key K, value V) (V, bool) {
+	t.init()
+
+	if _, _, page, i := t.lookup(key); i != -1 {
+		return page.Values[i], true
+	}
+	t.Set(key, value)
+	return value, false
+}
+
+func (t *Table[K, V]) Has(key K) bool {
+	t.init()
+	_, i := t.get(key)
+	return i != -1
+}
+
+/* Lookup returns value of 'key' and whether it is present. */
+func (t *Table[K, V]) Lookup(key K) (V, bool) {
+	var v V
+
+	t.init()
+	if page, i := t.get(key); i != -1 {
+		return page.Values[i], true
+	}
+	return v, false
+}
+
+func (t *Table[K, V]) Set(key K, value V) {
+	t.init()
+
+	for {
+		d, bucket, page, i := t.lookup(key)
+		switch {
+		case bucket == nil:
+			return
+		case i != -1:
+			page.Values[i] = value
+			page.dirty = true
+			return
+		case (len(bucket.Keys) < t.BucketSize) || (bucket.Depth >= t.MaxDepth):
+			t.add(bucket, key, value)
+			return
+		}
+		t.split(d)
+	}
+}
+
+func (t *Table[K, V]) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "depth %d\n", t.Depth)
+	for d, bucket := range t.Directory {
+		fmt.Fprintf(&sb, "%0*b -> ", max(t.Depth, 1), d)
+		if d >= 1<<bucket.Depth {
+			fmt.Fprintf(&sb, "%0*b\n", max(bucket.Depth, 1), d&(1<<bucket.Depth-1))
+			continue
+		}
+		if bucket = t.view(d); bucket == nil {
+			sb.WriteString("?\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "(%d) %v", bucket.Depth, bucket.Keys)
+		for page := bucket.Overflow; page != nil; page = page.Overflow {
+			fmt.Fprintf(&sb, " + %v", page.Keys)
+		}
+		sb.WriteRune('\n')
+	}
+
+	return sb.String()
+}
+
+/* Swap sets 'key' to 'value' and returns the previous value and whether there was one. */
+func (t *Table[K, V]) Swap(key K, value V) (V, bool) {
+	var old V
+
+	t.init()
+	if _, _, page, i := t.lookup(key); i != -1 {
+		old, page.Values[i] = page.Values[i], value
+		page.dirty = true
+		return old, true
+	}
+	t.Set(key, value)
+	return old, false
+}
+
+/*
+ * Update calls 'fn' with value of 'key' and whether it is present, then sets 'key' to the returned value or, if 'fn'
+ * returns false, deletes it. 'fn' must not modify the table.
+ */
+func (t *Table[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	var old V
+
+	t.init()
+
+	d, bucket, page, i := t.lookup(key)
+	if bucket == nil {
+		return
+	}
+	if i != -1 {
+		old = page.Values[i]
+	}
+	value, keep := fn(old, i != -1)
+	switch {
+	case (keep) && (i != -1):
+		page.Values[i] = value
+		page.dirty = true
+	case keep:
+		t.Set(key, value)
+	case i != -1:
+		t.delAt(d, page, i)
+	}
+}
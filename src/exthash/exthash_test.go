@@ -0,0 +1,423 @@
+package exthash
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+var BucketSizes = [...]int{2, 3, 16, DefaultBucketSize, constants.MaxOrder}
+
+/*
+ * checkTable verifies that directory entries point to buckets matching their bits, that no page is over BucketSize and
+ * that only buckets of MaxDepth have overflow pages, all but the last of them full. Returns number of keys.
+ */
+func checkTable[K comparable, V any](t *testing.T, ht *Table[K, V]) int {
+	t.Helper()
+
+	if len(ht.Directory) != 1<<ht.Depth {
+		t.Fatalf("directory has %d entries, expected %d", len(ht.Directory), 1<<ht.Depth)
+	}
+
+	n, deep := 0, 0
+	for d, bucket := range ht.Directory {
+		if bucket.Depth > ht.Depth {
+			t.Errorf("bucket with depth %d in directory with depth %d", bucket.Depth, ht.Depth)
+		}
+		mask := 1<<bucket.Depth - 1
+		if bucket != ht.Directory[d&mask] {
+			t.Errorf("entry %b does not point to bucket of entry %b", d, d&mask)
+		}
+		if d > mask {
+			continue
+		}
+		if bucket.Depth == ht.Depth {
+			deep++
+		}
+
+		if (bucket.Overflow != nil) && (bucket.Depth < ht.MaxDepth) {
+			t.Errorf("bucket with depth %d has overflow pages, max depth is %d", bucket.Depth, ht.MaxDepth)
+		}
+		for page := bucket; page != nil; page = page.Overflow {
+			if len(page.Keys) > ht.BucketSize {
+				t.Errorf("bucket with %d keys, expected at most %d", len(page.Keys), ht.BucketSize)
+			}
+			if (page.Overflow != nil) && (len(page.Keys) < ht.BucketSize) {
+				t.Errorf("page with %d keys is followed by overflow page", len(page.Keys))
+			}
+			for _, key := range page.Keys {
+				if int(ht.hash(key))&mask != d {
+					t.Errorf("key %v in bucket %b", key, d)
+				}
+			}
+			n += len(page.Keys)
+		}
+	}
+	if deep != ht.deep {
+		t.Errorf("table counts %d buckets of depth %d, expected %d", ht.deep, ht.Depth, deep)
+	}
+	return n
+}
+
+func testExthashGet(t *testing.T, g generator.Generator, size int) {
+	t.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		ht.Set(k, v)
+	}
+
+	if n := checkTable(t, &ht); n != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), n)
+	}
+	for k, v := range m {
+		if got := ht.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func testExthashDel(t *testing.T, g generator.Generator, size int) {
+	t.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		ht.Set(k, 0)
+	}
+
+	for k := range m {
+		ht.Del(k)
+		if ht.Has(k) {
+			t.Errorf("expected key %v to be removed, but it's still present", k)
+		}
+	}
+
+	/* Once empty, all buckets must be merged back. */
+	checkTable(t, &ht)
+	if ht.Depth != 0 {
+		t.Errorf("expected empty table to have depth 0, got %d", ht.Depth)
+	}
+}
+
+func testExthashHas(t *testing.T, g generator.Generator, size int) {
+	t.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		ht.Set(k, 0)
+	}
+
+	for k := range m {
+		if !ht.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+	}
+}
+
+func testExthashSet(t *testing.T, g generator.Generator, size int) {
+	t.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		ht.Set(k, v)
+		if !ht.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+		if got := ht.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func testExthashAll(t *testing.T, g generator.Generator, size int) {
+	t.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = i
+		ht.Set(k, i)
+		if i%3 == 2 {
+			delete(m, k)
+			ht.Del(k)
+		}
+	}
+	checkTable(t, &ht)
+
+	seen := make(map[int]struct{})
+	for k, v := range ht.All() {
+		if _, ok := seen[k]; ok {
+			t.Errorf("key %v visited twice", k)
+		}
+		seen[k] = struct{}{}
+		if got, ok := m[k]; (!ok) || (got != v) {
+			t.Errorf("unexpected key %v with value %v", k, v)
+		}
+	}
+	if len(seen) != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), len(seen))
+	}
+}
+
+func TestExthash(t *testing.T) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.T, generator.Generator, int)
+	}{
+		{"Get", testExthashGet},
+		{"Del", testExthashDel},
+		{"Has", testExthashHas},
+		{"Set", testExthashSet},
+		{"All", testExthashAll},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		t.Run(op.Name, func(t *testing.T) {
+			for _, generator := range generators {
+				t.Run(generator.String(), func(t *testing.T) {
+					for _, size := range BucketSizes {
+						t.Run(fmt.Sprintf("BucketSize-%d", size), func(t *testing.T) {
+							generator.Reset()
+							op.Func(t, generator, size)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+/* TestExthashPointOps runs random point operations against a map. */
+func TestExthashPointOps(t *testing.T) {
+	for _, size := range [...]int{2, 3, DefaultBucketSize} {
+		t.Run(fmt.Sprintf("BucketSize-%d", size), func(t *testing.T) {
+			var ht Table[int, int]
+			ht.BucketSize = size
+
+			rng := rand.New(rand.NewSource(constants.Seed))
+			m := make(map[int]int)
+			for i := 0; i < constants.N; i++ {
+				k := rng.Intn(constants.N / 8)
+				v, ok := m[k]
+
+				switch rng.Intn(7) {
+				case 0:
+					if got, found := ht.Lookup(k); (got != v) || (found != ok) {
+						t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+					}
+				case 1:
+					if !ok {
+						m[k] = i
+					}
+					if got, loaded := ht.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+						t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+					}
+				case 2:
+					m[k] = i
+					if got, loaded := ht.Swap(k, i); (got != v) || (loaded != ok) {
+						t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+					}
+				case 3:
+					old := v + rng.Intn(2)
+					want := ok && (old == v)
+					if want {
+						m[k] = i
+					}
+					if got := CompareAndSwap(&ht, k, old, i); got != want {
+						t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 4:
+					/* Values of the same parity are equal. */
+					old := rng.Intn(2)
+					want := ok && ((old-v)%2 == 0)
+					if want {
+						m[k] = i
+					}
+					if got := ht.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+						t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+					}
+				case 5:
+					/* Every third update deletes the key. */
+					keep := i%3 != 0
+					if keep {
+						m[k] = i
+					} else {
+						delete(m, k)
+					}
+					ht.Update(k, func(got int, found bool) (int, bool) {
+						if (got != v) || (found != ok) {
+							t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+						}
+						return i, keep
+					})
+				case 6:
+					delete(m, k)
+					ht.Del(k)
+				}
+			}
+			checkTable(t, &ht)
+
+			if got := maps.Collect(ht.All()); !maps.Equal(got, m) {
+				t.Errorf("All: expected %d pairs, got %d", len(m), len(got))
+			}
+		})
+	}
+}
+
+/* TestExthashMaxDepth checks that directory stops growing at MaxDepth and buckets get overflow pages instead. */
+func TestExthashMaxDepth(t *testing.T) {
+	var ht Table[int, int]
+	ht.BucketSize = 4
+	ht.MaxDepth = 3
+
+	for i := 0; i < constants.N; i++ {
+		ht.Set(i, i)
+	}
+	if ht.Depth != ht.MaxDepth {
+		t.Errorf("expected depth %d, got %d", ht.MaxDepth, ht.Depth)
+	}
+	if n := checkTable(t, &ht); n != constants.N {
+		t.Errorf("expected %d keys, got %d", constants.N, n)
+	}
+
+	for i := 0; i < constants.N; i++ {
+		if got, ok := ht.Lookup(i); (!ok) || (got != i) {
+			t.Errorf("expected value %v, got %v, %v", i, got, ok)
+		}
+	}
+	for i := 0; i < constants.N; i += 2 {
+		ht.Del(i)
+	}
+	if n := checkTable(t, &ht); n != constants.N/2 {
+		t.Errorf("expected %d keys, got %d", constants.N/2, n)
+	}
+	for i := 0; i < constants.N; i++ {
+		if ht.Has(i) != (i%2 == 1) {
+			t.Errorf("expected presence of key %v to be %v", i, i%2 == 1)
+		}
+	}
+
+	for i := 1; i < constants.N; i += 2 {
+		ht.Del(i)
+	}
+	if ht.Depth != 0 {
+		t.Errorf("expected empty table to have depth 0, got %d", ht.Depth)
+	}
+}
+
+func benchmarkExthashGet(b *testing.B, g generator.Generator, size int) {
+	b.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	for i := 0; i < b.N; i++ {
+		ht.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = ht.Get(g.Generate())
+	}
+}
+
+func benchmarkExthashDel(b *testing.B, g generator.Generator, size int) {
+	b.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	for i := 0; i < b.N; i++ {
+		ht.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ht.Del(g.Generate())
+	}
+}
+
+func benchmarkExthashSet(b *testing.B, g generator.Generator, size int) {
+	b.Helper()
+
+	var ht Table[int, int]
+	ht.BucketSize = size
+
+	for i := 0; i < b.N; i++ {
+		ht.Set(g.Generate(), 0)
+	}
+}
+
+func BenchmarkExthash(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, generator.Generator, int)
+	}{
+		{"Get", benchmarkExthashGet},
+		{"Del", benchmarkExthashDel},
+		{"Set", benchmarkExthashSet},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, generator := range generators {
+				b.Run(generator.String(), func(b *testing.B) {
+					for size := constants.MinOrder; size <= constants.MaxOrder; size += constants.OrderStep {
+						b.Run(fmt.Sprintf("BucketSize-%d", size), func(b *testing.B) {
+							generator.Reset()
+							op.Func(b, generator, size)
+						})
+					}
+				})
+			}
+		})
+	}
+}
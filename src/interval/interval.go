@@ -0,0 +1,179 @@
+package interval
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"rbtree"
+)
+
+/* Interval is a closed range [Lo, Hi] with a value attached. */
+type Interval[K cmp.Ordered, V any] struct {
+	Lo, Hi K
+	Value  V
+}
+
+/* bucket holds intervals starting at the same point, ordered by Hi. */
+type bucket[K cmp.Ordered, V any] struct {
+	Intervals []Interval[K, V]
+
+	/* Maximal Hi in the subtree of the node owning the bucket. */
+	max K
+}
+
+/*
+ * Tree is an interval tree. It is a red-black tree keyed by Lo, where every node also keeps maximal Hi of its
+ * subtree, maintained by rbtree.Tree through its Augment hook. Subtrees whose maximum is below the query are skipped,
+ * so Overlapping takes O(log n + m) for m reported intervals.
+ */
+type Tree[K cmp.Ordered, V any] struct {
+	tree rbtree.Tree[K, *bucket[K, V]]
+	len  int
+}
+
+func augment[K cmp.Ordered, V any](node *rbtree.Node[K, *bucket[K, V]]) {
+	b := node.Value
+	b.max = b.Intervals[len(b.Intervals)-1].Hi
+	if node.Left != nil {
+		b.max = max(b.max, node.Left.Value.max)
+	}
+	if node.Right != nil {
+		b.max = max(b.max, node.Right.Value.max)
+	}
+}
+
+func (t *Tree[K, V]) init() {
+	if t.tree.Augment == nil {
+		t.tree = *rbtree.New[K, *bucket[K, V]]()
+		t.tree.Augment = augment[K, V]
+	}
+}
+
+/* find returns index of the first interval ending at or after 'hi' and whether it ends exactly at 'hi'. */
+func (b *bucket[K, V]) find(hi K) (int, bool) {
+	return slices.BinarySearchFunc(b.Intervals, hi, func(iv Interval[K, V], hi K) int {
+		return cmp.Compare(iv.Hi, hi)
+	})
+}
+
+func overlapping[K cmp.Ordered, V any](node *rbtree.Node[K, *bucket[K, V]], lo, hi K, yield func(Interval[K, V]) bool) bool {
+	if (node == nil) || (node.Value.max < lo) {
+		return true
+	}
+	if !overlapping(node.Left, lo, hi, yield) {
+		return false
+	}
+	if node.Key > hi {
+		return true
+	}
+
+	b := node.Value
+	i, _ := b.find(lo)
+	for ; i < len(b.Intervals); i++ {
+		if !yield(b.Intervals[i]) {
+			return false
+		}
+	}
+	return overlapping(node.Right, lo, hi, yield)
+}
+
+/* All returns iterator over all intervals ordered by Lo, then by Hi. */
+func (t *Tree[K, V]) All() iter.Seq[Interval[K, V]] {
+	return func(yield func(Interval[K, V]) bool) {
+		for _, b := range t.tree.All() {
+			for _, iv := range b.Intervals {
+				if !yield(iv) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *Tree[K, V]) Clear() {
+	t.tree.Clear()
+	t.len = 0
+}
+
+/* Delete removes interval [lo, hi], with ends swapped as in Insert. Returns false if there is no such interval. */
+func (t *Tree[K, V]) Delete(lo, hi K) bool {
+	t.init()
+
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+
+	b := t.tree.Get(lo)
+	if b == nil {
+		return false
+	}
+	i, ok := b.find(hi)
+	if !ok {
+		return false
+	}
+
+	b.Intervals = slices.Delete(b.Intervals, i, i+1)
+	if len(b.Intervals) == 0 {
+		t.tree.Del(lo)
+	} else {
+		t.tree.Set(lo, b)
+	}
+	t.len--
+	return true
+}
+
+/* Insert adds interval [lo, hi] or replaces value of the existing one. Ends are swapped if 'lo' is greater than 'hi'. */
+func (t *Tree[K, V]) Insert(lo, hi K, value V) {
+	t.init()
+
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	iv := Interval[K, V]{Lo: lo, Hi: hi, Value: value}
+
+	b := t.tree.Get(lo)
+	if b == nil {
+		t.tree.Set(lo, &bucket[K, V]{Intervals: []Interval[K, V]{iv}})
+		t.len++
+		return
+	}
+
+	i, ok := b.find(hi)
+	if ok {
+		b.Intervals[i] = iv
+		return
+	}
+	b.Intervals = slices.Insert(b.Intervals, i, iv)
+	t.tree.Set(lo, b)
+	t.len++
+}
+
+func (t *Tree[K, V]) Len() int {
+	return t.len
+}
+
+/* Overlapping returns iterator over intervals sharing at least one point with [lo, hi], in the order of All. */
+func (t *Tree[K, V]) Overlapping(lo, hi K) iter.Seq[Interval[K, V]] {
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return func(yield func(Interval[K, V]) bool) {
+		overlapping(t.tree.Root, lo, hi, yield)
+	}
+}
+
+/* Stabbing returns iterator over intervals containing 'point', in the order of All. */
+func (t *Tree[K, V]) Stabbing(point K) iter.Seq[Interval[K, V]] {
+	return t.Overlapping(point, point)
+}
+
+func (t *Tree[K, V]) String() string {
+	var sb strings.Builder
+	for iv := range t.All() {
+		fmt.Fprintf(&sb, "[%v, %v] ", iv.Lo, iv.Hi)
+	}
+	return sb.String()
+}
@@ -0,0 +1,130 @@
+package interval
+
+import (
+	"cmp"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+	"rbtree"
+)
+
+const (
+	Queries = 64
+	Width   = 100
+)
+
+/* checkMax verifies that every node keeps maximal Hi of its subtree. Returns the maximum. */
+func checkMax[K cmp.Ordered, V any](t *testing.T, node *rbtree.Node[K, *bucket[K, V]]) K {
+	t.Helper()
+
+	b := node.Value
+	m := b.Intervals[len(b.Intervals)-1].Hi
+	if node.Left != nil {
+		m = max(m, checkMax(t, node.Left))
+	}
+	if node.Right != nil {
+		m = max(m, checkMax(t, node.Right))
+	}
+	if b.max != m {
+		t.Errorf("node %v has maximum %v, expected %v", node.Key, b.max, m)
+	}
+	return m
+}
+
+/* expected returns intervals from 'ivs' overlapping [lo, hi], ordered by Lo, then by Hi. */
+func expected(ivs map[[2]int]int, lo, hi int) []Interval[int, int] {
+	var res []Interval[int, int]
+	for k, v := range ivs {
+		if (k[0] <= hi) && (k[1] >= lo) {
+			res = append(res, Interval[int, int]{Lo: k[0], Hi: k[1], Value: v})
+		}
+	}
+	slices.SortFunc(res, func(a, b Interval[int, int]) int {
+		return cmp.Or(cmp.Compare(a.Lo, b.Lo), cmp.Compare(a.Hi, b.Hi))
+	})
+	return res
+}
+
+func testInterval(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var it Tree[int, int]
+	rng := rand.New(rand.NewSource(constants.Seed))
+
+	ivs := make(map[[2]int]int)
+	for i := 0; i < constants.N; i++ {
+		/* Sharing Lo between intervals exercises buckets. */
+		lo := g.Generate() % constants.N
+		hi := lo + rng.Intn(Width)
+		if i%2 == 0 {
+			/* Reversed ends must be swapped. */
+			it.Insert(hi, lo, i)
+		} else {
+			it.Insert(lo, hi, i)
+		}
+		ivs[[2]int{lo, hi}] = i
+	}
+	checkMax(t, it.tree.Root)
+
+	for k := range ivs {
+		if k[0]%3 == 0 {
+			if !it.Delete(k[0], k[1]) {
+				t.Errorf("failed to delete [%v, %v]", k[0], k[1])
+			}
+			delete(ivs, k)
+		}
+	}
+	if it.Delete(-constants.N, -constants.N) {
+		t.Errorf("deleted missing interval")
+	}
+	checkMax(t, it.tree.Root)
+
+	if it.Len() != len(ivs) {
+		t.Errorf("expected length %d, got %d", len(ivs), it.Len())
+	}
+	all := expected(ivs, math.MinInt, math.MaxInt)
+	if got := slices.Collect(it.All()); !slices.Equal(got, all) {
+		t.Errorf("All: expected %d intervals, got %d", len(all), len(got))
+	}
+
+	for j := 0; j < Queries; j++ {
+		lo := rng.Intn(2*(constants.N+Width)) - constants.N - Width
+		hi := lo + rng.Intn(2*Width)
+
+		exp := expected(ivs, lo, hi)
+		if got := slices.Collect(it.Overlapping(lo, hi)); !slices.Equal(got, exp) {
+			t.Errorf("Overlapping(%v, %v): expected %v, got %v", lo, hi, exp, got)
+		}
+		exp = expected(ivs, lo, lo)
+		if got := slices.Collect(it.Stabbing(lo)); !slices.Equal(got, exp) {
+			t.Errorf("Stabbing(%v): expected %v, got %v", lo, exp, got)
+		}
+	}
+
+	for k := range ivs {
+		it.Delete(k[0], k[1])
+	}
+	if (it.Len() != 0) || (it.tree.Root != nil) {
+		t.Errorf("expected empty tree, got length %d", it.Len())
+	}
+}
+
+func TestInterval(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testInterval(t, generator)
+		})
+	}
+}
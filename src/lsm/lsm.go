@@ -0,0 +1,571 @@
+package lsm
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"codec"
+	"rbtree"
+)
+
+/*
+ * Tree is a log-structured merge tree. Modifications go to in-memory rbtree.Tree ("memtable"), which is written out as
+ * immutable sorted run once it holds MemtableSize entries. Runs are organized in levels: level 0 holds up to L0Runs
+ * runs with overlapping keys, every deeper level holds a single run LevelRatio times larger than the previous one.
+ * When level overflows, it is merged into the next one. Del writes tombstones, which are dropped when merged into the
+ * deepest level.
+ *
+ * Contents of memtable are lost unless Flush or Close is called, flushed runs survive and are restored by Load.
+ */
+type Tree[K cmp.Ordered, V any] struct {
+	/* Directory for runs and manifest. If empty, temporary directory is created, which Close removes. */
+	Dir string
+
+	MemtableSize int
+	LevelRatio   int
+
+	KeyCodec   codec.Codec[K]
+	ValueCodec codec.Codec[V]
+
+	memtable rbtree.Tree[K, entry[V]]
+	memCount int
+
+	/* levels[0] holds runs from the oldest to the newest, other levels hold at most one run each. */
+	levels [][]*run[K]
+	next   int
+	temp   bool
+
+	buf     []byte
+	payload []byte
+	err     error
+}
+
+const (
+	DefaultMemtableSize = 4096
+	DefaultLevelRatio   = 10
+
+	/* Number of runs on level 0 that triggers its compaction. */
+	L0Runs = 4
+
+	manifestName = "MANIFEST"
+)
+
+var (
+	ErrBadManifest = errors.New("bad manifest")
+	ErrCorrupted   = errors.New("run is corrupted")
+)
+
+/* merge combines sorted sequences into one. When several sequences have the same key, the earliest one wins. */
+func merge[K cmp.Ordered, V any](seqs []iter.Seq2[K, entry[V]]) iter.Seq2[K, entry[V]] {
+	return func(yield func(K, entry[V]) bool) {
+		type head struct {
+			Next func() (K, entry[V], bool)
+			Key  K
+			E    entry[V]
+			OK   bool
+		}
+
+		heads := make([]head, len(seqs))
+		for i, seq := range seqs {
+			next, stop := iter.Pull2(seq)
+			defer stop()
+
+			heads[i].Next = next
+			heads[i].Key, heads[i].E, heads[i].OK = next()
+		}
+
+		for {
+			min := -1
+			for i := 0; i < len(heads); i++ {
+				if (heads[i].OK) && ((min == -1) || (heads[i].Key < heads[min].Key)) {
+					min = i
+				}
+			}
+			if min == -1 {
+				return
+			}
+
+			key, e := heads[min].Key, heads[min].E
+			for i := 0; i < len(heads); i++ {
+				if (heads[i].OK) && (heads[i].Key == key) {
+					heads[i].Key, heads[i].E, heads[i].OK = heads[i].Next()
+				}
+			}
+			if !yield(key, e) {
+				return
+			}
+		}
+	}
+}
+
+func (t *Tree[K, V]) init() {
+	if t.MemtableSize == 0 {
+		t.MemtableSize = DefaultMemtableSize
+	}
+	if t.LevelRatio == 0 {
+		t.LevelRatio = DefaultLevelRatio
+	}
+	if t.KeyCodec == nil {
+		t.KeyCodec = codec.Default[K]{}
+	}
+	if t.ValueCodec == nil {
+		t.ValueCodec = codec.Default[V]{}
+	}
+	if t.memtable.Compare == nil {
+		t.memtable = *rbtree.New[K, entry[V]]()
+	}
+	if len(t.levels) == 0 {
+		t.levels = make([][]*run[K], 1)
+	}
+}
+
+func (t *Tree[K, V]) initDir() error {
+	if t.Dir != "" {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "lsm")
+	if err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	t.Dir = dir
+	t.temp = true
+	return nil
+}
+
+/* capacity returns number of entries level 'l' may hold before it is merged into the next one. */
+func (t *Tree[K, V]) capacity(l int) int {
+	n := t.MemtableSize * L0Runs
+	for i := 0; i < l; i++ {
+		n *= t.LevelRatio
+	}
+	return n
+}
+
+/* compactLevel merges all runs of level 'l' into level 'l+1' and returns runs that are not used any more. */
+func (t *Tree[K, V]) compactLevel(l int) ([]*run[K], error) {
+	if l+1 == len(t.levels) {
+		t.levels = append(t.levels, nil)
+	}
+
+	/* Newer runs go first, so they win in merge. */
+	var inputs []*run[K]
+	for i := len(t.levels[l]) - 1; i >= 0; i-- {
+		inputs = append(inputs, t.levels[l][i])
+	}
+	inputs = append(inputs, t.levels[l+1]...)
+
+	var err error
+	fail := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	seqs := make([]iter.Seq2[K, entry[V]], len(inputs))
+	for i, r := range inputs {
+		seqs[i] = t.scanRun(r, fail)
+	}
+
+	deepest := true
+	for i := l + 2; i < len(t.levels); i++ {
+		deepest = deepest && (len(t.levels[i]) == 0)
+	}
+
+	r, werr := t.writeRun(merge(seqs), deepest)
+	if werr != nil {
+		return nil, werr
+	}
+	if err != nil {
+		if r != nil {
+			r.remove(t.Dir)
+		}
+		return nil, err
+	}
+
+	t.levels[l] = nil
+	t.levels[l+1] = nil
+	if r != nil {
+		t.levels[l+1] = append(t.levels[l+1], r)
+	}
+	return inputs, nil
+}
+
+func (t *Tree[K, V]) compact() ([]*run[K], error) {
+	var obsolete []*run[K]
+
+	if len(t.levels[0]) > L0Runs {
+		runs, err := t.compactLevel(0)
+		if err != nil {
+			return obsolete, err
+		}
+		obsolete = append(obsolete, runs...)
+	}
+	for l := 1; l < len(t.levels); l++ {
+		if (len(t.levels[l]) > 0) && (len(t.levels[l][0].Keys) > t.capacity(l)) {
+			runs, err := t.compactLevel(l)
+			if err != nil {
+				return obsolete, err
+			}
+			obsolete = append(obsolete, runs...)
+		}
+	}
+
+	return obsolete, nil
+}
+
+/* lookup returns the newest entry for the key. */
+func (t *Tree[K, V]) lookup(key K) (entry[V], bool) {
+	if k, e, ok := t.memtable.Ceiling(key); (ok) && (k == key) {
+		return e, true
+	}
+
+	for l := 0; l < len(t.levels); l++ {
+		for i := len(t.levels[l]) - 1; i >= 0; i-- {
+			r := t.levels[l][i]
+			if j, ok := r.find(key); ok {
+				e, err := t.readEntry(r, j)
+				if err != nil {
+					t.setErr(err)
+					return entry[V]{}, false
+				}
+				return e, true
+			}
+		}
+	}
+
+	return entry[V]{}, false
+}
+
+func (t *Tree[K, V]) put(key K, e entry[V]) {
+	t.init()
+
+	if !t.memtable.Has(key) {
+		t.memCount++
+	}
+	t.memtable.Set(key, e)
+
+	if t.memCount >= t.MemtableSize {
+		if err := t.Flush(); err != nil {
+			t.setErr(err)
+		}
+	}
+}
+
+func (t *Tree[K, V]) setErr(err error) {
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+/* writeManifest atomically replaces list of live runs. */
+func (t *Tree[K, V]) writeManifest() error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "next %d\n", t.next)
+	for l := 0; l < len(t.levels); l++ {
+		for _, r := range t.levels[l] {
+			fmt.Fprintf(&sb, "%d %s\n", l, r.Name)
+		}
+	}
+
+	tmp := filepath.Join(t.Dir, manifestName+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	if _, err := f.WriteString(sb.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync manifest: %w", err)
+	}
+	f.Close()
+
+	if err := os.Rename(tmp, filepath.Join(t.Dir, manifestName)); err != nil {
+		return fmt.Errorf("failed to replace manifest: %w", err)
+	}
+	return nil
+}
+
+/* All returns iterator over all key-value pairs in ascending order. Read errors stop iteration and are reported by Err. */
+func (t *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		t.init()
+
+		seqs := []iter.Seq2[K, entry[V]]{t.memtable.All()}
+		for l := 0; l < len(t.levels); l++ {
+			for i := len(t.levels[l]) - 1; i >= 0; i-- {
+				seqs = append(seqs, t.scanRun(t.levels[l][i], t.setErr))
+			}
+		}
+
+		for key, e := range merge(seqs) {
+			if e.Deleted {
+				continue
+			}
+			if !yield(key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+/* Clear removes all keys and run files. */
+func (t *Tree[K, V]) Clear() {
+	t.init()
+
+	t.memtable.Clear()
+	t.memCount = 0
+
+	for l := 0; l < len(t.levels); l++ {
+		for _, r := range t.levels[l] {
+			if err := r.remove(t.Dir); err != nil {
+				t.setErr(err)
+			}
+		}
+	}
+	t.levels = t.levels[:1]
+	t.levels[0] = nil
+
+	if t.Dir != "" {
+		if err := t.writeManifest(); err != nil {
+			t.setErr(err)
+		}
+	}
+}
+
+/* Close flushes memtable and closes run files. */
+func (t *Tree[K, V]) Close() error {
+	err := t.Flush()
+
+	for l := 0; l < len(t.levels); l++ {
+		for _, r := range t.levels[l] {
+			r.File.Close()
+		}
+	}
+	t.levels = nil
+
+	if t.temp {
+		if rerr := os.RemoveAll(t.Dir); (rerr != nil) && (err == nil) {
+			err = fmt.Errorf("failed to remove directory: %w", rerr)
+		}
+		t.Dir = ""
+		t.temp = false
+	}
+
+	return err
+}
+
+/* CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==. */
+func CompareAndSwap[K cmp.Ordered, V comparable](t *Tree[K, V], key K, old, new V) bool {
+	return t.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+/* CompareAndSwapFunc sets value of 'key' to 'new' if it is present with value 'old' according to 'equal'. */
+func (t *Tree[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
+	t.init()
+
+	e, ok := t.lookup(key)
+	if (!ok) || (e.Deleted) || (!equal(e.Value, old)) {
+		return false
+	}
+	t.put(key, entry[V]{Value: new})
+	return true
+}
+
+func (t *Tree[K, V]) Del(key K) {
+	t.init()
+
+	/* Without runs there is nothing to hide. */
+	if (len(t.levels) == 1) && (len(t.levels[0]) == 0) {
+		if t.memtable.Has(key) {
+			t.memtable.Del(key)
+			t.memCount--
+		}
+		return
+	}
+	t.put(key, entry[V]{Deleted: true})
+}
+
+/* Err returns the first error that happened in one of the methods without error result. */
+func (t *Tree[K, V]) Err() error {
+	return t.err
+}
+
+/* Flush writes memtable as a new run on level 0 and compacts levels that overflowed. */
+func (t *Tree[K, V]) Flush() error {
+	t.init()
+	if t.memCount == 0 {
+		return nil
+	}
+	if err := t.initDir(); err != nil {
+		return err
+	}
+
+	empty := true
+	for l := 0; l < len(t.levels); l++ {
+		empty = empty && (len(t.levels[l]) == 0)
+	}
+	r, err := t.writeRun(t.memtable.All(), empty)
+	if err != nil {
+		return err
+	}
+	if r != nil {
+		t.levels[0] = append(t.levels[0], r)
+	}
+	t.memtable.Clear()
+	t.memCount = 0
+
+	obsolete, err := t.compact()
+	if merr := t.writeManifest(); merr != nil {
+		return merr
+	}
+	if err != nil {
+		return err
+	}
+
+	/* Runs are removed only after manifest stopped referring to them. */
+	for _, r := range obsolete {
+		if err := r.remove(t.Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tree[K, V]) Get(key K) V {
+	var v V
+
+	t.init()
+	if e, ok := t.lookup(key); (ok) && (!e.Deleted) {
+		v = e.Value
+	}
+	return v
+}
+
+/* GetOrSet returns value of 'key' and true if it is present, otherwise it sets 'key' to 'value'. */
+func (t *Tree[K, V]) GetOrSet(key K, value V) (V, bool) {
+	if v, ok := t.Lookup(key); ok {
+		return v, true
+	}
+	t.put(key, entry[V]{Value: value})
+	return value, false
+}
+
+func (t *Tree[K, V]) Has(key K) bool {
+	t.init()
+	e, ok := t.lookup(key)
+	return (ok) && (!e.Deleted)
+}
+
+/* Load replaces contents of the tree with runs listed in manifest in Dir. Missing manifest means empty tree. */
+func (t *Tree[K, V]) Load() error {
+	t.init()
+
+	for l := 0; l < len(t.levels); l++ {
+		for _, r := range t.levels[l] {
+			r.File.Close()
+		}
+	}
+	t.levels = t.levels[:1]
+	t.levels[0] = nil
+	t.memtable.Clear()
+	t.memCount = 0
+
+	f, err := os.Open(filepath.Join(t.Dir, manifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	if !s.Scan() {
+		return ErrBadManifest
+	}
+	if _, err := fmt.Sscanf(s.Text(), "next %d", &t.next); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadManifest, err)
+	}
+	for s.Scan() {
+		var l int
+		var name string
+		if _, err := fmt.Sscanf(s.Text(), "%d %s", &l, &name); (err != nil) || (l < 0) {
+			return fmt.Errorf("%w: line %q", ErrBadManifest, s.Text())
+		}
+		for len(t.levels) <= l {
+			t.levels = append(t.levels, nil)
+		}
+
+		r, err := t.openRun(name)
+		if err != nil {
+			return err
+		}
+		t.levels[l] = append(t.levels[l], r)
+	}
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return nil
+}
+
+/* Lookup returns value of 'key' and whether it is present. */
+func (t *Tree[K, V]) Lookup(key K) (V, bool) {
+	var v V
+
+	t.init()
+	e, ok := t.lookup(key)
+	if (!ok) || (e.Deleted) {
+		return v, false
+	}
+	return e.Value, true
+}
+
+func (t *Tree[K, V]) Set(key K, value V) {
+	t.put(key, entry[V]{Value: value})
+}
+
+func (t *Tree[K, V]) String() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "memtable: %d\n", t.memCount)
+	for l := 0; l < len(t.levels); l++ {
+		fmt.Fprintf(&sb, "L%d:", l)
+		for _, r := range t.levels[l] {
+			fmt.Fprintf(&sb, " %s[%d]", r.Name, len(r.Keys))
+		}
+		sb.WriteRune('\n')
+	}
+
+	return sb.String()
+}
+
+/* Swap sets 'key' to 'value' and returns the previous value and whether there was one. */
+func (t *Tree[K, V]) Swap(key K, value V) (V, bool) {
+	old, ok := t.Lookup(key)
+	t.put(key, entry[V]{Value: value})
+	return old, ok
+}
+
+/*
+ * Update calls 'fn' with value of 'key' and whether it is present, then sets 'key' to the returned value or, if 'fn'
+ * returns false, deletes it. 'fn' must not modify the tree.
+ */
+func (t *Tree[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	old, ok := t.Lookup(key)
+	value, keep := fn(old, ok)
+	switch {
+	case keep:
+		t.put(key, entry[V]{Value: value})
+	case ok:
+		t.Del(key)
+	}
+}
@@ -0,0 +1,405 @@
+package lsm
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* MemtableSize is small, so that tests go through flushes and compactions of several levels. */
+const MemtableSize = 64
+
+func newTree(t testing.TB) *Tree[int, int] {
+	lt := new(Tree[int, int])
+	lt.Dir = t.TempDir()
+	lt.MemtableSize = MemtableSize
+	lt.LevelRatio = 4
+	t.Cleanup(func() { lt.Close() })
+	return lt
+}
+
+func testLSMGet(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	lt := newTree(t)
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		lt.Set(k, v)
+	}
+
+	for k, v := range m {
+		if got := lt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	if err := lt.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func testLSMDel(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	lt := newTree(t)
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		lt.Set(k, 0)
+	}
+
+	for k := range m {
+		lt.Del(k)
+		if lt.Has(k) {
+			t.Errorf("expected key %v to be removed, but it's still present", k)
+		}
+	}
+	for k := range lt.All() {
+		t.Errorf("expected tree to be empty, found key %v", k)
+	}
+	if err := lt.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func testLSMHas(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	lt := newTree(t)
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		lt.Set(k, 0)
+	}
+
+	for k := range m {
+		if !lt.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+	}
+}
+
+func testLSMSet(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	lt := newTree(t)
+
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		lt.Set(k, v)
+		if !lt.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+		if got := lt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+/* testLSMAll interleaves Set and Del, so that tombstones and newer values have to hide older ones in deeper levels. */
+func testLSMAll(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	lt := newTree(t)
+
+	m := make(map[int]int)
+	keys := make([]int, 0, constants.N)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		keys = append(keys, k)
+
+		m[k] = i
+		lt.Set(k, i)
+		if i%3 == 2 {
+			k := keys[i/2]
+			delete(m, k)
+			lt.Del(k)
+		}
+	}
+
+	expected := make([]int, 0, len(m))
+	for k := range m {
+		expected = append(expected, k)
+	}
+	slices.Sort(expected)
+
+	var got []int
+	for k, v := range lt.All() {
+		if v != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, v)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+	if err := lt.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+/* testLSMLoad checks that flushed runs are restored by Load. */
+func testLSMLoad(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	lt := &Tree[int, int]{Dir: dir, MemtableSize: MemtableSize}
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = i
+		lt.Set(k, i)
+		if i%5 == 4 {
+			delete(m, k)
+			lt.Del(k)
+		}
+	}
+	if err := lt.Close(); err != nil {
+		t.Fatalf("failed to close tree: %v", err)
+	}
+
+	lt = &Tree[int, int]{Dir: dir, MemtableSize: MemtableSize}
+	defer lt.Close()
+	if err := lt.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+
+	n := 0
+	for k, v := range lt.All() {
+		if got, ok := m[k]; (!ok) || (got != v) {
+			t.Errorf("unexpected key %v with value %v", k, v)
+		}
+		n++
+	}
+	if n != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), n)
+	}
+	for k, v := range m {
+		if got := lt.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func TestLSM(t *testing.T) {
+	tests := [...]struct {
+		Name string
+		Func func(*testing.T, generator.Generator)
+	}{
+		{"Get", testLSMGet},
+		{"Del", testLSMDel},
+		{"Has", testLSMHas},
+		{"Set", testLSMSet},
+		{"All", testLSMAll},
+		{"Load", testLSMLoad},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, generator := range generators {
+				generator.Reset()
+				t.Run(generator.String(), func(t *testing.T) {
+					test.Func(t, generator)
+				})
+			}
+		})
+	}
+}
+
+/* TestLSMPointOps runs random point operations against a map. */
+func TestLSMPointOps(t *testing.T) {
+	lt := newTree(t)
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := rng.Intn(constants.N / 8)
+		v, ok := m[k]
+
+		switch rng.Intn(7) {
+		case 0:
+			if got, found := lt.Lookup(k); (got != v) || (found != ok) {
+				t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+			}
+		case 1:
+			if !ok {
+				m[k] = i
+			}
+			if got, loaded := lt.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+				t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+			}
+		case 2:
+			m[k] = i
+			if got, loaded := lt.Swap(k, i); (got != v) || (loaded != ok) {
+				t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+			}
+		case 3:
+			old := v + rng.Intn(2)
+			want := ok && (old == v)
+			if want {
+				m[k] = i
+			}
+			if got := CompareAndSwap(lt, k, old, i); got != want {
+				t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 4:
+			/* Values of the same parity are equal. */
+			old := rng.Intn(2)
+			want := ok && ((old-v)%2 == 0)
+			if want {
+				m[k] = i
+			}
+			if got := lt.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+				t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 5:
+			/* Every third update deletes the key. */
+			keep := i%3 != 0
+			if keep {
+				m[k] = i
+			} else {
+				delete(m, k)
+			}
+			lt.Update(k, func(got int, found bool) (int, bool) {
+				if (got != v) || (found != ok) {
+					t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+				}
+				return i, keep
+			})
+		case 6:
+			delete(m, k)
+			lt.Del(k)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	var n int
+	for k, v := range lt.All() {
+		if (n >= len(keys)) || (keys[n] != k) || (m[k] != v) {
+			t.Fatalf("All: unexpected pair %v: %v at %d", k, v, n)
+		}
+		n++
+	}
+	if n != len(keys) {
+		t.Errorf("All: expected %d keys, got %d", len(keys), n)
+	}
+}
+
+func TestLSMClear(t *testing.T) {
+	lt := newTree(t)
+	for i := 0; i < 10*MemtableSize; i++ {
+		lt.Set(i, i)
+	}
+
+	lt.Clear()
+	for k := range lt.All() {
+		t.Errorf("expected tree to be empty, found key %v", k)
+	}
+	if lt.Has(0) {
+		t.Errorf("expected key %v to be removed, but it's still present", 0)
+	}
+
+	if err := lt.Load(); err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	if s := fmt.Sprint(lt); s != "memtable: 0\nL0:\n" {
+		t.Errorf("expected empty tree after Load, got %q", s)
+	}
+}
+
+func benchmarkLSMGet(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	lt := newTree(b)
+	lt.MemtableSize = DefaultMemtableSize
+	for i := 0; i < b.N; i++ {
+		lt.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = lt.Get(g.Generate())
+	}
+}
+
+func benchmarkLSMDel(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	lt := newTree(b)
+	lt.MemtableSize = DefaultMemtableSize
+	for i := 0; i < b.N; i++ {
+		lt.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lt.Del(g.Generate())
+	}
+}
+
+func benchmarkLSMSet(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	lt := newTree(b)
+	lt.MemtableSize = DefaultMemtableSize
+	for i := 0; i < b.N; i++ {
+		lt.Set(g.Generate(), 0)
+	}
+}
+
+func BenchmarkLSM(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, generator.Generator)
+	}{
+		{"Get", benchmarkLSMGet},
+		{"Del", benchmarkLSMDel},
+		{"Set", benchmarkLSMSet},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, generator := range generators {
+				generator.Reset()
+				b.Run(generator.String(), func(b *testing.B) {
+					op.Func(b, generator)
+				})
+			}
+		})
+	}
+}
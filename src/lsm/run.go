@@ -0,0 +1,243 @@
+package lsm
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+/* entry is either a value or a tombstone, which hides values of the same key in older runs. */
+type entry[V any] struct {
+	Value   V
+	Deleted bool
+}
+
+/* run is an immutable file of entries sorted by key. Keys and offsets of records are kept in memory. */
+type run[K cmp.Ordered] struct {
+	Name string
+	File *os.File
+
+	Keys    []K
+	offsets []int64
+}
+
+/*
+ * Run file is a sequence of records:
+ *	[payload size:uvarint][flags:1][key][value]
+ * where value is absent for tombstones.
+ */
+const flagDeleted = 1 << 0
+
+func (r *run[K]) find(key K) (int, bool) {
+	return slices.BinarySearch(r.Keys, key)
+}
+
+func (t *Tree[K, V]) encodeEntry(buf []byte, key K, e entry[V]) []byte {
+	var flags byte
+	if e.Deleted {
+		flags |= flagDeleted
+	}
+	buf = append(buf, flags)
+	buf = t.KeyCodec.Append(buf, key)
+	if !e.Deleted {
+		buf = t.ValueCodec.Append(buf, e.Value)
+	}
+	return buf
+}
+
+func (t *Tree[K, V]) decodeEntry(payload []byte) (K, entry[V], error) {
+	var key K
+	var e entry[V]
+
+	if len(payload) < 1 {
+		return key, e, ErrCorrupted
+	}
+	e.Deleted = payload[0]&flagDeleted != 0
+
+	key, n, err := t.KeyCodec.Decode(payload[1:])
+	if err != nil {
+		return key, e, fmt.Errorf("failed to decode key: %w", err)
+	}
+	if !e.Deleted {
+		e.Value, _, err = t.ValueCodec.Decode(payload[1+n:])
+		if err != nil {
+			return key, e, fmt.Errorf("failed to decode value: %w", err)
+		}
+	}
+	return key, e, nil
+}
+
+/* readRecord reads next record payload from 'r' into 'buf'. */
+func readRecord(r *bufio.Reader, buf []byte) ([]byte, int, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint64(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, 0, err
+	}
+	return buf, uvarintSize(size) + int(size), nil
+}
+
+func uvarintSize(x uint64) int {
+	n := 1
+	for x >= 0x80 {
+		x >>= 7
+		n++
+	}
+	return n
+}
+
+/* writeRun stores entries from 'seq' in a new run file. If 'drop' is set, tombstones are left out. Returns nil if nothing was written. */
+func (t *Tree[K, V]) writeRun(seq iter.Seq2[K, entry[V]], drop bool) (*run[K], error) {
+	name := fmt.Sprintf("%06d.run", t.next)
+	t.next++
+
+	f, err := os.OpenFile(filepath.Join(t.Dir, name), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run file: %w", err)
+	}
+	r := &run[K]{Name: name, File: f}
+	w := bufio.NewWriter(f)
+
+	var offset int64
+	for key, e := range seq {
+		if (drop) && (e.Deleted) {
+			continue
+		}
+		t.payload = t.encodeEntry(t.payload[:0], key, e)
+		t.buf = binary.AppendUvarint(t.buf[:0], uint64(len(t.payload)))
+		t.buf = append(t.buf, t.payload...)
+		if _, err := w.Write(t.buf); err != nil {
+			r.remove(t.Dir)
+			return nil, fmt.Errorf("failed to write run file: %w", err)
+		}
+
+		r.Keys = append(r.Keys, key)
+		r.offsets = append(r.offsets, offset)
+		offset += int64(len(t.buf))
+	}
+	r.offsets = append(r.offsets, offset)
+
+	if len(r.Keys) == 0 {
+		r.remove(t.Dir)
+		return nil, nil
+	}
+	if err := w.Flush(); err != nil {
+		r.remove(t.Dir)
+		return nil, fmt.Errorf("failed to write run file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		r.remove(t.Dir)
+		return nil, fmt.Errorf("failed to sync run file: %w", err)
+	}
+
+	return r, nil
+}
+
+/* openRun reads keys of existing run file. */
+func (t *Tree[K, V]) openRun(name string) (*run[K], error) {
+	f, err := os.Open(filepath.Join(t.Dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run file: %w", err)
+	}
+	r := &run[K]{Name: name, File: f}
+
+	br := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, n, err := readRecord(br, t.payload)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read run %s: %w", name, err)
+		}
+		t.payload = payload
+
+		key, _, err := t.decodeEntry(payload)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%w: run %s: %w", ErrCorrupted, name, err)
+		}
+		if (len(r.Keys) > 0) && (key <= r.Keys[len(r.Keys)-1]) {
+			f.Close()
+			return nil, fmt.Errorf("%w: run %s is not sorted", ErrCorrupted, name)
+		}
+
+		r.Keys = append(r.Keys, key)
+		r.offsets = append(r.offsets, offset)
+		offset += int64(n)
+	}
+	r.offsets = append(r.offsets, offset)
+
+	return r, nil
+}
+
+/* readEntry returns i-th entry of the run. */
+func (t *Tree[K, V]) readEntry(r *run[K], i int) (entry[V], error) {
+	size := r.offsets[i+1] - r.offsets[i]
+	if int64(cap(t.buf)) < size {
+		t.buf = make([]byte, size)
+	}
+	buf := t.buf[:size]
+	if _, err := r.File.ReadAt(buf, r.offsets[i]); err != nil {
+		return entry[V]{}, fmt.Errorf("failed to read run %s: %w", r.Name, err)
+	}
+
+	_, n := binary.Uvarint(buf)
+	if n <= 0 {
+		return entry[V]{}, fmt.Errorf("%w: run %s", ErrCorrupted, r.Name)
+	}
+	_, e, err := t.decodeEntry(buf[n:])
+	return e, err
+}
+
+/* scanRun returns iterator over all entries of the run. Errors stop iteration and are passed to 'fail'. */
+func (t *Tree[K, V]) scanRun(r *run[K], fail func(error)) iter.Seq2[K, entry[V]] {
+	return func(yield func(K, entry[V]) bool) {
+		br := bufio.NewReader(io.NewSectionReader(r.File, 0, r.offsets[len(r.offsets)-1]))
+
+		var buf []byte
+		for {
+			payload, _, err := readRecord(br, buf)
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				fail(fmt.Errorf("failed to read run %s: %w", r.Name, err))
+				return
+			}
+			buf = payload
+
+			key, e, err := t.decodeEntry(payload)
+			if err != nil {
+				fail(fmt.Errorf("%w: run %s: %w", ErrCorrupted, r.Name, err))
+				return
+			}
+			if !yield(key, e) {
+				return
+			}
+		}
+	}
+}
+
+func (r *run[K]) remove(dir string) error {
+	r.File.Close()
+	if err := os.Remove(filepath.Join(dir, r.Name)); err != nil {
+		return fmt.Errorf("failed to remove run file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package pager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+type PageID uint32
+
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	Stat() (os.FileInfo, error)
+	Sync() error
+}
+
+/* Store is implemented by Pager and anything layered on top of it, like buffer pools. */
+type Store interface {
+	Alloc() (PageID, error)
+	Free(PageID) error
+	LSN() uint64
+	PageSize() int
+	Read(PageID, []byte) error
+	Root() PageID
+	SetLSN(uint64)
+	SetRoot(PageID)
+	Sync() error
+	Write(PageID, []byte) error
+}
+
+/* Viewer is a Store which lets pages be read in place, without copying them. Contents passed to 'fn' are valid only until it returns. */
+type Viewer interface {
+	View(id PageID, fn func(data []byte) error) error
+}
+
+/* Pager maps fixed-size pages of a file to page IDs. Page 0 holds metadata, so 0 is never a valid data page. */
+type Pager struct {
+	File File
+
+	pageSize  int
+	pageCount PageID
+	freeHead  PageID
+	root      PageID
+	lsn       uint64
+
+	meta []byte
+}
+
+const (
+	MinPageSize     = 512
+	DefaultPageSize = 4096
+)
+
+const InvalidPage PageID = 0
+
+const (
+	magic       = "DBMSPAGE"
+	metaVersion = 1
+
+	metaMagic     = 0
+	metaVersionAt = 8
+	metaPageSize  = 12
+	metaPageCount = 16
+	metaFreeHead  = 20
+	metaRoot      = 24
+	metaLSN       = 28
+	metaSize      = 36
+)
+
+var (
+	ErrBadMeta     = errors.New("bad metadata page")
+	ErrBadPage     = errors.New("invalid page ID")
+	ErrBadPageSize = errors.New("invalid page size")
+)
+
+/* Open opens or creates a paged file. pageSize of 0 selects DefaultPageSize for new files and the stored size for existing ones. */
+func Open(path string, pageSize int) (*Pager, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pager file: %w", err)
+	}
+
+	p, err := New(f, pageSize)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func New(f File, pageSize int) (*Pager, error) {
+	p := &Pager{File: f}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat pager file: %w", err)
+	}
+	if fi.Size() == 0 {
+		if pageSize == 0 {
+			pageSize = DefaultPageSize
+		}
+		if (pageSize < MinPageSize) || (pageSize&(pageSize-1) != 0) {
+			return nil, fmt.Errorf("%w: %d", ErrBadPageSize, pageSize)
+		}
+		p.pageSize = pageSize
+		p.pageCount = 1
+		p.meta = make([]byte, pageSize)
+		if err := p.Sync(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	var hdr [metaSize]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if (string(hdr[metaMagic:metaMagic+len(magic)]) != magic) || (binary.LittleEndian.Uint32(hdr[metaVersionAt:]) != metaVersion) {
+		return nil, ErrBadMeta
+	}
+	stored := int(binary.LittleEndian.Uint32(hdr[metaPageSize:]))
+	if (pageSize != 0) && (pageSize != stored) {
+		return nil, fmt.Errorf("%w: file uses %d, requested %d", ErrBadPageSize, stored, pageSize)
+	}
+
+	p.pageSize = stored
+	p.meta = make([]byte, stored)
+	if _, err := f.ReadAt(p.meta, 0); err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	p.pageCount = PageID(binary.LittleEndian.Uint32(p.meta[metaPageCount:]))
+	p.freeHead = PageID(binary.LittleEndian.Uint32(p.meta[metaFreeHead:]))
+	p.root = PageID(binary.LittleEndian.Uint32(p.meta[metaRoot:]))
+	p.lsn = binary.LittleEndian.Uint64(p.meta[metaLSN:])
+
+	return p, nil
+}
+
+func (p *Pager) offset(id PageID) int64 {
+	return int64(id) * int64(p.pageSize)
+}
+
+func (p *Pager) Alloc() (PageID, error) {
+	if p.freeHead != InvalidPage {
+		id := p.freeHead
+
+		var next [4]byte
+		if _, err := p.File.ReadAt(next[:], p.offset(id)); err != nil {
+			return InvalidPage, fmt.Errorf("failed to read free list: %w", err)
+		}
+		p.freeHead = PageID(binary.LittleEndian.Uint32(next[:]))
+		return id, nil
+	}
+
+	id := p.pageCount
+	p.pageCount++
+	return id, nil
+}
+
+func (p *Pager) Close() error {
+	if err := p.Sync(); err != nil {
+		p.File.Close()
+		return err
+	}
+	return p.File.Close()
+}
+
+func (p *Pager) Free(id PageID) error {
+	if (id == InvalidPage) || (id >= p.pageCount) {
+		return fmt.Errorf("%w: %d", ErrBadPage, id)
+	}
+
+	var next [4]byte
+	binary.LittleEndian.PutUint32(next[:], uint32(p.freeHead))
+	if _, err := p.File.WriteAt(next[:], p.offset(id)); err != nil {
+		return fmt.Errorf("failed to write free list: %w", err)
+	}
+	p.freeHead = id
+	return nil
+}
+
+/* LSN returns log position of the last checkpoint, as set by SetLSN. */
+func (p *Pager) LSN() uint64 {
+	return p.lsn
+}
+
+func (p *Pager) PageCount() PageID {
+	return p.pageCount
+}
+
+func (p *Pager) PageSize() int {
+	return p.pageSize
+}
+
+/* Read fills buf with contents of page 'id'. Pages that were allocated but never written read as zeroes. */
+func (p *Pager) Read(id PageID, buf []byte) error {
+	if (id == InvalidPage) || (id >= p.pageCount) {
+		return fmt.Errorf("%w: %d", ErrBadPage, id)
+	} else if len(buf) != p.pageSize {
+		return fmt.Errorf("%w: buffer of %d bytes", ErrBadPageSize, len(buf))
+	}
+
+	n, err := p.File.ReadAt(buf, p.offset(id))
+	if err == io.EOF {
+		clear(buf[n:])
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read page %d: %w", id, err)
+	}
+	return nil
+}
+
+func (p *Pager) Root() PageID {
+	return p.root
+}
+
+func (p *Pager) SetLSN(lsn uint64) {
+	p.lsn = lsn
+}
+
+func (p *Pager) SetRoot(id PageID) {
+	p.root = id
+}
+
+/* Sync writes metadata and flushes file contents to stable storage. */
+func (p *Pager) Sync() error {
+	copy(p.meta[metaMagic:], magic)
+	binary.LittleEndian.PutUint32(p.meta[metaVersionAt:], metaVersion)
+	binary.LittleEndian.PutUint32(p.meta[metaPageSize:], uint32(p.pageSize))
+	binary.LittleEndian.PutUint32(p.meta[metaPageCount:], uint32(p.pageCount))
+	binary.LittleEndian.PutUint32(p.meta[metaFreeHead:], uint32(p.freeHead))
+	binary.LittleEndian.PutUint32(p.meta[metaRoot:], uint32(p.root))
+	binary.LittleEndian.PutUint64(p.meta[metaLSN:], p.lsn)
+
+	if _, err := p.File.WriteAt(p.meta, 0); err != nil {
+		return fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if err := p.File.Sync(); err != nil {
+		return fmt.Errorf("failed to sync pager file: %w", err)
+	}
+	return nil
+}
+
+func (p *Pager) Write(id PageID, buf []byte) error {
+	if (id == InvalidPage) || (id >= p.pageCount) {
+		return fmt.Errorf("%w: %d", ErrBadPage, id)
+	} else if len(buf) != p.pageSize {
+		return fmt.Errorf("%w: buffer of %d bytes", ErrBadPageSize, len(buf))
+	}
+
+	if _, err := p.File.WriteAt(buf, p.offset(id)); err != nil {
+		return fmt.Errorf("failed to write page %d: %w", id, err)
+	}
+	return nil
+}
+
+var _ Store = &Pager{}
@@ -0,0 +1,109 @@
+package pager
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pager.db")
+
+	p, err := Open(path, MinPageSize)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+
+	const n = 16
+	ids := make([]PageID, n)
+	buf := make([]byte, p.PageSize())
+	for i := 0; i < n; i++ {
+		id, err := p.Alloc()
+		if err != nil {
+			t.Fatalf("failed to allocate page: %v", err)
+		}
+		if id == InvalidPage {
+			t.Fatalf("allocated invalid page")
+		}
+		ids[i] = id
+
+		for j := range buf {
+			buf[j] = byte(i)
+		}
+		if err := p.Write(id, buf); err != nil {
+			t.Fatalf("failed to write page %d: %v", id, err)
+		}
+	}
+	p.SetRoot(ids[n-1])
+	p.SetLSN(100500)
+	if err := p.Close(); err != nil {
+		t.Fatalf("failed to close pager: %v", err)
+	}
+
+	p, err = Open(path, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen pager: %v", err)
+	}
+	defer p.Close()
+
+	if p.PageSize() != MinPageSize {
+		t.Errorf("expected page size %d, got %d", MinPageSize, p.PageSize())
+	}
+	if p.Root() != ids[n-1] {
+		t.Errorf("expected root %d, got %d", ids[n-1], p.Root())
+	}
+	if p.LSN() != 100500 {
+		t.Errorf("expected LSN %d, got %d", 100500, p.LSN())
+	}
+	for i := 0; i < n; i++ {
+		if err := p.Read(ids[i], buf); err != nil {
+			t.Fatalf("failed to read page %d: %v", ids[i], err)
+		}
+		if !bytes.Equal(buf, bytes.Repeat([]byte{byte(i)}, len(buf))) {
+			t.Errorf("page %d has unexpected contents", ids[i])
+		}
+	}
+
+	/* Freed pages must be reused before the file grows. */
+	count := p.PageCount()
+	for i := 0; i < n; i += 2 {
+		if err := p.Free(ids[i]); err != nil {
+			t.Fatalf("failed to free page %d: %v", ids[i], err)
+		}
+	}
+	for i := 0; i < n; i += 2 {
+		if _, err := p.Alloc(); err != nil {
+			t.Fatalf("failed to allocate page: %v", err)
+		}
+	}
+	if p.PageCount() != count {
+		t.Errorf("expected %d pages, got %d", count, p.PageCount())
+	}
+}
+
+func TestPagerErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Open(filepath.Join(dir, "bad.db"), 1000); !errors.Is(err, ErrBadPageSize) {
+		t.Errorf("expected error %v, got %v", ErrBadPageSize, err)
+	}
+
+	path := filepath.Join(dir, "pager.db")
+	p, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("failed to open pager: %v", err)
+	}
+	buf := make([]byte, p.PageSize())
+	if err := p.Read(InvalidPage, buf); !errors.Is(err, ErrBadPage) {
+		t.Errorf("expected error %v, got %v", ErrBadPage, err)
+	}
+	if err := p.Write(p.PageCount(), buf); !errors.Is(err, ErrBadPage) {
+		t.Errorf("expected error %v, got %v", ErrBadPage, err)
+	}
+	p.Close()
+
+	if _, err := Open(path, 2*DefaultPageSize); !errors.Is(err, ErrBadPageSize) {
+		t.Errorf("expected error %v, got %v", ErrBadPageSize, err)
+	}
+}
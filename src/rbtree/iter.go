@@ -0,0 +1,134 @@
+package rbtree
+
+import "iter"
+
+func (node *Node[K, V]) minimumNode() *Node[K, V] {
+	if node == nil {
+		return nil
+	}
+	for node.Left != nil {
+		node = node.Left
+	}
+	return node
+}
+
+// next returns in-order successor of the node, or nil.
+func (node *Node[K, V]) next() *Node[K, V] {
+	if node.Right != nil {
+		return node.Right.minimumNode()
+	}
+	for node.Parent != nil && node == node.Parent.Right {
+		node = node.Parent
+	}
+	return node.Parent
+}
+
+// prev returns in-order predecessor of the node, or nil.
+func (node *Node[K, V]) prev() *Node[K, V] {
+	if node.Left != nil {
+		return node.Left.maximumNode()
+	}
+	for node.Parent != nil && node == node.Parent.Left {
+		node = node.Parent
+	}
+	return node.Parent
+}
+
+// ceiling returns node with the smallest key greater than or equal to the given one, or nil.
+func (tree *Tree[K, V]) ceiling(key K) *Node[K, V] {
+	var found *Node[K, V]
+
+	node := tree.Root
+	for node != nil {
+		c := tree.Compare(key, node.Key)
+		if c == 0 {
+			return node
+		} else if c < 0 {
+			found = node
+			node = node.Left
+		} else {
+			node = node.Right
+		}
+	}
+	return found
+}
+
+// floor returns node with the largest key less than or equal to the given one, or nil.
+func (tree *Tree[K, V]) floor(key K) *Node[K, V] {
+	var found *Node[K, V]
+
+	node := tree.Root
+	for node != nil {
+		c := tree.Compare(key, node.Key)
+		if c == 0 {
+			return node
+		} else if c < 0 {
+			node = node.Left
+		} else {
+			found = node
+			node = node.Right
+		}
+	}
+	return found
+}
+
+func entry[K any, V any](node *Node[K, V]) (K, V, bool) {
+	if node == nil {
+		var k K
+		var v V
+		return k, v, false
+	}
+	return node.Key, node.Value, true
+}
+
+// All returns iterator over all key-value pairs in ascending order.
+func (tree *Tree[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for node := tree.Root.minimumNode(); node != nil; node = node.next() {
+			if !yield(node.Key, node.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Ceiling returns the smallest key greater than or equal to the given one.
+func (tree *Tree[K, V]) Ceiling(key K) (K, V, bool) {
+	return entry(tree.ceiling(key))
+}
+
+// Floor returns the largest key less than or equal to the given one.
+func (tree *Tree[K, V]) Floor(key K) (K, V, bool) {
+	return entry(tree.floor(key))
+}
+
+func (tree *Tree[K, V]) Max() (K, V, bool) {
+	return entry(tree.Root.maximumNode())
+}
+
+func (tree *Tree[K, V]) Min() (K, V, bool) {
+	return entry(tree.Root.minimumNode())
+}
+
+// Range returns iterator over keys from lo up to, but not including, hi.
+// If lo is greater than hi, keys are visited in descending order, again from lo inclusive to hi exclusive.
+func (tree *Tree[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		if tree.Root == nil {
+			return
+		}
+		if tree.Compare(lo, hi) <= 0 {
+			for node := tree.ceiling(lo); node != nil && tree.Compare(node.Key, hi) < 0; node = node.next() {
+				if !yield(node.Key, node.Value) {
+					return
+				}
+			}
+		} else {
+			for node := tree.floor(lo); node != nil && tree.Compare(node.Key, hi) > 0; node = node.prev() {
+				if !yield(node.Key, node.Value) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package rbtree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* Number of random ranges and probes checked per test. */
+const Ranges = 64
+
+func testRBtreeIter(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var rb Tree[int, int]
+
+	if _, _, ok := rb.Min(); ok {
+		t.Errorf("expected no minimum in empty tree")
+	}
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		m[k] = i
+		rb.Set(k, i)
+	}
+	for k := range m {
+		if k%3 == 0 {
+			rb.Del(k)
+			delete(m, k)
+		}
+	}
+
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var got []int
+	for k, v := range rb.All() {
+		if v != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, v)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, keys) {
+		t.Errorf("expected keys %v, got %v", keys, got)
+	}
+
+	if k, _, _ := rb.Min(); k != keys[0] {
+		t.Errorf("expected minimum %v, got %v", keys[0], k)
+	}
+	if k, _, _ := rb.Max(); k != keys[len(keys)-1] {
+		t.Errorf("expected maximum %v, got %v", keys[len(keys)-1], k)
+	}
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for j := 0; j < Ranges; j++ {
+		lo := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+		hi := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+
+		i, found := slices.BinarySearch(keys, lo)
+		k, v, ok := rb.Ceiling(lo)
+		if (i < len(keys)) != ok {
+			t.Errorf("Ceiling(%v): expected presence %v, got %v", lo, i < len(keys), ok)
+		} else if (ok) && ((k != keys[i]) || (v != m[k])) {
+			t.Errorf("Ceiling(%v): expected key %v, got %v", lo, keys[i], k)
+		}
+		if !found {
+			i--
+		}
+		k, v, ok = rb.Floor(lo)
+		if (i >= 0) != ok {
+			t.Errorf("Floor(%v): expected presence %v, got %v", lo, i >= 0, ok)
+		} else if (ok) && ((k != keys[i]) || (v != m[k])) {
+			t.Errorf("Floor(%v): expected key %v, got %v", lo, keys[i], k)
+		}
+
+		var expected []int
+		if lo <= hi {
+			for i := 0; i < len(keys); i++ {
+				if (keys[i] >= lo) && (keys[i] < hi) {
+					expected = append(expected, keys[i])
+				}
+			}
+		} else {
+			for i := len(keys) - 1; i >= 0; i-- {
+				if (keys[i] <= lo) && (keys[i] > hi) {
+					expected = append(expected, keys[i])
+				}
+			}
+		}
+
+		got = got[:0]
+		for k := range rb.Range(lo, hi) {
+			got = append(got, k)
+		}
+		if !slices.Equal(got, expected) {
+			t.Errorf("Range(%v, %v): expected %v, got %v", lo, hi, expected, got)
+		}
+	}
+
+	for range rb.All() {
+		break
+	}
+}
+
+func TestRBtreeIter(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testRBtreeIter(t, generator)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package rbtree
+
+// Every node keeps size of its subtree, so order statistics take O(log n).
+
+// Len returns number of keys in the tree.
+func (tree *Tree[K, V]) Len() int {
+	return nodeSize(tree.Root)
+}
+
+// Rank returns number of keys less than the given one.
+func (tree *Tree[K, V]) Rank(key K) int {
+	var rank int
+
+	node := tree.Root
+	for node != nil {
+		if tree.Compare(key, node.Key) <= 0 {
+			node = node.Left
+		} else {
+			rank += nodeSize(node.Left) + 1
+			node = node.Right
+		}
+	}
+	return rank
+}
+
+// rankInclusive returns number of keys less than or equal to the given one.
+func (tree *Tree[K, V]) rankInclusive(key K) int {
+	var rank int
+
+	node := tree.Root
+	for node != nil {
+		if tree.Compare(key, node.Key) < 0 {
+			node = node.Left
+		} else {
+			rank += nodeSize(node.Left) + 1
+			node = node.Right
+		}
+	}
+	return rank
+}
+
+// Select returns i-th smallest key, counting from zero.
+func (tree *Tree[K, V]) Select(i int) (K, V, bool) {
+	if (i < 0) || (i >= tree.Len()) {
+		return entry[K, V](nil)
+	}
+
+	node := tree.Root
+	for {
+		left := nodeSize(node.Left)
+		if i < left {
+			node = node.Left
+		} else if i == left {
+			return entry(node)
+		} else {
+			i -= left + 1
+			node = node.Right
+		}
+	}
+}
+
+// CountRange returns number of keys Range visits for the same arguments.
+func (tree *Tree[K, V]) CountRange(lo, hi K) int {
+	if tree.Root == nil {
+		return 0
+	}
+	if tree.Compare(lo, hi) <= 0 {
+		return tree.Rank(hi) - tree.Rank(lo)
+	}
+	return tree.rankInclusive(lo) - tree.rankInclusive(hi)
+}
@@ -0,0 +1,109 @@
+package rbtree
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"constants"
+	"generator"
+)
+
+/* checkSizes verifies that every node keeps size of its subtree. Returns size of the subtree. */
+func checkSizes[K cmp.Ordered, V any](t *testing.T, node *Node[K, V]) int {
+	t.Helper()
+
+	if node == nil {
+		return 0
+	}
+	size := checkSizes(t, node.Left) + checkSizes(t, node.Right) + 1
+	if node.size != size {
+		t.Errorf("node %v has size %d, expected %d", node.Key, node.size, size)
+	}
+	return size
+}
+
+func testRBtreeRank(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var rb Tree[int, int]
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		m[k] = i
+		rb.Set(k, i)
+	}
+	checkSizes(t, rb.Root)
+	for k := range m {
+		if k%3 == 0 {
+			rb.Del(k)
+			delete(m, k)
+		}
+	}
+	checkSizes(t, rb.Root)
+
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	if rb.Len() != len(keys) {
+		t.Errorf("expected length %d, got %d", len(keys), rb.Len())
+	}
+	for i, key := range keys {
+		if k, v, ok := rb.Select(i); (!ok) || (k != key) || (v != m[key]) {
+			t.Errorf("Select(%d): expected key %v, got %v", i, key, k)
+		}
+		if r := rb.Rank(key); r != i {
+			t.Errorf("Rank(%v): expected %d, got %d", key, i, r)
+		}
+	}
+	if _, _, ok := rb.Select(len(keys)); ok {
+		t.Errorf("Select(%d): expected nothing past the last key", len(keys))
+	}
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	for j := 0; j < Ranges; j++ {
+		lo := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+		hi := keys[0] - 1 + rng.Intn(keys[len(keys)-1]-keys[0]+3)
+
+		i, _ := slices.BinarySearch(keys, lo)
+		if r := rb.Rank(lo); r != i {
+			t.Errorf("Rank(%v): expected %d, got %d", lo, i, r)
+		}
+
+		var expected int
+		for range rb.Range(lo, hi) {
+			expected++
+		}
+		if n := rb.CountRange(lo, hi); n != expected {
+			t.Errorf("CountRange(%v, %v): expected %d, got %d", lo, hi, expected, n)
+		}
+	}
+
+	for _, k := range keys {
+		rb.Del(k)
+	}
+	if rb.Len() != 0 {
+		t.Errorf("expected empty tree, got length %d", rb.Len())
+	}
+}
+
+func TestRBtreeRank(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testRBtreeRank(t, generator)
+		})
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"cmp"
 	"fmt"
 	"strings"
+
+	"compare"
 )
 
 type color bool
@@ -23,7 +25,7 @@ const (
 	black, red color = true, false
 )
 
-type Node[K cmp.Ordered, V any] struct {
+type Node[K any, V any] struct {
 	Key   K
 	Value V
 
@@ -32,19 +34,146 @@ type Node[K cmp.Ordered, V any] struct {
 	Parent *Node[K, V]
 
 	color color
+	size  int
 }
 
-type Tree[K cmp.Ordered, V any] struct {
+type Tree[K any, V any] struct {
 	Root *Node[K, V]
+
+	// Compare orders keys. Zero value tree uses compare.Default, see New and NewFunc.
+	Compare func(a, b K) int
+
+	// find is picked by initFind.
+	find func(node *Node[K, V], key K) (*Node[K, V], int)
+
+	// Augment, if set, recomputes whatever the node keeps about its subtree from its own value and its children.
+	// It is called bottom-up for every node whose subtree has changed, rotations included.
+	Augment func(node *Node[K, V])
+}
+
+// New returns tree ordering keys with cmp.Compare.
+func New[K cmp.Ordered, V any]() *Tree[K, V] {
+	tree := &Tree[K, V]{Compare: cmp.Compare[K]}
+	tree.find = findOrdered[K, V]
+	return tree
+}
+
+// NewFunc returns tree ordering keys with the given comparator.
+func NewFunc[K any, V any](compare func(a, b K) int) *Tree[K, V] {
+	return &Tree[K, V]{Compare: compare}
+}
+
+// findOrdered descends from the node towards the key, comparing keys directly.
+// Returns node with the key and 0, or the last node visited and the sign of comparison of the key with its key.
+func findOrdered[K cmp.Ordered, V any](node *Node[K, V], key K) (*Node[K, V], int) {
+	for {
+		if key == node.Key {
+			return node, 0
+		} else if key < node.Key {
+			if node.Left == nil {
+				return node, -1
+			}
+			node = node.Left
+		} else {
+			if node.Right == nil {
+				return node, 1
+			}
+			node = node.Right
+		}
+	}
+}
+
+// findFunc is findOrdered for keys compared with the given comparator.
+func findFunc[K any, V any](node *Node[K, V], key K, compare func(a, b K) int) (*Node[K, V], int) {
+	for {
+		c := compare(key, node.Key)
+		if c == 0 {
+			return node, 0
+		} else if c < 0 {
+			if node.Left == nil {
+				return node, -1
+			}
+			node = node.Left
+		} else {
+			if node.Right == nil {
+				return node, 1
+			}
+			node = node.Right
+		}
+	}
+}
+
+// initFind sets default comparator if there is none and picks the way to find keys.
+// Built-in ordered types with default comparator are compared directly, as in trees made by New.
+func (tree *Tree[K, V]) initFind() {
+	if tree.Compare == nil {
+		tree.Compare = compare.Default[K]()
+		if tree.Compare == nil {
+			panic(fmt.Sprintf("rbtree: no default comparator for %T, use NewFunc", *new(K)))
+		}
+
+		switch tree := any(tree).(type) {
+		case *Tree[int, V]:
+			tree.find = findOrdered[int, V]
+		case *Tree[int8, V]:
+			tree.find = findOrdered[int8, V]
+		case *Tree[int16, V]:
+			tree.find = findOrdered[int16, V]
+		case *Tree[int32, V]:
+			tree.find = findOrdered[int32, V]
+		case *Tree[int64, V]:
+			tree.find = findOrdered[int64, V]
+		case *Tree[uint, V]:
+			tree.find = findOrdered[uint, V]
+		case *Tree[uint8, V]:
+			tree.find = findOrdered[uint8, V]
+		case *Tree[uint16, V]:
+			tree.find = findOrdered[uint16, V]
+		case *Tree[uint32, V]:
+			tree.find = findOrdered[uint32, V]
+		case *Tree[uint64, V]:
+			tree.find = findOrdered[uint64, V]
+		case *Tree[uintptr, V]:
+			tree.find = findOrdered[uintptr, V]
+		case *Tree[float32, V]:
+			tree.find = findOrdered[float32, V]
+		case *Tree[float64, V]:
+			tree.find = findOrdered[float64, V]
+		case *Tree[string, V]:
+			tree.find = findOrdered[string, V]
+		}
+	}
+	if tree.find == nil {
+		tree.find = func(node *Node[K, V], key K) (*Node[K, V], int) {
+			return findFunc(node, key, tree.Compare)
+		}
+	}
 }
 
-func nodeColor[K cmp.Ordered, V any](node *Node[K, V]) color {
+func nodeColor[K any, V any](node *Node[K, V]) color {
 	if node == nil {
 		return black
 	}
 	return node.color
 }
 
+func nodeSize[K any, V any](node *Node[K, V]) int {
+	if node == nil {
+		return 0
+	}
+	return node.size
+}
+
+// augment calls Augment for the node and all its ancestors.
+func (tree *Tree[K, V]) augment(node *Node[K, V]) {
+	if tree.Augment == nil {
+		return
+	}
+	for ; node != nil; node = node.Parent {
+		tree.Augment(node)
+	}
+}
+
 func (node *Node[K, V]) maximumNode() *Node[K, V] {
 	if node == nil {
 		return nil
@@ -210,17 +339,14 @@ func (tree *Tree[K, V]) insertCase5(node *Node[K, V]) {
 }
 
 func (tree *Tree[K, V]) lookup(key K) *Node[K, V] {
-	node := tree.Root
-	for node != nil {
-		if key == node.Key {
-			return node
-		} else if key < node.Key {
-			node = node.Left
-		} else {
-			node = node.Right
-		}
+	if tree.Root == nil {
+		return nil
 	}
-	return nil
+	node, c := tree.find(tree.Root, key)
+	if c != 0 {
+		return nil
+	}
+	return node
 }
 
 func (tree *Tree[K, V]) replaceNode(old *Node[K, V], new *Node[K, V]) {
@@ -247,6 +373,13 @@ func (tree *Tree[K, V]) rotateLeft(node *Node[K, V]) {
 	}
 	right.Left = node
 	node.Parent = right
+
+	right.size = node.size
+	node.size = nodeSize(node.Left) + nodeSize(node.Right) + 1
+	if tree.Augment != nil {
+		tree.Augment(node)
+		tree.Augment(right)
+	}
 }
 
 func (tree *Tree[K, V]) rotateRight(node *Node[K, V]) {
@@ -258,19 +391,47 @@ func (tree *Tree[K, V]) rotateRight(node *Node[K, V]) {
 	}
 	left.Right = node
 	node.Parent = left
+
+	left.size = node.size
+	node.size = nodeSize(node.Left) + nodeSize(node.Right) + 1
+	if tree.Augment != nil {
+		tree.Augment(node)
+		tree.Augment(left)
+	}
 }
 
 func (tree *Tree[K, V]) Clear() {
 	tree.Root = nil
 }
 
-func (tree *Tree[K, V]) Del(key K) {
-	var child *Node[K, V]
+// CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==.
+func CompareAndSwap[K any, V comparable](tree *Tree[K, V], key K, old, new V) bool {
+	return tree.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
 
+// CompareAndSwapFunc sets value of the key to new if it is present with value old according to equal.
+func (tree *Tree[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
+	node, c := tree.search(key)
+	if (c != 0) || (!equal(node.Value, old)) {
+		return false
+	}
+	node.Value = new
+	tree.augment(node)
+	return true
+}
+
+func (tree *Tree[K, V]) Del(key K) {
 	node := tree.lookup(key)
 	if node == nil {
 		return
 	}
+	tree.remove(node)
+}
+
+// remove deletes the node from the tree.
+func (tree *Tree[K, V]) remove(node *Node[K, V]) {
+	var child *Node[K, V]
+
 	if (node.Left != nil) && (node.Right != nil) {
 		pred := node.Left.maximumNode()
 		node.Key = pred.Key
@@ -283,6 +444,13 @@ func (tree *Tree[K, V]) Del(key K) {
 		} else {
 			child = node.Right
 		}
+
+		// Node stops counting itself before rebalancing, so rotations keep sizes right.
+		node.size--
+		for parent := node.Parent; parent != nil; parent = parent.Parent {
+			parent.size--
+		}
+
 		if node.color == black {
 			node.color = nodeColor(child)
 			tree.deleteCase1(node)
@@ -291,6 +459,7 @@ func (tree *Tree[K, V]) Del(key K) {
 		if node.Parent == nil && child != nil {
 			child.color = black
 		}
+		tree.augment(node.Parent)
 	}
 }
 
@@ -304,46 +473,87 @@ func (tree *Tree[K, V]) Get(key K) V {
 	return v
 }
 
+// GetOrSet returns value of the key and true if it is present, otherwise it sets the key to the value.
+func (tree *Tree[K, V]) GetOrSet(key K, value V) (V, bool) {
+	node, c := tree.search(key)
+	if c == 0 {
+		return node.Value, true
+	}
+	tree.insert(node, c, key, value)
+	return value, false
+}
+
 func (tree *Tree[K, V]) Has(key K) bool {
 	return tree.lookup(key) != nil
 }
 
-func (tree *Tree[K, V]) Set(key K, value V) {
-	var insertedNode *Node[K, V]
+// Lookup returns value of the key and whether it is present.
+func (tree *Tree[K, V]) Lookup(key K) (V, bool) {
+	var v V
+
+	node := tree.lookup(key)
+	if node == nil {
+		return v, false
+	}
+	return node.Value, true
+}
+
+// search returns node with the key and 0, or the node to attach the key to and the side, see insert.
+func (tree *Tree[K, V]) search(key K) (*Node[K, V], int) {
+	if tree.find == nil {
+		tree.initFind()
+	}
 	if tree.Root == nil {
-		tree.Root = &Node[K, V]{Key: key, Value: value, color: red}
-		insertedNode = tree.Root
+		return nil, -1
+	}
+	return tree.find(tree.Root, key)
+}
+
+func (tree *Tree[K, V]) Set(key K, value V) {
+	node, c := tree.search(key)
+	if c == 0 {
+		node.Value = value
+		tree.augment(node)
+		return
+	}
+	tree.insert(node, c, key, value)
+}
+
+// insert attaches new node to the parent on the side of the sign of c, or makes it the root if there is no parent.
+func (tree *Tree[K, V]) insert(parent *Node[K, V], c int, key K, value V) {
+	insertedNode := &Node[K, V]{Key: key, Value: value, color: red, size: 1}
+	if parent == nil {
+		tree.Root = insertedNode
 	} else {
-		node := tree.Root
-		loop := true
-		for loop {
-			if key == node.Key {
-				node.Value = value
-				return
-			} else if key < node.Key {
-				if node.Left == nil {
-					node.Left = &Node[K, V]{Key: key, Value: value, color: red}
-					insertedNode = node.Left
-					loop = false
-				} else {
-					node = node.Left
-				}
-			} else {
-				if node.Right == nil {
-					node.Right = &Node[K, V]{Key: key, Value: value, color: red}
-					insertedNode = node.Right
-					loop = false
-				} else {
-					node = node.Right
-				}
-			}
+		if c < 0 {
+			parent.Left = insertedNode
+		} else {
+			parent.Right = insertedNode
+		}
+		insertedNode.Parent = parent
+		for node := parent; node != nil; node = node.Parent {
+			node.size++
 		}
-		insertedNode.Parent = node
 	}
+	tree.augment(insertedNode)
 	tree.insertCase1(insertedNode)
 }
 
-func stringImpl[K cmp.Ordered, V any](sb *strings.Builder, node *Node[K, V], level int) {
+// Swap sets the key to the value and returns the previous value and whether there was one.
+func (tree *Tree[K, V]) Swap(key K, value V) (V, bool) {
+	var old V
+
+	node, c := tree.search(key)
+	if c != 0 {
+		tree.insert(node, c, key, value)
+		return old, false
+	}
+	old, node.Value = node.Value, value
+	tree.augment(node)
+	return old, true
+}
+
+func stringImpl[K any, V any](sb *strings.Builder, node *Node[K, V], level int) {
 	if node == nil {
 		return
 	}
@@ -360,3 +570,24 @@ func (tree *Tree[K, V]) String() string {
 	stringImpl(&sb, tree.Root, 0)
 	return sb.String()
 }
+
+// Update calls fn with value of the key and whether it is present, then sets the key to the returned value
+// or, if fn returns false, deletes it. fn must not modify the tree.
+func (tree *Tree[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	var old V
+
+	node, c := tree.search(key)
+	if c == 0 {
+		old = node.Value
+	}
+	value, keep := fn(old, c == 0)
+	switch {
+	case keep && (c == 0):
+		node.Value = value
+		tree.augment(node)
+	case keep:
+		tree.insert(node, c, key, value)
+	case c == 0:
+		tree.remove(node)
+	}
+}
@@ -1,6 +1,10 @@
 package rbtree
 
 import (
+	"cmp"
+	"maps"
+	"math/rand"
+	"slices"
 	"testing"
 
 	"constants"
@@ -118,6 +122,85 @@ func TestRBtree(t *testing.T) {
 	}
 }
 
+/* TestRBtreePointOps runs random point operations against a map. */
+func TestRBtreePointOps(t *testing.T) {
+	var rb Tree[int, int]
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := rng.Intn(constants.N / 8)
+		v, ok := m[k]
+
+		switch rng.Intn(7) {
+		case 0:
+			if got, found := rb.Lookup(k); (got != v) || (found != ok) {
+				t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+			}
+		case 1:
+			if !ok {
+				m[k] = i
+			}
+			if got, loaded := rb.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+				t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+			}
+		case 2:
+			m[k] = i
+			if got, loaded := rb.Swap(k, i); (got != v) || (loaded != ok) {
+				t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+			}
+		case 3:
+			old := v + rng.Intn(2)
+			want := ok && (old == v)
+			if want {
+				m[k] = i
+			}
+			if got := CompareAndSwap(&rb, k, old, i); got != want {
+				t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 4:
+			/* Values of the same parity are equal. */
+			old := rng.Intn(2)
+			want := ok && ((old-v)%2 == 0)
+			if want {
+				m[k] = i
+			}
+			if got := rb.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+				t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 5:
+			/* Every third update deletes the key. */
+			keep := i%3 != 0
+			if keep {
+				m[k] = i
+			} else {
+				delete(m, k)
+			}
+			rb.Update(k, func(got int, found bool) (int, bool) {
+				if (got != v) || (found != ok) {
+					t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+				}
+				return i, keep
+			})
+		case 6:
+			delete(m, k)
+			rb.Del(k)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	var n int
+	for k, v := range rb.All() {
+		if (n >= len(keys)) || (keys[n] != k) || (m[k] != v) {
+			t.Fatalf("All: unexpected pair %v: %v at %d", k, v, n)
+		}
+		n++
+	}
+	if n != len(keys) {
+		t.Errorf("All: expected %d keys, got %d", len(keys), n)
+	}
+}
+
 func benchmarkRBtreeGet(b *testing.B, g generator.Generator) {
 	b.Helper()
 
@@ -185,3 +268,133 @@ func BenchmarkRBtree(b *testing.B) {
 		})
 	}
 }
+
+type point struct {
+	X, Y int
+}
+
+func comparePoints(a, b point) int {
+	return cmp.Or(cmp.Compare(a.X, b.X), cmp.Compare(a.Y, b.Y))
+}
+
+func testRBtreeFunc(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	rb := NewFunc[point, int](comparePoints)
+
+	m := make(map[point]int)
+	for i := 0; i < constants.N; i++ {
+		// Many keys share X, so that Y decides their order.
+		x := g.Generate()
+		k := point{X: x % 100, Y: x}
+
+		m[k] = i
+		rb.Set(k, i)
+	}
+
+	check := func() {
+		t.Helper()
+
+		keys := slices.SortedFunc(maps.Keys(m), comparePoints)
+		var i int
+		for k, v := range rb.All() {
+			if (i >= len(keys)) || (k != keys[i]) || (v != m[k]) {
+				t.Errorf("All: unexpected pair %v: %v at %d", k, v, i)
+				return
+			}
+			i++
+		}
+		if i != len(keys) {
+			t.Errorf("All: expected %d keys, got %d", len(keys), i)
+		}
+	}
+	check()
+
+	for k := range m {
+		if k.Y%2 == 0 {
+			rb.Del(k)
+			if rb.Has(k) {
+				t.Errorf("expected key %v to be removed, but it's still present", k)
+			}
+			delete(m, k)
+		}
+	}
+	for k, v := range m {
+		if got := rb.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	check()
+}
+
+func TestRBtreeFunc(t *testing.T) {
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, generator := range generators {
+		generator.Reset()
+		t.Run(generator.String(), func(t *testing.T) {
+			testRBtreeFunc(t, generator)
+		})
+	}
+}
+
+func benchmarkRBtreeCompareGet(b *testing.B, rb *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		rb.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = rb.Get(g.Generate())
+	}
+}
+
+func benchmarkRBtreeCompareSet(b *testing.B, rb *Tree[int, int]) {
+	b.Helper()
+
+	g := new(generator.RandomGenerator)
+	g.Reset()
+	for i := 0; i < b.N; i++ {
+		rb.Set(g.Generate(), 0)
+	}
+}
+
+// BenchmarkRBtreeCompare compares zero value tree and New, which compare keys directly, with NewFunc, which calls comparator.
+func BenchmarkRBtreeCompare(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, *Tree[int, int])
+	}{
+		{"Get", benchmarkRBtreeCompareGet},
+		{"Set", benchmarkRBtreeCompareSet},
+	}
+
+	trees := [...]struct {
+		Name string
+		New  func() *Tree[int, int]
+	}{
+		{"Zero", func() *Tree[int, int] { return new(Tree[int, int]) }},
+		{"New", New[int, int]},
+		{"Func", func() *Tree[int, int] { return NewFunc[int, int](cmp.Compare[int]) }},
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, tree := range trees {
+				b.Run(tree.Name, func(b *testing.B) {
+					op.Func(b, tree.New())
+				})
+			}
+		})
+	}
+}
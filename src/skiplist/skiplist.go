@@ -0,0 +1,394 @@
+package skiplist
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"math/bits"
+	"math/rand/v2"
+	"strings"
+	"sync/atomic"
+)
+
+/* link is an immutable successor pointer together with deletion mark of the node it belongs to. It is replaced by CAS as a whole. */
+type link[K cmp.Ordered, V any] struct {
+	Node   *node[K, V]
+	Marked bool
+}
+
+type node[K cmp.Ordered, V any] struct {
+	Key   K
+	value atomic.Pointer[V]
+
+	/* next[i] is successor on level i. Node is logically deleted once next[0] is marked. */
+	next []atomic.Pointer[link[K, V]]
+}
+
+/*
+ * List is a lock-free skip list (Herlihy, Shavit, "The Art of Multiprocessor Programming", 14.4). All methods but Clear
+ * may be called concurrently. Del marks links of the node from the top level down, level 0 mark being the moment of
+ * deletion, then unlinks it. Traversals in Set and Del help to unlink marked nodes, Get, Has and All only skip them.
+ *
+ * Zero value is an empty list.
+ */
+type List[K cmp.Ordered, V any] struct {
+	/* head[i] is the first link on level i, nil if the level is empty. */
+	head [MaxLevel]atomic.Pointer[link[K, V]]
+}
+
+/* MaxLevel is enough for 2^MaxLevel keys with level probability of 1/2. */
+const MaxLevel = 32
+
+func (l *link[K, V]) node() *node[K, V] {
+	if l == nil {
+		return nil
+	}
+	return l.Node
+}
+
+func (l *link[K, V]) marked() bool {
+	return (l != nil) && (l.Marked)
+}
+
+func randomLevel() int {
+	return min(bits.TrailingZeros64(rand.Uint64())+1, MaxLevel)
+}
+
+/* next returns successor pointer of 'pred' on 'level', where nil 'pred' is the head. */
+func (sl *List[K, V]) next(pred *node[K, V], level int) *atomic.Pointer[link[K, V]] {
+	if pred == nil {
+		return &sl.head[level]
+	}
+	return &pred.next[level]
+}
+
+/*
+ * find fills for every level last node with smaller key, link from it that was observed and the node after it. Marked
+ * nodes met on the way are unlinked. Returns true if node with the key is on level 0.
+ */
+func (sl *List[K, V]) find(key K, preds, succs *[MaxLevel]*node[K, V], links *[MaxLevel]*link[K, V]) bool {
+retry:
+	var pred *node[K, V]
+	for level := MaxLevel - 1; level >= 0; level-- {
+		predLink := sl.next(pred, level).Load()
+		curr := predLink.node()
+		for curr != nil {
+			currLink := curr.next[level].Load()
+			if currLink.Marked {
+				newLink := &link[K, V]{Node: currLink.Node}
+				if (predLink.marked()) || (!sl.next(pred, level).CompareAndSwap(predLink, newLink)) {
+					goto retry
+				}
+				predLink = newLink
+				curr = currLink.Node
+				continue
+			}
+			if curr.Key >= key {
+				break
+			}
+			pred = curr
+			predLink = currLink
+			curr = currLink.Node
+		}
+
+		preds[level] = pred
+		succs[level] = curr
+		links[level] = predLink
+	}
+
+	return (succs[0] != nil) && (succs[0].Key == key)
+}
+
+/* lookup returns node with the key that was not deleted when it was reached, without modifying the list. */
+func (sl *List[K, V]) lookup(key K) *node[K, V] {
+	var pred *node[K, V]
+	var curr *node[K, V]
+
+	for level := MaxLevel - 1; level >= 0; level-- {
+		curr = sl.next(pred, level).Load().node()
+		for curr != nil {
+			currLink := curr.next[level].Load()
+			if currLink.Marked {
+				curr = currLink.Node
+				continue
+			}
+			if curr.Key >= key {
+				break
+			}
+			pred = curr
+			curr = currLink.Node
+		}
+	}
+
+	if (curr != nil) && (curr.Key == key) {
+		return curr
+	}
+	return nil
+}
+
+/* All returns iterator over all key-value pairs in ascending order. Concurrent modifications may or may not be seen. */
+func (sl *List[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for curr := sl.head[0].Load().node(); curr != nil; {
+			currLink := curr.next[0].Load()
+			if !currLink.Marked {
+				if !yield(curr.Key, *curr.value.Load()) {
+					return
+				}
+			}
+			curr = currLink.Node
+		}
+	}
+}
+
+/* Clear removes all keys. It must not run concurrently with other methods. */
+func (sl *List[K, V]) Clear() {
+	for level := 0; level < MaxLevel; level++ {
+		sl.head[level].Store(nil)
+	}
+}
+
+/* CompareAndSwap is CompareAndSwapFunc for comparable values, which are compared with ==. */
+func CompareAndSwap[K cmp.Ordered, V comparable](sl *List[K, V], key K, old, new V) bool {
+	return sl.CompareAndSwapFunc(key, old, new, func(a, b V) bool { return a == b })
+}
+
+/* CompareAndSwapFunc sets value of 'key' to 'new' if it is present with value 'old' according to 'equal'. */
+func (sl *List[K, V]) CompareAndSwapFunc(key K, old, new V, equal func(a, b V) bool) bool {
+	n := sl.lookup(key)
+	if n == nil {
+		return false
+	}
+
+	for {
+		p := n.value.Load()
+		if (n.next[0].Load().Marked) || (!equal(*p, old)) {
+			return false
+		}
+		if n.value.CompareAndSwap(p, &new) {
+			return true
+		}
+	}
+}
+
+func (sl *List[K, V]) Del(key K) {
+	var preds, succs [MaxLevel]*node[K, V]
+	var links [MaxLevel]*link[K, V]
+
+	if sl.find(key, &preds, &succs, &links) {
+		sl.remove(succs[0], nil, &preds, &succs, &links)
+	}
+}
+
+func (sl *List[K, V]) Get(key K) V {
+	var v V
+
+	if n := sl.lookup(key); n != nil {
+		v = *n.value.Load()
+	}
+	return v
+}
+
+/* GetOrSet returns value of 'key' and true if it is present, otherwise it sets 'key' to 'value'. */
+func (sl *List[K, V]) GetOrSet(key K, value V) (V, bool) {
+	var preds, succs [MaxLevel]*node[K, V]
+	var links [MaxLevel]*link[K, V]
+
+	for {
+		if sl.find(key, &preds, &succs, &links) {
+			n := succs[0]
+			v := *n.value.Load()
+			if !n.next[0].Load().Marked {
+				return v, true
+			}
+			continue
+		}
+		if sl.insert(key, &value, &preds, &succs, &links) {
+			return value, false
+		}
+	}
+}
+
+func (sl *List[K, V]) Has(key K) bool {
+	return sl.lookup(key) != nil
+}
+
+/*
+ * insert links new node with the key and value between nodes found by find. Returns false if the list changed
+ * meanwhile and the key must be looked up again.
+ */
+func (sl *List[K, V]) insert(key K, value *V, preds, succs *[MaxLevel]*node[K, V], links *[MaxLevel]*link[K, V]) bool {
+	top := randomLevel()
+	n := &node[K, V]{Key: key, next: make([]atomic.Pointer[link[K, V]], top)}
+	n.value.Store(value)
+	for level := 0; level < top; level++ {
+		n.next[level].Store(&link[K, V]{Node: succs[level]})
+	}
+
+	/* Node becomes part of the list once it is on level 0. */
+	if !sl.next(preds[0], 0).CompareAndSwap(links[0], &link[K, V]{Node: n}) {
+		return false
+	}
+
+	for level := 1; level < top; level++ {
+		for {
+			l := n.next[level].Load()
+			if l.Marked {
+				/* Node is being deleted, no use linking it further. */
+				return true
+			}
+			if (l.Node == succs[level]) || (n.next[level].CompareAndSwap(l, &link[K, V]{Node: succs[level]})) {
+				if sl.next(preds[level], level).CompareAndSwap(links[level], &link[K, V]{Node: n}) {
+					break
+				}
+			}
+			sl.find(key, preds, succs, links)
+			if succs[0] != n {
+				/* Node was deleted and unlinked. */
+				return true
+			}
+		}
+	}
+	return true
+}
+
+/* Lookup returns value of 'key' and whether it is present. */
+func (sl *List[K, V]) Lookup(key K) (V, bool) {
+	var v V
+
+	n := sl.lookup(key)
+	if n == nil {
+		return v, false
+	}
+	return *n.value.Load(), true
+}
+
+/*
+ * remove marks links of 'victim' from the top level down and unlinks it. If 'value' is not nil, the node is marked only
+ * while it holds that value. Returns false if the node was deleted by someone else or its value changed.
+ */
+func (sl *List[K, V]) remove(victim *node[K, V], value *V, preds, succs *[MaxLevel]*node[K, V], links *[MaxLevel]*link[K, V]) bool {
+	if (value != nil) && (victim.value.Load() != value) {
+		return false
+	}
+	for level := len(victim.next) - 1; level >= 1; level-- {
+		for {
+			l := victim.next[level].Load()
+			if (l.Marked) || (victim.next[level].CompareAndSwap(l, &link[K, V]{Node: l.Node, Marked: true})) {
+				break
+			}
+		}
+	}
+	for {
+		l := victim.next[0].Load()
+		if (l.Marked) || ((value != nil) && (victim.value.Load() != value)) {
+			return false
+		}
+		if victim.next[0].CompareAndSwap(l, &link[K, V]{Node: l.Node, Marked: true}) {
+			sl.find(victim.Key, preds, succs, links)
+			return true
+		}
+	}
+}
+
+func (sl *List[K, V]) Set(key K, value V) {
+	var preds, succs [MaxLevel]*node[K, V]
+	var links [MaxLevel]*link[K, V]
+
+	for {
+		if sl.find(key, &preds, &succs, &links) {
+			n := succs[0]
+			n.value.Store(&value)
+			if !n.next[0].Load().Marked {
+				return
+			}
+			/* Node was deleted meanwhile, so the value must go to a new one. */
+			continue
+		}
+		if sl.insert(key, &value, &preds, &succs, &links) {
+			return
+		}
+	}
+}
+
+/* Swap sets 'key' to 'value' and returns the previous value and whether there was one. */
+func (sl *List[K, V]) Swap(key K, value V) (V, bool) {
+	var preds, succs [MaxLevel]*node[K, V]
+	var links [MaxLevel]*link[K, V]
+	var old V
+
+	for {
+		if sl.find(key, &preds, &succs, &links) {
+			n := succs[0]
+			p := n.value.Swap(&value)
+			if !n.next[0].Load().Marked {
+				return *p, true
+			}
+			continue
+		}
+		if sl.insert(key, &value, &preds, &succs, &links) {
+			return old, false
+		}
+	}
+}
+
+func (sl *List[K, V]) String() string {
+	var sb strings.Builder
+
+	for level := MaxLevel - 1; level >= 0; level-- {
+		curr := sl.head[level].Load().node()
+		if (curr == nil) && (level > 0) {
+			continue
+		}
+
+		fmt.Fprintf(&sb, "%2d:", level)
+		for curr != nil {
+			currLink := curr.next[level].Load()
+			if !currLink.Marked {
+				fmt.Fprintf(&sb, " %v", curr.Key)
+			}
+			curr = currLink.Node
+		}
+		sb.WriteRune('\n')
+	}
+
+	return sb.String()
+}
+
+/*
+ * Update calls 'fn' with value of 'key' and whether it is present, then sets 'key' to the returned value or, if 'fn'
+ * returns false, deletes it. The value is replaced only if it did not change since 'fn' was called, otherwise 'fn' is
+ * called again, so it may run several times. A value set concurrently between the check and the deletion is deleted
+ * too.
+ */
+func (sl *List[K, V]) Update(key K, fn func(V, bool) (V, bool)) {
+	var preds, succs [MaxLevel]*node[K, V]
+	var links [MaxLevel]*link[K, V]
+	var zero V
+
+	for {
+		if !sl.find(key, &preds, &succs, &links) {
+			value, keep := fn(zero, false)
+			if (!keep) || (sl.insert(key, &value, &preds, &succs, &links)) {
+				return
+			}
+			continue
+		}
+
+		n := succs[0]
+		p := n.value.Load()
+		if n.next[0].Load().Marked {
+			continue
+		}
+		value, keep := fn(*p, true)
+		if keep {
+			if n.value.CompareAndSwap(p, &value) {
+				return
+			}
+			continue
+		}
+		if sl.remove(n, p, &preds, &succs, &links) {
+			return
+		}
+	}
+}
@@ -0,0 +1,513 @@
+package skiplist
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"constants"
+	"generator"
+	"rbtree"
+)
+
+func testSkipListGet(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var sl List[int, int]
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		m[k] = v
+		sl.Set(k, v)
+	}
+
+	for k, v := range m {
+		if got := sl.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func testSkipListDel(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var sl List[int, int]
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		sl.Set(k, 0)
+	}
+
+	for k := range m {
+		sl.Del(k)
+		if sl.Has(k) {
+			t.Errorf("expected key %v to be removed, but it's still present", k)
+		}
+	}
+	for level := 0; level < MaxLevel; level++ {
+		if n := sl.head[level].Load().node(); n != nil {
+			t.Errorf("expected level %d to be empty, found key %v", level, n.Key)
+		}
+	}
+}
+
+func testSkipListHas(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var sl List[int, int]
+
+	m := make(map[int]struct{})
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = struct{}{}
+		sl.Set(k, 0)
+	}
+
+	for k := range m {
+		if !sl.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+	}
+}
+
+func testSkipListSet(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var sl List[int, int]
+
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+		v := g.Generate()
+
+		sl.Set(k, v)
+		if !sl.Has(k) {
+			t.Errorf("expected to found key %v, found nothing", k)
+		}
+		if got := sl.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+func testSkipListAll(t *testing.T, g generator.Generator) {
+	t.Helper()
+
+	var sl List[int, int]
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := g.Generate()
+
+		m[k] = i
+		sl.Set(k, i)
+		if i%3 == 2 {
+			delete(m, k)
+			sl.Del(k)
+		}
+	}
+
+	expected := make([]int, 0, len(m))
+	for k := range m {
+		expected = append(expected, k)
+	}
+	slices.Sort(expected)
+
+	var got []int
+	for k, v := range sl.All() {
+		if v != m[k] {
+			t.Errorf("expected value %v for key %v, got %v", m[k], k, v)
+		}
+		got = append(got, k)
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	tests := [...]struct {
+		Name string
+		Func func(*testing.T, generator.Generator)
+	}{
+		{"Get", testSkipListGet},
+		{"Del", testSkipListDel},
+		{"Has", testSkipListHas},
+		{"Set", testSkipListSet},
+		{"All", testSkipListAll},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			for _, generator := range generators {
+				generator.Reset()
+				t.Run(generator.String(), func(t *testing.T) {
+					test.Func(t, generator)
+				})
+			}
+		})
+	}
+}
+
+/* TestSkipListPointOps runs random point operations against a map. */
+func TestSkipListPointOps(t *testing.T) {
+	var sl List[int, int]
+
+	rng := rand.New(rand.NewSource(constants.Seed))
+	m := make(map[int]int)
+	for i := 0; i < constants.N; i++ {
+		k := rng.Intn(constants.N / 8)
+		v, ok := m[k]
+
+		switch rng.Intn(7) {
+		case 0:
+			if got, found := sl.Lookup(k); (got != v) || (found != ok) {
+				t.Fatalf("Lookup(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+			}
+		case 1:
+			if !ok {
+				m[k] = i
+			}
+			if got, loaded := sl.GetOrSet(k, i); (got != m[k]) || (loaded != ok) {
+				t.Fatalf("GetOrSet(%v): expected %v, %v, got %v, %v", k, m[k], ok, got, loaded)
+			}
+		case 2:
+			m[k] = i
+			if got, loaded := sl.Swap(k, i); (got != v) || (loaded != ok) {
+				t.Fatalf("Swap(%v): expected %v, %v, got %v, %v", k, v, ok, got, loaded)
+			}
+		case 3:
+			old := v + rng.Intn(2)
+			want := ok && (old == v)
+			if want {
+				m[k] = i
+			}
+			if got := CompareAndSwap(&sl, k, old, i); got != want {
+				t.Fatalf("CompareAndSwap(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 4:
+			/* Values of the same parity are equal. */
+			old := rng.Intn(2)
+			want := ok && ((old-v)%2 == 0)
+			if want {
+				m[k] = i
+			}
+			if got := sl.CompareAndSwapFunc(k, old, i, func(a, b int) bool { return (a-b)%2 == 0 }); got != want {
+				t.Fatalf("CompareAndSwapFunc(%v, %v): expected %v, got %v", k, old, want, got)
+			}
+		case 5:
+			/* Every third update deletes the key. */
+			keep := i%3 != 0
+			if keep {
+				m[k] = i
+			} else {
+				delete(m, k)
+			}
+			sl.Update(k, func(got int, found bool) (int, bool) {
+				if (got != v) || (found != ok) {
+					t.Errorf("Update(%v): expected %v, %v, got %v, %v", k, v, ok, got, found)
+				}
+				return i, keep
+			})
+		case 6:
+			delete(m, k)
+			sl.Del(k)
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(m))
+	var n int
+	for k, v := range sl.All() {
+		if (n >= len(keys)) || (keys[n] != k) || (m[k] != v) {
+			t.Fatalf("All: unexpected pair %v: %v at %d", k, v, n)
+		}
+		n++
+	}
+	if n != len(keys) {
+		t.Errorf("All: expected %d keys, got %d", len(keys), n)
+	}
+}
+
+/* TestSkipListConcurrent runs workers that own disjoint keys together with workers that fight over the same small set of keys. */
+func TestSkipListConcurrent(t *testing.T) {
+	const (
+		Workers = 8
+		Rounds  = 4
+		Shared  = 16
+	)
+
+	var sl List[int, int]
+	var wg sync.WaitGroup
+
+	for w := 0; w < Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for r := 0; r < Rounds; r++ {
+				for i := w; i < constants.N; i += Workers {
+					sl.Set(i, r)
+					if got := sl.Get(i); got != r {
+						t.Errorf("expected value %v for key %v, got %v", r, i, got)
+					}
+					sl.Set(-1-i%Shared, w)
+				}
+				for i := w; i < constants.N; i += 2 * Workers {
+					sl.Del(i)
+					if sl.Has(i) {
+						t.Errorf("expected key %v to be removed, but it's still present", i)
+					}
+					sl.Del(-1 - i%Shared)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	var all, got []int
+	for k := range sl.All() {
+		all = append(all, k)
+		if k >= 0 {
+			got = append(got, k)
+		}
+	}
+	if !slices.IsSorted(all) {
+		t.Errorf("keys are not sorted: %v", all)
+	}
+
+	/* Keys of every worker that were deleted on each round. */
+	var expected []int
+	for i := 0; i < constants.N; i++ {
+		if i%(2*Workers) >= Workers {
+			expected = append(expected, i)
+		}
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+}
+
+/* TestSkipListConcurrentUpdate checks that increments of the same keys by concurrent Update calls are not lost. */
+func TestSkipListConcurrentUpdate(t *testing.T) {
+	const (
+		Workers = 8
+		Shared  = 16
+	)
+
+	var sl List[int, int]
+	var wg sync.WaitGroup
+
+	inc := func(v int, ok bool) (int, bool) { return v + 1, true }
+	for w := 0; w < Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < constants.N; i++ {
+				sl.Update(i%Shared, inc)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for k := 0; k < Shared; k++ {
+		expected := Workers * (constants.N / Shared)
+		if k < constants.N%Shared {
+			expected += Workers
+		}
+		if got, ok := sl.Lookup(k); (!ok) || (got != expected) {
+			t.Errorf("expected value %v for key %v, got %v, %v", expected, k, got, ok)
+		}
+	}
+}
+
+func benchmarkSkipListGet(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	var sl List[int, int]
+	for i := 0; i < b.N; i++ {
+		sl.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = sl.Get(g.Generate())
+	}
+}
+
+func benchmarkSkipListDel(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	var sl List[int, int]
+	for i := 0; i < b.N; i++ {
+		sl.Set(g.Generate(), 0)
+	}
+
+	g.Reset()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl.Del(g.Generate())
+	}
+}
+
+func benchmarkSkipListSet(b *testing.B, g generator.Generator) {
+	b.Helper()
+
+	var sl List[int, int]
+	for i := 0; i < b.N; i++ {
+		sl.Set(g.Generate(), 0)
+	}
+}
+
+func BenchmarkSkipList(b *testing.B) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.B, generator.Generator)
+	}{
+		{"Get", benchmarkSkipListGet},
+		{"Del", benchmarkSkipListDel},
+		{"Set", benchmarkSkipListSet},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		b.Run(op.Name, func(b *testing.B) {
+			for _, generator := range generators {
+				generator.Reset()
+				b.Run(generator.String(), func(b *testing.B) {
+					op.Func(b, generator)
+				})
+			}
+		})
+	}
+}
+
+/* Tree is the part of the Tree interface from main.go used by parallel benchmarks. */
+type Tree interface {
+	Del(int)
+	Get(int) int
+	Set(int, int)
+}
+
+/* LockedRBtree is the baseline for parallel benchmarks: single-threaded rbtree.Tree behind a mutex. */
+type LockedRBtree struct {
+	sync.RWMutex
+	Tree rbtree.Tree[int, int]
+}
+
+func (t *LockedRBtree) Del(key int) {
+	t.Lock()
+	t.Tree.Del(key)
+	t.Unlock()
+}
+
+func (t *LockedRBtree) Get(key int) int {
+	t.RLock()
+	defer t.RUnlock()
+	return t.Tree.Get(key)
+}
+
+func (t *LockedRBtree) Set(key int, value int) {
+	t.Lock()
+	t.Tree.Set(key, value)
+	t.Unlock()
+}
+
+/* Stride separates keys of different goroutines: goroutine 'id' uses keys equal to 'id' modulo Stride. */
+const Stride = 1024
+
+/*
+ * benchmarkParallel runs operations on 'tree' from GOMAXPROCS goroutines, each with its own generator made by 'newGenerator'.
+ * 'mix' is a number of Set and Del operations per 16 operations, the rest are Get.
+ */
+func benchmarkParallel(b *testing.B, tree Tree, newGenerator func() generator.Generator, mix int) {
+	b.Helper()
+
+	var ids atomic.Int64
+
+	g := newGenerator()
+	g.Reset()
+	for i := 0; i < constants.N; i++ {
+		key := g.Generate() * Stride
+		for id := 0; id < runtime.GOMAXPROCS(0); id++ {
+			tree.Set(key+id, 0)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := int(ids.Add(1)-1) % Stride
+		g := newGenerator()
+		g.Reset()
+
+		for i := 0; pb.Next(); i++ {
+			key := g.Generate()*Stride + id
+			switch {
+			case i%16 >= mix:
+				_ = tree.Get(key)
+			case i%2 == 0:
+				tree.Set(key, i)
+			default:
+				tree.Del(key)
+			}
+		}
+	})
+}
+
+func BenchmarkSkipListParallel(b *testing.B) {
+	trees := [...]struct {
+		Name string
+		New  func() Tree
+	}{
+		{"SkipList", func() Tree { return new(List[int, int]) }},
+		{"RBtree", func() Tree { return new(LockedRBtree) }},
+	}
+
+	generators := [...]func() generator.Generator{
+		func() generator.Generator { return new(generator.RandomGenerator) },
+		func() generator.Generator { return new(generator.AscendingGenerator) },
+		func() generator.Generator { return new(generator.DescendingGenerator) },
+		func() generator.Generator { return new(generator.SawtoothGenerator) },
+	}
+
+	mixes := [...]int{0, 2, 8, 16}
+
+	for _, tree := range trees {
+		b.Run(tree.Name, func(b *testing.B) {
+			for _, newGenerator := range generators {
+				b.Run(newGenerator().String(), func(b *testing.B) {
+					for _, mix := range mixes {
+						b.Run(fmt.Sprintf("Writes-%d/16", mix), func(b *testing.B) {
+							benchmarkParallel(b, tree.New(), newGenerator, mix)
+						})
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package txn
+
+import (
+	"errors"
+)
+
+type Tree[K comparable, V any] interface {
+	Clear()
+	Del(K)
+	Get(K) V
+	Has(K) bool
+	Set(K, V)
+}
+
+/*
+ * Batcher is implemented by trees that can make a group of modifications atomic on their own, like bplus.Tree with a
+ * write-ahead log. AbortBatch undoes modifications of the batch whose CommitBatch has failed.
+ */
+type Batcher interface {
+	AbortBatch() error
+	BeginBatch()
+	CommitBatch() error
+}
+
+type write[V any] struct {
+	Value V
+	Del   bool
+}
+
+/* Transaction buffers modifications of a tree, so they become visible all at once on Commit or not at all. */
+type Transaction[K comparable, V any] struct {
+	Tree Tree[K, V]
+
+	writes map[K]write[V]
+	keys   []K
+	active bool
+}
+
+var ErrNotActive = errors.New("transaction is not active")
+
+func Begin[K comparable, V any](t Tree[K, V]) *Transaction[K, V] {
+	tx := &Transaction[K, V]{Tree: t}
+	tx.Begin()
+	return tx
+}
+
+func (tx *Transaction[K, V]) record(key K, w write[V]) {
+	if _, ok := tx.writes[key]; !ok {
+		tx.keys = append(tx.keys, key)
+	}
+	tx.writes[key] = w
+}
+
+func (tx *Transaction[K, V]) reset() {
+	clear(tx.writes)
+	tx.keys = tx.keys[:0]
+}
+
+/* Begin starts new transaction, discarding modifications of the previous one, if it was not finished. */
+func (tx *Transaction[K, V]) Begin() {
+	if tx.writes == nil {
+		tx.writes = make(map[K]write[V])
+	}
+	tx.reset()
+	tx.active = true
+}
+
+/*
+ * Commit applies all modifications in order they were first made. If Batcher reports failure, it undoes modifications
+ * that were already applied, and Commit returns errors of both.
+ */
+func (tx *Transaction[K, V]) Commit() error {
+	if !tx.active {
+		return ErrNotActive
+	}
+	tx.active = false
+	defer tx.reset()
+
+	b, batch := tx.Tree.(Batcher)
+	if batch {
+		b.BeginBatch()
+	}
+
+	for _, key := range tx.keys {
+		w := tx.writes[key]
+		if w.Del {
+			tx.Tree.Del(key)
+		} else {
+			tx.Tree.Set(key, w.Value)
+		}
+	}
+
+	if batch {
+		if err := b.CommitBatch(); err != nil {
+			return errors.Join(err, b.AbortBatch())
+		}
+	}
+
+	return nil
+}
+
+func (tx *Transaction[K, V]) Del(key K) {
+	tx.record(key, write[V]{Del: true})
+}
+
+func (tx *Transaction[K, V]) Get(key K) V {
+	if w, ok := tx.writes[key]; ok {
+		var zero V
+		if w.Del {
+			return zero
+		}
+		return w.Value
+	}
+	return tx.Tree.Get(key)
+}
+
+func (tx *Transaction[K, V]) Has(key K) bool {
+	if w, ok := tx.writes[key]; ok {
+		return !w.Del
+	}
+	return tx.Tree.Has(key)
+}
+
+/* Rollback discards all modifications, leaving the tree untouched. */
+func (tx *Transaction[K, V]) Rollback() error {
+	if !tx.active {
+		return ErrNotActive
+	}
+	tx.active = false
+
+	tx.reset()
+	return nil
+}
+
+func (tx *Transaction[K, V]) Set(key K, value V) {
+	tx.record(key, write[V]{Value: value})
+}
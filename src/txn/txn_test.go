@@ -0,0 +1,218 @@
+package txn
+
+import (
+	"path/filepath"
+	"testing"
+
+	"bplus"
+	"btree"
+	"constants"
+	"generator"
+	"rbtree"
+	"wal"
+)
+
+func testTxnCommit(t *testing.T, tree Tree[int, int], g generator.Generator) {
+	t.Helper()
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N/2; i++ {
+		k := g.Generate()
+		m[k] = i
+		tree.Set(k, i)
+	}
+
+	tx := Begin(tree)
+	i := 0
+	for k := range m {
+		if i%2 == 0 {
+			tx.Del(k)
+			if tx.Has(k) {
+				t.Errorf("expected key %v to be removed in transaction, but it's still present", k)
+			}
+			if !tree.Has(k) {
+				t.Errorf("expected key %v to stay in tree before commit", k)
+			}
+			delete(m, k)
+		} else {
+			tx.Set(k, -i)
+			if got := tx.Get(k); got != -i {
+				t.Errorf("expected value %v in transaction, got %v", -i, got)
+			}
+			if got := tree.Get(k); got == -i {
+				t.Errorf("expected value %v to be invisible before commit", -i)
+			}
+			m[k] = -i
+		}
+		i++
+	}
+	for i := 0; i < constants.N/2; i++ {
+		k := g.Generate()
+		tx.Set(k, i)
+		if tree.Has(k) {
+			t.Errorf("expected key %v to be invisible before commit", k)
+		}
+		m[k] = i
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit transaction: %v", err)
+	}
+	for k, v := range m {
+		if got := tree.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+	if err := tx.Commit(); err != ErrNotActive {
+		t.Errorf("expected error %v, got %v", ErrNotActive, err)
+	}
+}
+
+func testTxnRollback(t *testing.T, tree Tree[int, int], g generator.Generator) {
+	t.Helper()
+
+	m := make(map[int]int)
+	for i := 0; i < constants.N/2; i++ {
+		k := g.Generate()
+		m[k] = i
+		tree.Set(k, i)
+	}
+
+	tx := Begin(tree)
+	for k := range m {
+		tx.Del(k)
+	}
+	for i := 0; i < constants.N/2; i++ {
+		tx.Set(g.Generate(), i)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back transaction: %v", err)
+	}
+
+	g.Reset()
+	for i := 0; i < constants.N/2; i++ {
+		g.Generate()
+	}
+	for i := 0; i < constants.N/2; i++ {
+		if k := g.Generate(); tree.Has(k) {
+			if _, ok := m[k]; !ok {
+				t.Errorf("expected key %v to be absent after rollback", k)
+			}
+		}
+	}
+	for k, v := range m {
+		if got := tree.Get(k); got != v {
+			t.Errorf("expected value %v, got %v", v, got)
+		}
+	}
+}
+
+/* failingTree loses its log right before the batch is committed, so all modifications of the batch are applied, but not committed. */
+type failingTree struct {
+	*bplus.Tree[int, int]
+}
+
+func (ft failingTree) CommitBatch() error {
+	ft.Log.Close()
+	return ft.Tree.CommitBatch()
+}
+
+func TestTxnCommitFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "txn.log")
+	l, err := wal.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+
+	bt := new(bplus.Tree[int, int])
+	bt.Log = l
+	for i := 0; i < constants.N; i += 2 {
+		bt.Set(i, i)
+	}
+
+	tx := Begin[int, int](failingTree{bt})
+	for i := 0; i < constants.N; i++ {
+		if i%4 == 0 {
+			tx.Del(i)
+		} else {
+			tx.Set(i, -i)
+		}
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatalf("expected commit to fail")
+	}
+
+	l, err = wal.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	defer l.Close()
+
+	recovered := new(bplus.Tree[int, int])
+	recovered.Log = l
+	if err := recovered.Recover(); err != nil {
+		t.Fatalf("failed to recover tree: %v", err)
+	}
+
+	/* Undo is made in memory, while recovery undoes uncommitted batch on its own. */
+	for _, tree := range [...]*bplus.Tree[int, int]{bt, recovered} {
+		for i := 0; i < constants.N; i++ {
+			v, ok := tree.Lookup(i)
+			if ok != (i%2 == 0) {
+				t.Errorf("expected key %v to be present: %v, got %v", i, i%2 == 0, ok)
+			}
+			if ok && (v != i) {
+				t.Errorf("expected value %v, got %v", i, v)
+			}
+		}
+	}
+}
+
+func TestTxn(t *testing.T) {
+	ops := [...]struct {
+		Name string
+		Func func(*testing.T, Tree[int, int], generator.Generator)
+	}{
+		{"Commit", testTxnCommit},
+		{"Rollback", testTxnRollback},
+	}
+
+	trees := [...]struct {
+		Name string
+		New  func() Tree[int, int]
+	}{
+		{"RBtree", func() Tree[int, int] { return new(rbtree.Tree[int, int]) }},
+		{"Btree", func() Tree[int, int] { return new(btree.Tree[int, int]) }},
+		{"Bplus", func() Tree[int, int] { return new(bplus.Tree[int, int]) }},
+	}
+
+	generators := [...]generator.Generator{
+		new(generator.RandomGenerator),
+		new(generator.AscendingGenerator),
+		new(generator.DescendingGenerator),
+		new(generator.SawtoothGenerator),
+	}
+
+	for _, op := range ops {
+		t.Run(op.Name, func(t *testing.T) {
+			for _, tree := range trees {
+				t.Run(tree.Name, func(t *testing.T) {
+					for _, generator := range generators {
+						t.Run(generator.String(), func(t *testing.T) {
+							generator.Reset()
+							op.Func(t, tree.New(), generator)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+var (
+	_ Tree[int, int] = &rbtree.Tree[int, int]{}
+	_ Tree[int, int] = &btree.Tree[int, int]{}
+	_ Tree[int, int] = &bplus.Tree[int, int]{}
+
+	_ Batcher = &bplus.Tree[int, int]{}
+)
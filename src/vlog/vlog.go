@@ -0,0 +1,372 @@
+package vlog
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/* Pointer locates a record in the log. Zero Pointer refers to nothing. */
+type Pointer struct {
+	Segment uint32
+	Offset  uint64
+	Size    uint32
+}
+
+/* PointerCodec lets trees of pointers be stored in pages, see codec.Codec. */
+type PointerCodec struct{}
+
+func (PointerCodec) Append(buf []byte, p Pointer) []byte {
+	buf = binary.AppendUvarint(buf, uint64(p.Segment))
+	buf = binary.AppendUvarint(buf, uint64(p.Offset))
+	return binary.AppendUvarint(buf, uint64(p.Size))
+}
+
+func (PointerCodec) Decode(buf []byte) (Pointer, int, error) {
+	var fields [3]uint64
+
+	var offset int
+	for i := range fields {
+		x, n := binary.Uvarint(buf[offset:])
+		if (n <= 0) || ((i != 1) && (x > math.MaxUint32)) {
+			return Pointer{}, 0, ErrBadPointer
+		}
+		fields[i] = x
+		offset += n
+	}
+	return Pointer{Segment: uint32(fields[0]), Offset: fields[1], Size: uint32(fields[2])}, offset, nil
+}
+
+/*
+ * Log is an append-only value log (Lu et al., "WiscKey: Separating Keys from Values in SSD-conscious Storage"). It is
+ * a directory of segment files, new records go to the last one. Every record keeps the key next to the value, so that
+ * garbage collector can ask the index whether the record is still referenced, see Rewrite.
+ */
+type Log struct {
+	Dir string
+
+	/* Size after which the last segment is closed and a new one is started. */
+	SegmentSize int64
+
+	segments []segment
+	buf      []byte
+}
+
+type segment struct {
+	ID   uint32
+	File *os.File
+	Size int64
+}
+
+/*
+ * Segment file is a sequence of records, all integers are little-endian:
+ *	[payload size:4][crc32 of payload:4][key size:uvarint][key][value]
+ */
+const (
+	DefaultSegmentSize = 64 << 20
+
+	recordHeaderSize = 8
+	segmentExt       = ".vlog"
+)
+
+var (
+	ErrBadPointer = errors.New("invalid value pointer")
+	ErrCorrupted  = errors.New("value log record is corrupted")
+	ErrTooLarge   = errors.New("value log record is too large")
+)
+
+func segmentPath(dir string, id uint32) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d%s", id, segmentExt))
+}
+
+/* Open opens or creates value log in 'dir'. Torn tail of the last segment is cut off. */
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create value log directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read value log directory: %w", err)
+	}
+
+	l := &Log{Dir: dir, SegmentSize: DefaultSegmentSize}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 32)
+		if (err != nil) || (id == 0) {
+			continue
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, e.Name()), os.O_RDWR, 0644)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to open value log segment: %w", err)
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			l.Close()
+			return nil, fmt.Errorf("failed to stat value log segment: %w", err)
+		}
+		l.segments = append(l.segments, segment{ID: uint32(id), File: f, Size: fi.Size()})
+	}
+	slices.SortFunc(l.segments, func(a, b segment) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if len(l.segments) == 0 {
+		if err := l.roll(); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	/* Find the end of the last complete record and cut off torn tail, if any. */
+	last := &l.segments[len(l.segments)-1]
+	end, err := l.scan(last, func([]byte, []byte, Pointer) error { return nil })
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	if end != last.Size {
+		if err := last.File.Truncate(end); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("failed to truncate torn value log tail: %w", err)
+		}
+		last.Size = end
+	}
+
+	return l, nil
+}
+
+/* roll starts a new segment after syncing the current one. */
+func (l *Log) roll() error {
+	var id uint32 = 1
+	if len(l.segments) > 0 {
+		if err := l.Sync(); err != nil {
+			return err
+		}
+		id = l.segments[len(l.segments)-1].ID + 1
+	}
+
+	f, err := os.OpenFile(segmentPath(l.Dir, id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create value log segment: %w", err)
+	}
+	l.segments = append(l.segments, segment{ID: id, File: f})
+	return nil
+}
+
+/* find returns index of segment 'id' or -1. */
+func (l *Log) find(id uint32) int {
+	i, ok := slices.BinarySearchFunc(l.segments, id, func(s segment, id uint32) int {
+		return cmp.Compare(s.ID, id)
+	})
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+func decodeRecord(payload []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(payload)
+	if (n <= 0) || (uint64(len(payload)-n) < l) {
+		return nil, nil, ErrCorrupted
+	}
+	return payload[n : n+int(l)], payload[n+int(l):], nil
+}
+
+/* scan calls fn for every complete record of the segment. Returns offset right after the last one. */
+func (l *Log) scan(s *segment, fn func(key, value []byte, p Pointer) error) (int64, error) {
+	var hdr [recordHeaderSize]byte
+	var payload []byte
+
+	var offset int64
+	for offset+recordHeaderSize <= s.Size {
+		if _, err := s.File.ReadAt(hdr[:], offset); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, fmt.Errorf("failed to read value log record: %w", err)
+		}
+
+		size := int64(binary.LittleEndian.Uint32(hdr[:]))
+		if offset+recordHeaderSize+size > s.Size {
+			break
+		}
+		if int64(cap(payload)) < size {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := s.File.ReadAt(payload, offset+recordHeaderSize); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, fmt.Errorf("failed to read value log record: %w", err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(hdr[4:]) {
+			break
+		}
+		key, value, err := decodeRecord(payload)
+		if err != nil {
+			break
+		}
+
+		p := Pointer{Segment: s.ID, Offset: uint64(offset), Size: uint32(recordHeaderSize + size)}
+		if err := fn(key, value, p); err != nil {
+			return 0, err
+		}
+		offset += recordHeaderSize + size
+	}
+
+	return offset, nil
+}
+
+/* Append writes record of 'key' and 'value' to the log. Record is durable only after Sync. Records are limited to 4 GiB. */
+func (l *Log) Append(key, value []byte) (Pointer, error) {
+	var keySize [binary.MaxVarintLen64]byte
+	size := uint64(recordHeaderSize+binary.PutUvarint(keySize[:], uint64(len(key)))) + uint64(len(key)) + uint64(len(value))
+	if size > math.MaxUint32 {
+		return Pointer{}, fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+
+	s := &l.segments[len(l.segments)-1]
+	if (s.Size > 0) && (s.Size >= l.SegmentSize) {
+		if err := l.roll(); err != nil {
+			return Pointer{}, err
+		}
+		s = &l.segments[len(l.segments)-1]
+	}
+
+	l.buf = append(l.buf[:0], make([]byte, recordHeaderSize)...)
+	l.buf = binary.AppendUvarint(l.buf, uint64(len(key)))
+	l.buf = append(l.buf, key...)
+	l.buf = append(l.buf, value...)
+
+	payload := l.buf[recordHeaderSize:]
+	binary.LittleEndian.PutUint32(l.buf, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(l.buf[4:], crc32.ChecksumIEEE(payload))
+	if _, err := s.File.WriteAt(l.buf, s.Size); err != nil {
+		return Pointer{}, fmt.Errorf("failed to append value log record: %w", err)
+	}
+
+	p := Pointer{Segment: s.ID, Offset: uint64(s.Size), Size: uint32(len(l.buf))}
+	s.Size += int64(len(l.buf))
+	return p, nil
+}
+
+func (l *Log) Close() error {
+	var err error
+	if len(l.segments) > 0 {
+		err = l.Sync()
+	}
+	for _, s := range l.segments {
+		if e := s.File.Close(); err == nil {
+			err = e
+		}
+	}
+	l.segments = nil
+	return err
+}
+
+/* Read returns value of the record 'p' points to. */
+func (l *Log) Read(p Pointer) ([]byte, error) {
+	i := l.find(p.Segment)
+	if (i < 0) || (p.Size < recordHeaderSize) || (p.Offset+uint64(p.Size) > uint64(l.segments[i].Size)) {
+		return nil, fmt.Errorf("%w: %+v", ErrBadPointer, p)
+	}
+	s := &l.segments[i]
+
+	buf := make([]byte, p.Size)
+	if _, err := s.File.ReadAt(buf, int64(p.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read value log record: %w", err)
+	}
+	payload := buf[recordHeaderSize:]
+	if (binary.LittleEndian.Uint32(buf) != uint32(len(payload))) || (crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[4:])) {
+		return nil, fmt.Errorf("%w: %+v", ErrCorrupted, p)
+	}
+	_, value, err := decodeRecord(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %+v", err, p)
+	}
+	return value, nil
+}
+
+/* Remove deletes segment, which Rewrite has emptied. */
+func (l *Log) Remove(id uint32) error {
+	i := l.find(id)
+	if (i < 0) || (i == len(l.segments)-1) {
+		return fmt.Errorf("%w: segment %d", ErrBadPointer, id)
+	}
+
+	l.segments[i].File.Close()
+	if err := os.Remove(segmentPath(l.Dir, id)); err != nil {
+		return fmt.Errorf("failed to remove value log segment: %w", err)
+	}
+	l.segments = slices.Delete(l.segments, i, i+1)
+	return nil
+}
+
+/*
+ * Rewrite is the first step of garbage collection. It appends records of the oldest segment for which 'live' returns
+ * true to the end of the log and reports their new pointers to 'moved'. Returns ID of the segment, which may be
+ * removed with Remove once the index durably refers to the new pointers. If the oldest segment is the last one, a new
+ * segment is started first. Key and value are valid only until the callbacks return.
+ */
+func (l *Log) Rewrite(live func(key []byte, p Pointer) bool, moved func(key []byte, p Pointer) error) (uint32, error) {
+	if len(l.segments) == 1 {
+		if err := l.roll(); err != nil {
+			return 0, err
+		}
+	}
+
+	/* Appends may move segments around, so the oldest one is copied. */
+	oldest := l.segments[0]
+	if _, err := l.scan(&oldest, func(key, value []byte, p Pointer) error {
+		if !live(key, p) {
+			return nil
+		}
+		np, err := l.Append(key, value)
+		if err != nil {
+			return err
+		}
+		return moved(key, np)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to rewrite value log segment: %w", err)
+	}
+
+	return oldest.ID, l.Sync()
+}
+
+/* Segments returns number of segment files. */
+func (l *Log) Segments() int {
+	return len(l.segments)
+}
+
+/* Size returns total size of segment files, including records which are no longer referenced. */
+func (l *Log) Size() int64 {
+	var size int64
+	for _, s := range l.segments {
+		size += s.Size
+	}
+	return size
+}
+
+func (l *Log) Sync() error {
+	if err := l.segments[len(l.segments)-1].File.Sync(); err != nil {
+		return fmt.Errorf("failed to sync value log segment: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package vlog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func openLog(t *testing.T, dir string) *Log {
+	t.Helper()
+
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	return l
+}
+
+func appendRecord(t *testing.T, l *Log, key, value string) Pointer {
+	t.Helper()
+
+	p, err := l.Append([]byte(key), []byte(value))
+	if err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+	return p
+}
+
+func checkRecord(t *testing.T, l *Log, p Pointer, value string) {
+	t.Helper()
+
+	got, err := l.Read(p)
+	if err != nil {
+		t.Fatalf("failed to read %+v: %v", p, err)
+	}
+	if string(got) != value {
+		t.Errorf("Read(%+v): expected %q, got %q", p, value, got)
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openLog(t, dir)
+	l.SegmentSize = 256
+
+	m := make(map[string]Pointer)
+	for i := 0; i < 100; i++ {
+		key, value := fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)
+		m[key] = appendRecord(t, l, key, value)
+	}
+	if l.Segments() < 2 {
+		t.Errorf("expected several segments, got %d", l.Segments())
+	}
+	for i := 0; i < 100; i++ {
+		checkRecord(t, l, m[fmt.Sprintf("key-%d", i)], fmt.Sprintf("value-%d", i))
+	}
+	segments, size := l.Segments(), l.Size()
+	if err := l.Close(); err != nil {
+		t.Fatalf("failed to close log: %v", err)
+	}
+
+	l = openLog(t, dir)
+	defer l.Close()
+	if (l.Segments() != segments) || (l.Size() != size) {
+		t.Errorf("expected %d segments of %d bytes, got %d of %d", segments, size, l.Segments(), l.Size())
+	}
+	for i := 0; i < 100; i++ {
+		checkRecord(t, l, m[fmt.Sprintf("key-%d", i)], fmt.Sprintf("value-%d", i))
+	}
+
+	if _, err := l.Read(Pointer{}); !errors.Is(err, ErrBadPointer) {
+		t.Errorf("expected ErrBadPointer for zero pointer, got %v", err)
+	}
+	p := m["key-0"]
+	p.Size--
+	if _, err := l.Read(p); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected ErrCorrupted for truncated pointer, got %v", err)
+	}
+}
+
+func TestLogTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	l := openLog(t, dir)
+	good := appendRecord(t, l, "key", "value")
+	appendRecord(t, l, "torn", "value")
+	l.Close()
+
+	path := segmentPath(dir, good.Segment)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat segment: %v", err)
+	}
+	if err := os.Truncate(path, fi.Size()-1); err != nil {
+		t.Fatalf("failed to truncate segment: %v", err)
+	}
+
+	l = openLog(t, dir)
+	defer l.Close()
+	if l.Size() != int64(good.Size) {
+		t.Errorf("expected size %d, got %d", good.Size, l.Size())
+	}
+	checkRecord(t, l, good, "value")
+	if p := appendRecord(t, l, "next", "value"); p.Offset != uint64(good.Size) {
+		t.Errorf("expected record at %d, got %d", good.Size, p.Offset)
+	}
+}
+
+func TestLogRewrite(t *testing.T) {
+	l := openLog(t, t.TempDir())
+	defer l.Close()
+	l.SegmentSize = 1024
+
+	/* Every key is written several times, only the last version is live. */
+	m := make(map[string]Pointer)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key-%d", i%50)
+		m[key] = appendRecord(t, l, key, fmt.Sprintf("value-%d", i))
+	}
+
+	/* Collect all segments written so far, the live records end up in new ones. */
+	segments, size := l.Segments(), l.Size()
+	for i := 0; i < segments; i++ {
+		id, err := l.Rewrite(func(key []byte, p Pointer) bool {
+			return m[string(key)] == p
+		}, func(key []byte, p Pointer) error {
+			m[string(key)] = p
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to rewrite segment: %v", err)
+		}
+		for _, p := range m {
+			if p.Segment == id {
+				t.Fatalf("pointer %+v still refers to rewritten segment %d", p, id)
+			}
+		}
+		if err := l.Remove(id); err != nil {
+			t.Fatalf("failed to remove segment: %v", err)
+		}
+	}
+	if 3*l.Size() > size {
+		t.Errorf("expected log of %d bytes to shrink, got %d", size, l.Size())
+	}
+
+	for i := 250; i < 300; i++ {
+		checkRecord(t, l, m[fmt.Sprintf("key-%d", i%50)], fmt.Sprintf("value-%d", i))
+	}
+	if err := l.Remove(m["key-0"].Segment + 1000); !errors.Is(err, ErrBadPointer) {
+		t.Errorf("expected ErrBadPointer for unknown segment, got %v", err)
+	}
+}
+
+/* TestLogLargeOffset appends past 4 GiB of a sparse segment, where 32-bit offsets would wrap. */
+func TestLogLargeOffset(t *testing.T) {
+	l := openLog(t, t.TempDir())
+	defer l.Close()
+	l.SegmentSize = 1 << 40
+
+	first := appendRecord(t, l, "first", "value")
+	l.segments[0].Size = 1<<32 + 16
+	p := appendRecord(t, l, "key", "large")
+	if p.Offset != 1<<32+16 {
+		t.Errorf("expected record at %d, got %d", 1<<32+16, p.Offset)
+	}
+	checkRecord(t, l, first, "value")
+	checkRecord(t, l, p, "large")
+}
+
+func TestPointerCodec(t *testing.T) {
+	var c PointerCodec
+
+	ps := [...]Pointer{{}, {Segment: 1, Offset: 2, Size: 3}, {Segment: 1 << 31, Offset: 1<<32 - 1, Size: 1 << 20}, {Segment: 2, Offset: 1 << 40, Size: 1<<32 - 1}}
+	var buf []byte
+	for _, p := range ps {
+		buf = c.Append(buf, p)
+	}
+	for _, p := range ps {
+		got, n, err := c.Decode(buf)
+		if err != nil {
+			t.Fatalf("failed to decode %+v: %v", p, err)
+		}
+		if got != p {
+			t.Errorf("expected %+v, got %+v", p, got)
+		}
+		buf = buf[n:]
+	}
+	if _, _, err := c.Decode(buf); !errors.Is(err, ErrBadPointer) {
+		t.Errorf("expected ErrBadPointer for empty buffer, got %v", err)
+	}
+}
@@ -0,0 +1,387 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+/* LSN is a position in the log. It grows monotonically, even across Reset. */
+type LSN uint64
+
+type RecordType byte
+
+const (
+	RecordSet RecordType = iota + 1
+	RecordDel
+	RecordCommit
+	RecordAbort
+)
+
+type Record struct {
+	LSN  LSN
+	Type RecordType
+	Txn  uint64
+
+	Key   []byte
+	Value []byte
+
+	/* Before image, used for undo. HasOld is false if key was absent. */
+	Old    []byte
+	HasOld bool
+
+	/* Compensation records are written during undo and are never undone themselves. */
+	Compensation bool
+}
+
+/* Log is an append-only file of checksummed records, see Record for contents. */
+type Log struct {
+	File *os.File
+	Path string
+
+	base LSN
+	size int64
+	buf  []byte
+}
+
+/*
+ * File layout, all integers are little-endian:
+ *	Header: [magic:8][base LSN:8]
+ *	Record: [payload size:4][crc32 of payload:4][type:1][flags:1][txn:uvarint][key][value][old]
+ * where key, value and old are [size:uvarint][bytes...].
+ */
+const (
+	magic      = "DBMSWAL\x00"
+	headerSize = 16
+
+	recordHeaderSize = 8
+
+	flagHasOld       = 1 << 0
+	flagCompensation = 1 << 1
+)
+
+var (
+	ErrBadHeader = errors.New("bad log header")
+	ErrCorrupted = errors.New("log record is corrupted")
+)
+
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	l := &Log{File: f, Path: path}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat log file: %w", err)
+	}
+	if fi.Size() < headerSize {
+		/* Missing or torn header means the log was never used. */
+		if err := writeHeader(f, 0); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return l, nil
+	}
+
+	var hdr [headerSize]byte
+	if _, err := f.ReadAt(hdr[:], 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read log header: %w", err)
+	}
+	if string(hdr[:len(magic)]) != magic {
+		f.Close()
+		return nil, ErrBadHeader
+	}
+	l.base = LSN(binary.LittleEndian.Uint64(hdr[len(magic):]))
+
+	/* Find the end of the last complete record and cut off torn tail, if any. */
+	l.size = fi.Size() - headerSize
+	end, err := l.scan(l.base, func(Record) error { return nil })
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if l.size != int64(end-l.base) {
+		l.size = int64(end - l.base)
+		if err := f.Truncate(headerSize + l.size); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to truncate torn log tail: %w", err)
+		}
+	}
+
+	return l, nil
+}
+
+func writeHeader(f *os.File, base LSN) error {
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	binary.LittleEndian.PutUint64(hdr[len(magic):], uint64(base))
+
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate log file: %w", err)
+	}
+	if _, err := f.WriteAt(hdr[:], 0); err != nil {
+		return fmt.Errorf("failed to write log header: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log file: %w", err)
+	}
+	return nil
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func encodeRecord(buf []byte, r *Record) []byte {
+	var flags byte
+	if r.HasOld {
+		flags |= flagHasOld
+	}
+	if r.Compensation {
+		flags |= flagCompensation
+	}
+
+	start := len(buf)
+	buf = append(buf, make([]byte, recordHeaderSize)...)
+	buf = append(buf, byte(r.Type), flags)
+	buf = binary.AppendUvarint(buf, r.Txn)
+	buf = appendBytes(buf, r.Key)
+	buf = appendBytes(buf, r.Value)
+	buf = appendBytes(buf, r.Old)
+
+	payload := buf[start+recordHeaderSize:]
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[start+4:], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+func decodeBytes(buf []byte) ([]byte, int, error) {
+	l, n := binary.Uvarint(buf)
+	if (n <= 0) || (uint64(len(buf)-n) < l) {
+		return nil, 0, ErrCorrupted
+	}
+	return buf[n : n+int(l)], n + int(l), nil
+}
+
+func decodeRecord(payload []byte, r *Record) error {
+	if len(payload) < 2 {
+		return ErrCorrupted
+	}
+	r.Type = RecordType(payload[0])
+	r.HasOld = payload[1]&flagHasOld != 0
+	r.Compensation = payload[1]&flagCompensation != 0
+	payload = payload[2:]
+
+	txn, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return ErrCorrupted
+	}
+	r.Txn = txn
+	payload = payload[n:]
+
+	fields := [...]*[]byte{&r.Key, &r.Value, &r.Old}
+	for _, field := range fields {
+		b, n, err := decodeBytes(payload)
+		if err != nil {
+			return err
+		}
+		*field = b
+		payload = payload[n:]
+	}
+
+	return nil
+}
+
+/* Append writes record to the log and assigns its LSN. Record is durable only after Sync. */
+func (l *Log) Append(r *Record) (LSN, error) {
+	l.buf = encodeRecord(l.buf[:0], r)
+	if _, err := l.File.WriteAt(l.buf, headerSize+l.size); err != nil {
+		return 0, fmt.Errorf("failed to append log record: %w", err)
+	}
+
+	r.LSN = l.End()
+	l.size += int64(len(l.buf))
+	return r.LSN, nil
+}
+
+func (l *Log) Close() error {
+	if err := l.Sync(); err != nil {
+		l.File.Close()
+		return err
+	}
+	return l.File.Close()
+}
+
+/* End returns LSN of the next record. */
+func (l *Log) End() LSN {
+	return l.base + LSN(l.size)
+}
+
+/* Reset discards all records. LSNs keep growing from End, so new log replaces the old one atomically. */
+func (l *Log) Reset() error {
+	tmp := l.Path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create log file: %w", err)
+	}
+	if err := writeHeader(f, l.End()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := os.Rename(tmp, l.Path); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to replace log file: %w", err)
+	}
+
+	l.File.Close()
+	l.File = f
+	l.base = l.End()
+	l.size = 0
+	return nil
+}
+
+/* scan returns LSN right after the last complete record. */
+func (l *Log) scan(from LSN, fn func(Record) error) (LSN, error) {
+	if from < l.base {
+		from = l.base
+	}
+
+	var hdr [recordHeaderSize]byte
+	var payload []byte
+
+	offset := int64(from - l.base)
+	for offset+recordHeaderSize <= l.size {
+		if _, err := l.File.ReadAt(hdr[:], headerSize+offset); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, fmt.Errorf("failed to read log record: %w", err)
+		}
+
+		size := int64(binary.LittleEndian.Uint32(hdr[:]))
+		if offset+recordHeaderSize+size > l.size {
+			break
+		}
+		if int64(cap(payload)) < size {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := l.File.ReadAt(payload, headerSize+offset+recordHeaderSize); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, fmt.Errorf("failed to read log record: %w", err)
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(hdr[4:]) {
+			break
+		}
+
+		r := Record{LSN: l.base + LSN(offset)}
+		if err := decodeRecord(payload, &r); err != nil {
+			break
+		}
+		if err := fn(r); err != nil {
+			return 0, err
+		}
+		offset += recordHeaderSize + size
+	}
+
+	return l.base + LSN(offset), nil
+}
+
+/* Scan calls fn for every complete record starting at 'from'. Record contents are valid only until fn returns. Scan stops silently at torn or corrupted tail. */
+func (l *Log) Scan(from LSN, fn func(Record) error) error {
+	_, err := l.scan(from, fn)
+	return err
+}
+
+func (l *Log) Sync() error {
+	if err := l.File.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log file: %w", err)
+	}
+	return nil
+}
+
+/*
+ * Recover brings state captured at checkpoint 'from' up to date:
+ *	1. Analysis finds transactions that never committed or aborted ("losers").
+ *	2. Redo repeats history, applying every Set and Del starting from 'from', including ones made by losers.
+ *	3. Undo rolls losers back in reverse order, logging compensation records, and then logs Abort for each of them.
+ * Undo records carry full before images, so undoing the same records twice after a crash during undo is harmless.
+ * Recover returns the largest transaction ID found in the log.
+ */
+func (l *Log) Recover(from LSN, apply func(Record) error) (uint64, error) {
+	var maxTxn uint64
+
+	done := make(map[uint64]bool)
+	if err := l.Scan(l.base, func(r Record) error {
+		maxTxn = max(maxTxn, r.Txn)
+		switch r.Type {
+		case RecordSet, RecordDel:
+			if _, ok := done[r.Txn]; !ok {
+				done[r.Txn] = false
+			}
+		case RecordCommit, RecordAbort:
+			done[r.Txn] = true
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("failed to analyze log: %w", err)
+	}
+
+	var undo []Record
+	if err := l.Scan(l.base, func(r Record) error {
+		if (r.Type != RecordSet) && (r.Type != RecordDel) {
+			return nil
+		}
+		if r.LSN >= from {
+			if err := apply(r); err != nil {
+				return err
+			}
+		}
+		if (!done[r.Txn]) && (!r.Compensation) {
+			r.Key = append([]byte(nil), r.Key...)
+			r.Old = append([]byte(nil), r.Old...)
+			undo = append(undo, r)
+		}
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("failed to redo log: %w", err)
+	}
+
+	for i := len(undo) - 1; i >= 0; i-- {
+		r := &undo[i]
+
+		clr := Record{Txn: r.Txn, Key: r.Key, Compensation: true}
+		if r.HasOld {
+			clr.Type = RecordSet
+			clr.Value = r.Old
+		} else {
+			clr.Type = RecordDel
+		}
+		if _, err := l.Append(&clr); err != nil {
+			return 0, err
+		}
+		if err := apply(clr); err != nil {
+			return 0, err
+		}
+	}
+	for txn, ok := range done {
+		if !ok {
+			if _, err := l.Append(&Record{Type: RecordAbort, Txn: txn}); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return maxTxn, l.Sync()
+}
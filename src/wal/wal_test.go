@@ -0,0 +1,146 @@
+package wal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openLog(t *testing.T, path string) *Log {
+	t.Helper()
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open log: %v", err)
+	}
+	return l
+}
+
+func appendRecord(t *testing.T, l *Log, r Record) LSN {
+	t.Helper()
+
+	lsn, err := l.Append(&r)
+	if err != nil {
+		t.Fatalf("failed to append record: %v", err)
+	}
+	return lsn
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+
+	l := openLog(t, path)
+	records := [...]Record{
+		{Type: RecordSet, Txn: 1, Key: []byte("a"), Value: []byte("1")},
+		{Type: RecordCommit, Txn: 1},
+		{Type: RecordSet, Txn: 2, Key: []byte("a"), Value: []byte("2"), Old: []byte("1"), HasOld: true},
+		{Type: RecordDel, Txn: 2, Key: []byte("a"), Old: []byte("2"), HasOld: true, Compensation: true},
+	}
+	for _, r := range records {
+		appendRecord(t, l, r)
+	}
+	end := l.End()
+	if err := l.Close(); err != nil {
+		t.Fatalf("failed to close log: %v", err)
+	}
+
+	l = openLog(t, path)
+	defer l.Close()
+	if l.End() != end {
+		t.Errorf("expected end %d, got %d", end, l.End())
+	}
+
+	i := 0
+	if err := l.Scan(0, func(r Record) error {
+		want := records[i]
+		if (r.Type != want.Type) || (r.Txn != want.Txn) || (r.HasOld != want.HasOld) || (r.Compensation != want.Compensation) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want, r)
+		}
+		if (!bytes.Equal(r.Key, want.Key)) || (!bytes.Equal(r.Value, want.Value)) || (!bytes.Equal(r.Old, want.Old)) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want, r)
+		}
+		i++
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to scan log: %v", err)
+	}
+	if i != len(records) {
+		t.Errorf("expected %d records, got %d", len(records), i)
+	}
+
+	/* LSNs must keep growing after Reset. */
+	if err := l.Reset(); err != nil {
+		t.Fatalf("failed to reset log: %v", err)
+	}
+	if lsn := appendRecord(t, l, Record{Type: RecordCommit, Txn: 3}); lsn != end {
+		t.Errorf("expected LSN %d, got %d", end, lsn)
+	}
+}
+
+func TestLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+
+	l := openLog(t, path)
+	appendRecord(t, l, Record{Type: RecordSet, Txn: 1, Key: []byte("key"), Value: []byte("value")})
+	good := l.End()
+	appendRecord(t, l, Record{Type: RecordSet, Txn: 1, Key: []byte("torn"), Value: []byte("value")})
+	l.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat log: %v", err)
+	}
+	if err := os.Truncate(path, fi.Size()-1); err != nil {
+		t.Fatalf("failed to truncate log: %v", err)
+	}
+
+	l = openLog(t, path)
+	defer l.Close()
+	if l.End() != good {
+		t.Errorf("expected end %d, got %d", good, l.End())
+	}
+}
+
+func TestLogRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wal.log")
+
+	l := openLog(t, path)
+	defer l.Close()
+
+	appendRecord(t, l, Record{Type: RecordSet, Txn: 1, Key: []byte("a"), Value: []byte("1")})
+	appendRecord(t, l, Record{Type: RecordCommit, Txn: 1})
+	appendRecord(t, l, Record{Type: RecordSet, Txn: 2, Key: []byte("a"), Value: []byte("2"), Old: []byte("1"), HasOld: true})
+	appendRecord(t, l, Record{Type: RecordSet, Txn: 2, Key: []byte("b"), Value: []byte("3")})
+
+	state := make(map[string]string)
+	apply := func(r Record) error {
+		switch r.Type {
+		case RecordSet:
+			state[string(r.Key)] = string(r.Value)
+		case RecordDel:
+			delete(state, string(r.Key))
+		}
+		return nil
+	}
+
+	txn, err := l.Recover(0, apply)
+	if err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	if txn != 2 {
+		t.Errorf("expected max transaction %d, got %d", 2, txn)
+	}
+	if (len(state) != 1) || (state["a"] != "1") {
+		t.Errorf("expected only a=1, got %v", state)
+	}
+
+	/* Recovering again must give the same result, since transaction 2 is now aborted. */
+	clear(state)
+	if _, err := l.Recover(0, apply); err != nil {
+		t.Fatalf("failed to recover: %v", err)
+	}
+	if (len(state) != 1) || (state["a"] != "1") {
+		t.Errorf("expected only a=1, got %v", state)
+	}
+}